@@ -0,0 +1,111 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// AggResult holds the statistics computed by Aggregate
+type AggResult struct {
+	// Count is the number of items with a numeric value for the field
+	Count int
+	// Skipped is the number of matching items where the field was missing or
+	// not numeric
+	Skipped int
+	Sum     float64
+	Min     float64
+	Max     float64
+	Avg     float64
+}
+
+// Aggregate computes count, sum, min, max and average of a numeric field over
+// the items matching the lookup in a single scan, without materializing the
+// result set. Items with a missing or non numeric value are skipped.
+func (self *FileStoreHandler) Aggregate(ctx context.Context, lookup *resource.Lookup, field string) (res AggResult, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return res, err
+	}
+	if err := self.checkLookup(lookup); err != nil {
+		return res, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		ids, ok := self.indexCandidates(lookup)
+		if !ok {
+			if ids, ok = self.rangeCandidates(lookup); !ok {
+				if err := self.checkFullScan(lookup); err != nil {
+					return err
+				}
+				ids = self.ids
+			}
+		}
+		for i, id := range ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			item, found, err := self.peekValid(id)
+			if err != nil {
+				return err
+			}
+			if !found {
+				// Expired or deleted, see peek
+				continue
+			}
+			if !self.match(lookup, item.Payload) {
+				continue
+			}
+			value, ok := toFloat(item.GetField(field))
+			if !ok {
+				res.Skipped++
+				continue
+			}
+			if res.Count == 0 || value < res.Min {
+				res.Min = value
+			}
+			if res.Count == 0 || value > res.Max {
+				res.Max = value
+			}
+			res.Sum += value
+			res.Count++
+		}
+		if res.Count > 0 {
+			res.Avg = res.Sum / float64(res.Count)
+		}
+		return nil
+	})
+	return res, err
+}
+
+// toFloat converts any numeric value to a float64
+func toFloat(value interface{}) (float64, bool) {
+	switch t := value.(type) {
+	case int:
+		return float64(t), true
+	case int8:
+		return float64(t), true
+	case int16:
+		return float64(t), true
+	case int32:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case uint:
+		return float64(t), true
+	case uint8:
+		return float64(t), true
+	case uint16:
+		return float64(t), true
+	case uint32:
+		return float64(t), true
+	case uint64:
+		return float64(t), true
+	case float32:
+		return float64(t), true
+	case float64:
+		return t, true
+	}
+	return 0, false
+}
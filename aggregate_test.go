@@ -0,0 +1,86 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func aggregateItems(t *testing.T, opts ...Option) *FileStoreHandler {
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	if err := h.Insert(context.Background(), []*resource.Item{
+		mkitem(1, map[string]interface{}{"g": "x", "n": 1}),
+		mkitem(2, map[string]interface{}{"g": "x", "n": 4.5}),
+		mkitem(3, map[string]interface{}{"g": "y", "n": -2}),
+		mkitem(4, map[string]interface{}{"g": "y", "n": "nope"}),
+		mkitem(5, map[string]interface{}{"g": "y"}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func groupLookup(group string) *resource.Lookup {
+	l := resource.NewLookup()
+	if group != "" {
+		l.AddQuery(schema.Query{schema.Equal{Field: "g", Value: group}})
+	}
+	return l
+}
+
+func TestAggregate(t *testing.T) {
+	ctx := context.Background()
+	for _, indexed := range []bool{false, true} {
+		var opts []Option
+		if indexed {
+			opts = append(opts, WithIndexedFields("g"))
+		}
+		h := aggregateItems(t, opts...)
+		for _, c := range []struct {
+			group string
+			want  AggResult
+		}{
+			{"", AggResult{Count: 3, Skipped: 2, Sum: 3.5, Min: -2, Max: 4.5, Avg: 3.5 / 3}},
+			{"x", AggResult{Count: 2, Sum: 5.5, Min: 1, Max: 4.5, Avg: 2.75}},
+			{"y", AggResult{Count: 1, Skipped: 2, Sum: -2, Min: -2, Max: -2, Avg: -2}},
+			{"z", AggResult{}},
+		} {
+			if res, err := h.Aggregate(ctx, groupLookup(c.group), "n"); err != nil || res != c.want {
+				t.Errorf("indexed %v, group %q: %+v %v", indexed, c.group, res, err)
+			}
+		}
+	}
+}
+
+func TestAggregateSkipCorrupt(t *testing.T) {
+	ctx := context.Background()
+	h := aggregateItems(t)
+	h.items[2] = []byte{0xff, 0x01}
+	h.cache.invalidate(2)
+	if _, err := h.Aggregate(ctx, groupLookup(""), "n"); err == nil {
+		t.Fatal("corrupt item not reported")
+	}
+	h.SkipCorrupt = true
+	if res, err := h.Aggregate(ctx, groupLookup("x"), "n"); err != nil || res.Count != 1 || res.Sum != 1 {
+		t.Fatal(res, err)
+	}
+}
+
+func TestAggregateStrictQueries(t *testing.T) {
+	ctx := context.Background()
+	h := aggregateItems(t, WithIndexedFields("g"), WithStrictQueries())
+	if _, err := h.Aggregate(ctx, groupLookup("x"), "n"); err != nil {
+		t.Fatal(err)
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.NotEqual{Field: "g", Value: "x"}})
+	if _, err := h.Aggregate(ctx, l, "n"); err == nil {
+		t.Fatal("expected err")
+	}
+}
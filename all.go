@@ -0,0 +1,24 @@
+package filestore
+
+import (
+	"sort"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// All returns every item sorted by id, for the small collections read whole
+// like configurations. It is Find with an empty lookup and no pagination, so
+// the latency, the context and MaxResultItems apply the same way, and the
+// items are copies the caller may modify, unless ReadOnlyResults is set.
+func (self *FileStoreHandler) All(ctx context.Context) ([]*resource.Item, error) {
+	list, err := self.Find(ctx, resource.NewLookup(), 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	items := list.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		return lessID(items[i].ID, items[j].ID)
+	})
+	return items, nil
+}
@@ -0,0 +1,36 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestAll(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	for _, id := range []int{5, 3, 10, 1, 7} {
+		h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{})})
+	}
+	items, err := h.All(ctx)
+	if err != nil || len(items) != 5 {
+		t.Fatal(items, err)
+	}
+	for i, want := range []int{1, 3, 5, 7, 10} {
+		if items[i].ID != want {
+			t.Fatal(i, items[i].ID)
+		}
+	}
+	h.Latency = time.Second
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := h.All(cctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+}
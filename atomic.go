@@ -0,0 +1,54 @@
+package filestore
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// over path, so path holds either its previous or its new content whenever
+// the process dies. If durable is set, the file is synced before the rename
+// and its directory after, so the new content survives a power failure too.
+// A write failing halfway, on a full disk for instance, leaves path untouched
+// and its temporary file is removed. The temporary file has a random name so
+// the processes sharing the datafile, see NewSharedHandler and MergeOnSave,
+// never write to the same one.
+func writeFileAtomic(path string, data []byte, perm os.FileMode, durable bool) error {
+	return writeFileAtomicFS(OSFileSystem{}, path, data, perm, durable)
+}
+
+// writeFileAtomicFS is writeFileAtomic on the FileSystem fs
+func writeFileAtomicFS(fs FileSystem, path string, data []byte, perm os.FileMode, durable bool) error {
+	tmp, err := tempPath(path)
+	if err != nil {
+		return err
+	}
+	err = fs.WriteFile(tmp, data, perm)
+	if err == nil && durable {
+		err = fs.Sync(tmp)
+	}
+	if err == nil {
+		err = fs.Rename(tmp, path)
+	}
+	if err != nil {
+		fs.Remove(tmp)
+		return err
+	}
+	if durable {
+		// Best effort as not every platform supports syncing a directory
+		fs.Sync(filepath.Dir(path))
+	}
+	return nil
+}
+
+// tempPath returns a path for a temporary file next to path, unique to the
+// caller
+func tempPath(path string) (string, error) {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", err
+	}
+	return path + "." + hex.EncodeToString(suffix[:]) + ".tmp", nil
+}
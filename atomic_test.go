@@ -0,0 +1,38 @@
+package filestore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteFileAtomicConcurrent(t *testing.T) {
+	d := tmpdir(t)
+	path := filepath.Join(d, "c")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data := bytes.Repeat([]byte{byte('a' + i)}, 1<<16)
+			if err := writeFileAtomic(path, data, 0644, false); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// The content of a single writer, never a mix
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1<<16 || !bytes.Equal(data, bytes.Repeat(data[:1], 1<<16)) {
+		t.Fatal("mixed content")
+	}
+	files, _ := ioutil.ReadDir(d)
+	if len(files) != 1 {
+		t.Fatal("temporary files left", len(files))
+	}
+}
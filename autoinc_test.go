@@ -0,0 +1,42 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestAutoIncrement(t *testing.T) {
+	d := tmpdir(t)
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(d, "c", WithAutoIncrement())
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := []*resource.Item{{Payload: map[string]interface{}{}}, {Payload: map[string]interface{}{}}, {Payload: map[string]interface{}{}}}
+	if err := h.Insert(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	for i, it := range items {
+		if it.ID != i+1 || it.Payload["id"] != i+1 {
+			t.Fatal(it)
+		}
+	}
+	l, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+	h.Delete(ctx, l.Items[2])
+	h.Close()
+	h, _ = NewHandlerWithOptions(d, "c", WithAutoIncrement())
+	it := &resource.Item{Payload: map[string]interface{}{}}
+	if err := h.Insert(ctx, []*resource.Item{it}); err != nil || it.ID != 4 {
+		t.Fatal(err, it.ID)
+	}
+	h.Close()
+	j, _ := NewHandlerWithOptions(d, "j", WithAutoIncrement(), WithCodec(JSONCodec{}))
+	j.Insert(ctx, []*resource.Item{{Payload: map[string]interface{}{}}})
+	j.Close()
+	j, _ = NewHandlerWithOptions(d, "j", WithAutoIncrement(), WithCodec(JSONCodec{}))
+	if j.sequence != 1 {
+		t.Fatal(j.sequence)
+	}
+}
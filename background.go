@@ -0,0 +1,191 @@
+package filestore
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ShutdownTimeout is how long Close waits for the background workers to stop
+var ShutdownTimeout = 10 * time.Second
+
+// workerRestartDelay is how long a worker which panicked waits before being
+// restarted, see RestartWorkers
+var workerRestartDelay = time.Second
+
+// shutdownStage orders the stop sequence of the background workers
+type shutdownStage int
+
+const (
+	// stageProducer workers generate new work (TTL sweeper, snapshotter) and
+	// are stopped first
+	stageProducer shutdownStage = iota
+	// stageFlusher workers persist pending writes before exiting
+	stageFlusher
+	// stageWatcher workers watch the datafile and are stopped once nothing
+	// writes to it anymore
+	stageWatcher
+	numStages
+)
+
+type worker struct {
+	stage shutdownStage
+	stop  chan struct{}
+	done  chan struct{}
+	// stopped is set once stop is closed
+	stopped bool
+}
+
+// lifecycle tracks the background workers and resources of a handler
+type lifecycle struct {
+	sync.Mutex
+	// closing is set once a Shutdown started, no worker is started
+	// afterwards, and closed once one completed
+	closing bool
+	closed  bool
+	// shutdown is closed when the running Shutdown returns, nil if none runs
+	shutdown chan struct{}
+	workers  []*worker
+	releases []func() error
+}
+
+// startWorker runs fn in a background goroutine until its stop channel is
+// closed by Shutdown. fn must return promptly once stop is closed and must not
+// expect the handler's lock to be free when it is. It must release the lock
+// with defer so a panic doesn't leave it held: the panic is recovered, logged
+// and fn restarted or the handler degraded, see RestartWorkers.
+func (self *FileStoreHandler) startWorker(stage shutdownStage, fn func(stop <-chan struct{})) {
+	self.lifecycle.Lock()
+	defer self.lifecycle.Unlock()
+	if self.lifecycle.closing {
+		return
+	}
+	w := &worker{stage: stage, stop: make(chan struct{}), done: make(chan struct{})}
+	self.lifecycle.workers = append(self.lifecycle.workers, w)
+	go func() {
+		defer close(w.done)
+		for self.runWorker(fn, w.stop) {
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(workerRestartDelay):
+			}
+		}
+	}()
+}
+
+// runWorker runs fn and recovers its panic. It returns true if fn panicked
+// and must be restarted.
+func (self *FileStoreHandler) runWorker(fn func(stop <-chan struct{}), stop <-chan struct{}) (restart bool) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		count(&self.counters.workerPanics, 1)
+		self.logf("Error: background worker of database %s panicked: %v\n%s", self.database_file, r, debug.Stack())
+		self.Lock()
+		defer self.Unlock()
+		if !self.RestartWorkers {
+			self.degraded = true
+		}
+		restart = self.RestartWorkers
+	}()
+	fn(stop)
+	return false
+}
+
+// onShutdown registers a release function called by Shutdown once all the
+// workers are stopped, like unlocking the datafile
+func (self *FileStoreHandler) onShutdown(release func() error) {
+	self.lifecycle.Lock()
+	defer self.lifecycle.Unlock()
+	self.lifecycle.releases = append(self.lifecycle.releases, release)
+}
+
+// Close shuts the handler down, waiting at most ShutdownTimeout. See Shutdown.
+func (self *FileStoreHandler) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	return self.Shutdown(ctx)
+}
+
+// Shutdown stops the background workers stage by stage: producers of new work
+// first, then the flushers writing the pending changes, then the datafile
+// watchers. The datafile is then saved a last time if changes are left to
+// persist, see needsSave, and the handler is marked
+// closed, the operations made afterwards fail with ErrClosed. The registered
+// resources are released last. The handler's lock is never held while waiting
+// so a worker busy with an operation can finish it. If ctx is done before the
+// workers are stopped, its error is returned and the handler isn't closed: no
+// worker is started anymore and the next Shutdown or Close resumes where it
+// stopped. A Shutdown called while another runs waits for it.
+func (self *FileStoreHandler) Shutdown(ctx context.Context) error {
+	self.lifecycle.Lock()
+	for self.lifecycle.shutdown != nil {
+		running := self.lifecycle.shutdown
+		self.lifecycle.Unlock()
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		self.lifecycle.Lock()
+	}
+	if self.lifecycle.closed {
+		self.lifecycle.Unlock()
+		return nil
+	}
+	self.lifecycle.closing = true
+	running := make(chan struct{})
+	self.lifecycle.shutdown = running
+	workers := self.lifecycle.workers
+	releases := self.lifecycle.releases
+	self.lifecycle.Unlock()
+	closed := false
+	defer func() {
+		self.lifecycle.Lock()
+		self.lifecycle.closed = closed
+		self.lifecycle.shutdown = nil
+		self.lifecycle.Unlock()
+		close(running)
+	}()
+
+	for stage := stageProducer; stage < numStages; stage++ {
+		for _, w := range workers {
+			if w.stage == stage && !w.stopped {
+				close(w.stop)
+				w.stopped = true
+			}
+		}
+		for _, w := range workers {
+			if w.stage != stage {
+				continue
+			}
+			select {
+			case <-w.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	self.Lock()
+	var err error
+	if !self.ReadOnly && self.needsSave() {
+		err = self.saveDatafile()
+	}
+	self.closed = true
+	self.closeSubscribers()
+	self.Unlock()
+	closed = true
+
+	for _, release := range releases {
+		if e := release(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
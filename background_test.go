@@ -0,0 +1,92 @@
+package filestore
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestCloseStopsBackgroundWorkers(t *testing.T) {
+	ctx := context.Background()
+	before := runtime.NumGoroutine()
+	var handlers []*FileStoreHandler
+	for _, opts := range [][]Option{
+		{WithTTL(10 * time.Millisecond), WithFlushInterval(10 * time.Millisecond)},
+		{WithWAL(), WithSyncBatchWindow(time.Millisecond)},
+		{WithCoalesceWrites(), WithSyncBatchWindow(time.Millisecond)},
+	} {
+		h, err := NewHandlerWithOptions(tmpdir(t), "c", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Subscribe()
+		if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})}); err != nil {
+			t.Fatal(err)
+		}
+		handlers = append(handlers, h)
+	}
+	start := time.Now()
+	for _, h := range handlers {
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatal("slow close", elapsed)
+	}
+	// The goroutines exit right after signaling they stopped
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines left running\n%s", runtime.NumGoroutine()-before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	fs := &stallFS{entered: make(chan struct{}), release: make(chan struct{})}
+	h, err := NewHandlerWithOptions(d, "c", WithFlushInterval(5*time.Millisecond), WithFileSystem(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&fs.failing, 1)
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+	// The flusher is stuck saving
+	<-fs.entered
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(timeout); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&fs.failing, 0)
+	close(fs.release)
+	// The retry resumes the shutdown
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{})}); err != ErrClosed {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Saved a last time and unlocked
+	h, err = NewHandler(d, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if ids := h.IDs(); len(ids) != 1 || ids[0] != "a" {
+		t.Fatal(ids)
+	}
+}
@@ -0,0 +1,84 @@
+package filestore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// ErrNoBackup is returned by RollbackToBackup when the backup doesn't exist
+var ErrNoBackup = &rest.Error{Code: 404, Message: "Backup not found"}
+
+// backupFile returns the path of the backup n of the datafile
+func (self *FileStoreHandler) backupFile(n int) string {
+	return fmt.Sprintf("%s.%d", self.database_file, n)
+}
+
+// rotateBackups shifts the backups of the datafile, dropping the oldest one,
+// and copies the current datafile to the backup 1. It is called before the
+// datafile is rewritten with data if Backups is set, and does nothing if data
+// is the current content: the backups keep the states replaced. The datafile
+// is copied rather than renamed so there is always a datafile in place.
+func (self *FileStoreHandler) rotateBackups(data []byte) error {
+	if self.Backups <= 0 {
+		return nil
+	}
+	fs := self.fileSystem()
+	current, err := fs.ReadFile(self.database_file)
+	if os.IsNotExist(err) || err == nil && bytes.Equal(current, data) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for n := self.Backups; n > 1; n-- {
+		if err := fs.Rename(self.backupFile(n-1), self.backupFile(n)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return writeFileAtomicFS(fs, self.backupFile(1), current, self.fileMode(), self.Durable)
+}
+
+// RollbackToBackup replaces the items with the content of the backup n of the
+// datafile, 1 being the most recent, and saves them. The current datafile is
+// backed up in turn, so a rollback can itself be rolled back. The items are
+// left untouched if the backup can't be read, decoded or saved. Like the other
+// bulk changes, the subscribers don't get an event per item and ChangesSince
+// requires a resync.
+func (self *FileStoreHandler) RollbackToBackup(ctx context.Context, n int) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if self.inMemory() || self.PerItemFiles || n < 1 {
+			return ErrNoBackup
+		}
+		var data []byte
+		err := self.retryIO(func() (err error) {
+			data, err = self.fileSystem().ReadFile(self.backupFile(n))
+			return err
+		})
+		if os.IsNotExist(err) {
+			return ErrNoBackup
+		} else if err != nil {
+			return err
+		}
+		err = self.replaceItems(func() error {
+			if err := self.decodeDatafile(data); err != nil {
+				return err
+			}
+			return self.saveDatafile()
+		})
+		if err != nil {
+			return err
+		}
+		self.debugf("Rolled database %s back to backup %d", self.database_file, n)
+		return nil
+	})
+}
@@ -0,0 +1,103 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/rs/rest-layer/resource"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestBackups(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		if err := h.Insert(ctx, []*resource.Item{mkitem(i, map[string]interface{}{})}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(d, "c.3")); !os.IsNotExist(err) {
+		t.Fatal("rotation depth", err)
+	}
+	if err := h.RollbackToBackup(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if h.Len() != 2 {
+		t.Fatal(h.Len())
+	}
+	if err := h.RollbackToBackup(ctx, 5); err != ErrNoBackup {
+		t.Fatal(err)
+	}
+	// The rolled back datafile is backup 1
+	if err := h.RollbackToBackup(ctx, 1); err != nil || h.Len() != 4 {
+		t.Fatal(err, h.Len())
+	}
+	h.Close()
+	h, _ = NewHandler(d, "c", nil)
+	defer h.Close()
+	if h.Len() != 4 {
+		t.Fatal(h.Len())
+	}
+}
+
+func TestBackupsIdleClose(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		if err := h.Insert(ctx, []*resource.Item{mkitem(i, map[string]interface{}{})}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Close()
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(d, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	want := []string{read("c"), read("c.1"), read("c.2")}
+	if want[0] == want[1] || want[1] == want[2] {
+		t.Fatal("backups not rotated")
+	}
+	// An idle Close doesn't rewrite the datafile
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filepath.Join(d, "c"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if h, err = NewHandlerWithOptions(d, "c", WithBackups(2)); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(d, "c")); err != nil || !fi.ModTime().Equal(old) {
+		t.Fatal("datafile rewritten", err)
+	}
+	// Saving the same content again doesn't rotate either
+	if h, err = NewHandlerWithOptions(d, "c", WithBackups(2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	if got := []string{read("c"), read("c.1"), read("c.2")}; !reflect.DeepEqual(got, want) {
+		t.Fatal("backups replaced by idle cycles")
+	}
+}
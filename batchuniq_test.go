@@ -0,0 +1,28 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestBatchUnique(t *testing.T) {
+	h, _ := NewHandlerWithOptions(tmpdir(t), "c", WithUniqueFields("n", "e"), WithCaseInsensitiveUniqueFields("e"), WithUniqueCompositeFields([]string{"a", "b"}))
+	ctx := context.Background()
+	for _, batch := range [][]*resource.Item{
+		{mkitem("x", map[string]interface{}{"n": 1}), mkitem("y", map[string]interface{}{"n": 1})},
+		{mkitem("x", map[string]interface{}{"e": "A@b"}), mkitem("y", map[string]interface{}{"e": " a@B"})},
+		{mkitem("x", map[string]interface{}{"a": 1, "b": 2}), mkitem("y", map[string]interface{}{"a": 1, "b": 2})},
+	} {
+		if _, ok := UniqueViolation(h.Insert(ctx, batch)); !ok {
+			t.Fatal("accepted", batch[0].Payload)
+		}
+		if h.Len() != 0 {
+			t.Fatal("inserted")
+		}
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("x", map[string]interface{}{"n": 1, "a": 1, "b": 2}), mkitem("y", map[string]interface{}{"n": 2, "a": 1}), mkitem("z", map[string]interface{}{"n": nil, "a": 2, "b": 2}), mkitem("w", map[string]interface{}{"n": nil})}); err != nil {
+		t.Fatal(err)
+	}
+}
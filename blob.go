@@ -0,0 +1,275 @@
+package filestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// With BlobThreshold set, the top level []byte fields of at least
+// BlobThreshold bytes aren't stored in the records: each one is written to a
+// sidecar file of the blobs directory, next to the datafile, named after the
+// sha256 of its content, and the record holds a blobRef to it instead. The
+// records, and so the datafile, stay small whatever the size of the binary
+// fields. The blobs are read back the first time their item is decoded, the
+// decoded items are then cached with their blobs like any other.
+//
+// A blob file is written when a record referencing it is encoded and never
+// modified, so the records restored by a rollback can always be read back.
+// The references of the stored records are counted: a blob no longer
+// referenced is removed once the collection is saved or logged, unless a
+// change retained for ChangesSince still references it. A blob is
+// shared by the items holding the same bytes. The backups of the datafile
+// don't keep the blobs of the items changed since, and the blobs written by
+// writes which failed are left behind. BlobThreshold is ignored by the memory
+// handlers.
+
+// blobRef is the value a record holds for a field stored in a blob file. The
+// JSON codec reads it back as a map holding the key under "$blob".
+type blobRef struct {
+	Blob string `json:"$blob"`
+}
+
+// blobbing tells if the large binary fields are stored in blob files
+func (self *FileStoreHandler) blobbing() bool {
+	return self.BlobThreshold > 0 && !self.inMemory()
+}
+
+// blobDir returns the directory of the blob files
+func (self *FileStoreHandler) blobDir() string {
+	return self.database_file + ".blobs"
+}
+
+// blobFile returns the path of the blob file with key
+func (self *FileStoreHandler) blobFile(key string) string {
+	return filepath.Join(self.blobDir(), key)
+}
+
+// externalize returns item with its large binary fields replaced by blobRefs,
+// writing the blob files missing. The payload of item itself isn't modified.
+func (self *FileStoreHandler) externalize(item *resource.Item) (*resource.Item, error) {
+	return self.externalizeWith(item, self.writeBlob)
+}
+
+// externalizeWith is externalize writing the blobs with write
+func (self *FileStoreHandler) externalizeWith(item *resource.Item, write func(key string, data []byte) error) (*resource.Item, error) {
+	if !self.blobbing() {
+		return item, nil
+	}
+	var payload map[string]interface{}
+	for field, value := range item.Payload {
+		data, ok := value.([]byte)
+		if !ok || len(data) < self.BlobThreshold {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		key := hex.EncodeToString(sum[:])
+		if err := write(key, data); err != nil {
+			return nil, err
+		}
+		if payload == nil {
+			payload = make(map[string]interface{}, len(item.Payload))
+			for k, v := range item.Payload {
+				payload[k] = v
+			}
+		}
+		payload[field] = blobRef{key}
+	}
+	if payload == nil {
+		return item, nil
+	}
+	externalized := *item
+	externalized.Payload = payload
+	return &externalized, nil
+}
+
+// writeBlob writes the blob file with key unless it exists already
+func (self *FileStoreHandler) writeBlob(key string, data []byte) error {
+	fs := self.fileSystem()
+	path := self.blobFile(key)
+	if _, err := fs.Stat(path); err == nil {
+		return nil
+	}
+	if err := fs.MkdirAll(self.blobDir(), self.dirMode()); err != nil {
+		return err
+	}
+	data, err := self.encrypt(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomicFS(fs, path, data, self.fileMode(), self.Durable)
+}
+
+// blobKey returns the key of value if it's a blobRef, as read back by any
+// codec
+func blobKey(value interface{}) (string, bool) {
+	switch ref := value.(type) {
+	case blobRef:
+		return ref.Blob, true
+	case map[string]interface{}:
+		if key, ok := ref["$blob"].(string); ok && len(ref) == 1 {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// resolveBlobs replaces the blobRefs of a decoded item by the content of
+// their blob file
+func (self *FileStoreHandler) resolveBlobs(item *resource.Item) error {
+	if !self.blobbing() {
+		return nil
+	}
+	for field, value := range item.Payload {
+		key, ok := blobKey(value)
+		if !ok {
+			continue
+		}
+		data, err := self.fileSystem().ReadFile(self.blobFile(key))
+		if err == nil {
+			data, err = self.decrypt(data)
+		}
+		if err != nil {
+			return fmt.Errorf("filestore: can't read blob %s of item %v: %v", key, item.ID, err)
+		}
+		item.Payload[field] = data
+	}
+	return nil
+}
+
+// recountBlobs counts the blobs referenced by all the records, after the
+// records were replaced at once. It is called with the indexes rebuilt.
+func (self *FileStoreHandler) recountBlobs() {
+	if !self.blobbing() {
+		return
+	}
+	previous := self.blobs.refs
+	self.blobs = blobCounts{garbage: self.blobs.garbage, logged: self.blobs.logged}
+	self.blobs.init()
+	for id := range self.items {
+		self.refBlobs(id)
+	}
+	for key := range previous {
+		if self.blobs.refs[key] == 0 {
+			self.blobs.garbage[key] = true
+		}
+	}
+}
+
+// refBlobs counts the blobs referenced by the record of id, which was just
+// set, in place of the ones of its previous record
+func (self *FileStoreHandler) refBlobs(id interface{}) {
+	if !self.blobbing() {
+		return
+	}
+	self.unrefBlobs(id)
+	data, _, err := self.record(id)
+	if err != nil {
+		return
+	}
+	var item resource.Item
+	if err := decodeRecord(data, &item); err != nil {
+		return
+	}
+	var keys []string
+	for _, value := range item.Payload {
+		if key, ok := blobKey(value); ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	self.blobs.init()
+	self.blobs.keys[id] = keys
+	for _, key := range keys {
+		self.blobs.refs[key]++
+	}
+}
+
+// unrefBlobs forgets the blobs referenced by the record of id, the ones no
+// longer referenced are removed by the next save
+func (self *FileStoreHandler) unrefBlobs(id interface{}) {
+	keys, found := self.blobs.keys[id]
+	if !found {
+		return
+	}
+	delete(self.blobs.keys, id)
+	for _, key := range keys {
+		if self.blobs.refs[key]--; self.blobs.refs[key] <= 0 {
+			delete(self.blobs.refs, key)
+			self.blobs.garbage[key] = true
+		}
+	}
+}
+
+// logBlobs counts the blobs referenced by a retained change
+func (self *FileStoreHandler) logBlobs(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	self.blobs.init()
+	for _, key := range keys {
+		self.blobs.logged[key]++
+	}
+}
+
+// unlogBlobs forgets the blobs referenced by a dropped change, the ones no
+// longer referenced are removed by the next save
+func (self *FileStoreHandler) unlogBlobs(keys []string) {
+	for _, key := range keys {
+		if self.blobs.logged[key]--; self.blobs.logged[key] <= 0 {
+			delete(self.blobs.logged, key)
+			if self.blobs.refs[key] <= 0 {
+				self.blobs.garbage[key] = true
+			}
+		}
+	}
+}
+
+// removeGarbageBlobs removes the blob files no longer referenced, once the
+// records which referenced them are saved
+func (self *FileStoreHandler) removeGarbageBlobs() {
+	for key := range self.blobs.garbage {
+		delete(self.blobs.garbage, key)
+		if self.blobs.refs[key] > 0 || self.blobs.logged[key] > 0 {
+			// Referenced again in the meantime, or by a retained change
+			// which marks it again once dropped, see unlogBlobs
+			continue
+		}
+		if err := self.fileSystem().Remove(self.blobFile(key)); err != nil && !os.IsNotExist(err) {
+			self.logf("Error removing blob %s of database %s: %v", key, self.database_file, err)
+		}
+	}
+}
+
+// blobCounts tracks the blobs referenced by the stored records: keys are the
+// blobs of each record, refs the number of records referencing each blob,
+// logged the number of retained changes referencing it and garbage the blobs
+// to remove
+type blobCounts struct {
+	keys    map[interface{}][]string
+	refs    map[string]int
+	logged  map[string]int
+	garbage map[string]bool
+}
+
+// init makes the maps still nil
+func (self *blobCounts) init() {
+	if self.keys == nil {
+		self.keys = map[interface{}][]string{}
+	}
+	if self.refs == nil {
+		self.refs = map[string]int{}
+	}
+	if self.logged == nil {
+		self.logged = map[string]int{}
+	}
+	if self.garbage == nil {
+		self.garbage = map[string]bool{}
+	}
+}
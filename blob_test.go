@@ -0,0 +1,78 @@
+package filestore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestBlobs(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithCodec(JSONCodec{})}, {WithPerItemFiles(), WithOffloadRecords()}, {WithWAL()}} {
+		dir := tmpdir(t)
+		ctx := context.Background()
+		big := bytes.Repeat([]byte("x"), 1000)
+		o := append([]Option{WithBlobThreshold(100)}, opts...)
+		h, err := NewHandlerWithOptions(dir, "c", o...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Insert(ctx, []*resource.Item{
+			mkitem("1", map[string]interface{}{"data": big, "small": []byte("abc")}),
+			mkitem("2", map[string]interface{}{"data": big}),
+			mkitem("3", map[string]interface{}{"data": append([]byte("y"), big...)}),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		files, _ := ioutil.ReadDir(h.blobDir())
+		if len(files) != 2 {
+			t.Fatal(opts, len(files))
+		}
+		h.Close()
+		h, err = NewHandlerWithOptions(dir, "c", o...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		item, err := h.Get(ctx, "1")
+		if err != nil || !bytes.Equal(item.Payload["data"].([]byte), big) {
+			t.Fatal(opts, item, err)
+		}
+		// Shared by 1 and 2
+		if err := h.Delete(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+		if files, _ := ioutil.ReadDir(h.blobDir()); len(files) != 2 {
+			t.Fatal(opts, len(files))
+		}
+		item, _ = h.Get(ctx, "2")
+		if err := h.Delete(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+		if files, _ := ioutil.ReadDir(h.blobDir()); len(files) != 1 {
+			t.Fatal(opts, len(files))
+		}
+		item, _ = h.Get(ctx, "3")
+		if item.Payload["data"].([]byte)[0] != 'y' {
+			t.Fatal(opts, item)
+		}
+		h.Close()
+	}
+}
+
+func TestBlobsInJSONDatafile(t *testing.T) {
+	dir := tmpdir(t)
+	ctx := context.Background()
+	h, _ := NewHandlerWithOptions(dir, "c", WithCodec(JSONCodec{}), WithBlobThreshold(100))
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"data": make([]byte, 10000)})})
+	h.Close()
+	if data, _ := ioutil.ReadFile(h.database_file); len(data) > 1000 || !bytes.Contains(data, []byte(`"$blob"`)) {
+		t.Fatal(string(data))
+	}
+	h, _ = NewHandlerWithOptions(dir, "c", WithCodec(JSONCodec{}), WithBlobThreshold(100))
+	defer h.Close()
+	if item, err := h.Get(ctx, "a"); err != nil || len(item.Payload["data"].([]byte)) != 10000 {
+		t.Fatal(item, err)
+	}
+}
@@ -0,0 +1,23 @@
+package filestore
+
+import "testing"
+
+func TestPageBounds(t *testing.T) {
+	for _, c := range []struct{ total, page, perPage, start, end int }{
+		{0, 1, 10, 0, 0},
+		{0, 1, -1, 0, 0},
+		{10, 1, 5, 0, 5},
+		{10, 2, 5, 5, 10},
+		{10, 3, 5, 10, 10},
+		{7, 2, 5, 5, 7},
+		{6, 6, 1, 5, 6},
+		{6, 1, 100, 0, 6},
+		{6, 2, -1, 0, 6},
+		{6, 0, 2, 0, 2},
+	} {
+		s, e := pageBounds(c.total, c.page, c.perPage)
+		if s != c.start || e != c.end {
+			t.Errorf("%+v: got %d, %d", c, s, e)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// BeginBulkLoad suspends the persistence of the handler: until EndBulkLoad is
+// called, mutations only update the memory and nothing is written to disk.
+// This amortizes the datafile writes over many independent calls when seeding
+// a collection. Beware that everything written during the bulk load is lost if
+// the process dies before EndBulkLoad.
+func (self *FileStoreHandler) BeginBulkLoad() {
+	self.Lock()
+	defer self.Unlock()
+	self.bulkLoading = true
+}
+
+// EndBulkLoad resumes persistence and writes everything loaded since
+// BeginBulkLoad to disk at once. Within a transaction, see Begin, it is
+// written by Commit instead.
+func (self *FileStoreHandler) EndBulkLoad(ctx context.Context) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if !self.bulkLoading {
+			return nil
+		}
+		if self.txn == nil {
+			if err := self.saveDatafile(); err != nil {
+				return err
+			}
+		}
+		self.bulkLoading = false
+		return nil
+	})
+}
+
+// persistSuspended tells if the mutations only update the memory, during a
+// bulk load or a transaction
+func (self *FileStoreHandler) persistSuspended() bool {
+	return self.bulkLoading || self.txn != nil
+}
+
+// NewHandlerFromItems creates a handler like NewHandlerWithOptions and inserts
+// items with a single write of the datafile, in the order of the slice. It is
+// meant for the fixtures of tests. The handler is closed and an error returned
+// if the items can't all be inserted, see Insert.
+func NewHandlerFromItems(directory string, collection string, items []*resource.Item, opts ...Option) (*FileStoreHandler, error) {
+	h, err := NewHandlerWithOptions(directory, collection, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Insert(context.Background(), items); err != nil {
+		h.Close()
+		return nil, err
+	}
+	return h, nil
+}
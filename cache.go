@@ -0,0 +1,125 @@
+package filestore
+
+import (
+	"sync"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// itemCache holds the decoded items so the records aren't decoded on every
+// read. It has its own lock as it is filled by readers holding the handler's
+// read lock only. An entry is dropped whenever its record changes.
+type itemCache struct {
+	sync.Mutex
+	items map[interface{}]*resource.Item
+}
+
+func (c *itemCache) get(id interface{}) *resource.Item {
+	c.Lock()
+	defer c.Unlock()
+	return c.items[id]
+}
+
+func (c *itemCache) set(id interface{}, item *resource.Item) {
+	c.Lock()
+	defer c.Unlock()
+	if c.items == nil {
+		c.items = map[interface{}]*resource.Item{}
+	}
+	c.items[id] = item
+}
+
+func (c *itemCache) invalidate(id interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.items, id)
+}
+
+// peek returns the decoded item of id from the cache, decoding its record on a
+// cache miss. Expired and soft deleted items aren't found. The returned item
+// is shared and must not be modified, use fetch to get a copy the caller owns.
+func (self *FileStoreHandler) peek(id interface{}) (*resource.Item, bool, error) {
+	item, found, err := self.decodeItem(id)
+	if item != nil && (self.expired(item) || self.softDeleted(item)) {
+		return nil, false, nil
+	}
+	return item, found, err
+}
+
+// peekValid is like peek for the scans of the lookups: with SkipCorrupt set,
+// an item which can't be decoded is logged, counted and reported as not found
+// instead of failing the scan
+func (self *FileStoreHandler) peekValid(id interface{}) (*resource.Item, bool, error) {
+	item, found, err := self.peek(id)
+	if err != nil && self.SkipCorrupt {
+		self.skipCorrupt(id, err)
+		return nil, false, nil
+	}
+	return item, found, err
+}
+
+// decodeValid is like decodeItem with the items which can't be decoded
+// skipped like by peekValid
+func (self *FileStoreHandler) decodeValid(id interface{}) (*resource.Item, bool, error) {
+	item, found, err := self.decodeItem(id)
+	if err != nil && self.SkipCorrupt {
+		self.skipCorrupt(id, err)
+		return nil, false, nil
+	}
+	return item, found, err
+}
+
+// skipCorrupt logs and counts the skip of an item which can't be decoded
+func (self *FileStoreHandler) skipCorrupt(id interface{}, err error) {
+	count(&self.counters.corrupt, 1)
+	self.logf("Warning: skipping item %v of database %s which can't be decoded: %v", id, self.database_file, err)
+}
+
+// decodeItem is like peek but also returns the hidden items
+func (self *FileStoreHandler) decodeItem(id interface{}) (*resource.Item, bool, error) {
+	id = canonicalID(id)
+	if _, found := self.items[id]; !found {
+		return nil, false, nil
+	}
+	if item := self.cache.get(id); item != nil {
+		return item, true, nil
+	}
+	data, _, err := self.record(id)
+	if err != nil {
+		return nil, true, err
+	}
+	var item resource.Item
+	if err := decodeRecord(data, &item); err != nil {
+		return nil, true, err
+	}
+	// Stored before the ids were canonicalized
+	item.ID = id
+	if err := self.resolveBlobs(&item); err != nil {
+		return nil, true, err
+	}
+	self.addDerivedFields(&item)
+	if !self.offloading() {
+		self.cache.set(id, &item)
+	}
+	return &item, true, nil
+}
+
+// cloneItem deep copies an item, including the maps and slices of its payload.
+//
+// It enforces the ownership of the items: the decoded items held by the cache
+// are shared by all the readers and never handed out. Every item returned by
+// the handler, from Find, MultiGet or the other reads, is a copy made by
+// cloneItem (fetch or scan) which the caller owns and may modify, and the
+// items given to the writes are encoded right away and not retained. Only the
+// items of the events are shared, see Event, and the items returned with
+// ReadOnlyResults.
+func cloneItem(item *resource.Item) *resource.Item {
+	if item == nil {
+		return nil
+	}
+	clone := *item
+	if item.Payload != nil {
+		clone.Payload = copyValue(item.Payload).(map[string]interface{})
+	}
+	return &clone
+}
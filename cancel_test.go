@@ -0,0 +1,35 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestCancelScan(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	items := []*resource.Item{}
+	for i := 0; i < 1000; i++ {
+		items = append(items, mkitem(fmt.Sprint(i), map[string]interface{}{"n": i}))
+	}
+	if err := h.Insert(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := h.Find(cctx, resource.NewLookup(), 1, -1); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if _, err := h.Clear(cctx, resource.NewLookup()); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if _, err := h.Count(cctx, resource.NewLookup()); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if n, _ := h.Count(ctx, resource.NewLookup()); n != 1000 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,23 @@
+package filestore
+
+import "strings"
+
+// foldValue returns the value a string of the CaseInsensitiveUniqueFields is
+// compared by: lowercased with the surrounding spaces trimmed. Other values
+// are returned as is.
+func foldValue(value interface{}) interface{} {
+	if s, ok := value.(string); ok {
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+	return value
+}
+
+// caseInsensitive tells if field is one of the CaseInsensitiveUniqueFields
+func (self *FileStoreHandler) caseInsensitive(field string) bool {
+	for _, f := range self.CaseInsensitiveUniqueFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
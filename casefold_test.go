@@ -0,0 +1,50 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestCaseInsensitiveUnique(t *testing.T) {
+	ctx := context.Background()
+	for _, fold := range []bool{false, true} {
+		d := tmpdir(t)
+		opts := []Option{WithUniqueFields("email")}
+		if fold {
+			opts = append(opts, WithCaseInsensitiveUniqueFields("email"))
+		}
+		h, err := NewHandlerWithOptions(d, "c", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"email": "Foo@x.com"})}); err != nil {
+			t.Fatal(err)
+		}
+		err = h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"email": " foo@X.com"})})
+		if fold && err == nil || !fold && err != nil {
+			t.Fatal(fold, err)
+		}
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.Equal{Field: "email", Value: "foo@x.com"}})
+		res, err := h.Find(ctx, l, 1, -1)
+		if err != nil || res.Total != 0 {
+			t.Fatal(res, err)
+		}
+		h.Close()
+	}
+	h := NewMemoryHandler(0)
+	h.UniqueFields = []string{"email"}
+	h.CaseInsensitiveUniqueFields = []string{"email"}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"email": "A"})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"email": "a"})}); err == nil {
+		t.Fatal("no conflict")
+	}
+	if _, err := NewHandlerWithOptions(tmpdir(t), "c", WithCaseInsensitiveUniqueFields("email")); err == nil {
+		t.Fatal("not validated")
+	}
+}
@@ -0,0 +1,167 @@
+package filestore
+
+import (
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// DefaultChangeLogSize is the number of changes retained for ChangesSince when
+// ChangeLogSize isn't set
+const DefaultChangeLogSize = 1000
+
+// ErrResyncRequired is returned by ChangesSince when the changes following the
+// requested version are no longer retained
+var ErrResyncRequired = &rest.Error{Code: 410, Message: "Changes no longer available, a full resync is required"}
+
+// ChangeOp is the kind of mutation of a Change
+type ChangeOp int
+
+const (
+	ChangeInsert ChangeOp = iota + 1
+	ChangeUpdate
+	ChangeDelete
+)
+
+// Change is a mutation of the collection
+type Change struct {
+	// Version is the version of the collection right after the change
+	Version uint64
+	Op      ChangeOp
+	ID      interface{}
+	// Item is the item as stored by the change, nil for a ChangeDelete
+	Item *resource.Item
+}
+
+// changeEntry is a retained change, the item is kept encoded along with the
+// keys of the blobs it references, see logBlobs
+type changeEntry struct {
+	version uint64
+	op      ChangeOp
+	id      interface{}
+	record  []byte
+	blobs   []string
+}
+
+// changeLog tracks the version of the collection and retains its last
+// changes. Versions start at the creation time of the handler in nanoseconds
+// so they keep increasing across restarts and a replica can't mistake the
+// changes of a new handler process for the ones it missed.
+type changeLog struct {
+	version uint64
+	// floor is the oldest version changes can be served from
+	floor   uint64
+	entries []changeEntry
+}
+
+func newChangeLog() changeLog {
+	version := uint64(time.Now().UnixNano())
+	return changeLog{version: version, floor: version}
+}
+
+// recordChange bumps the version and retains the change
+func (self *FileStoreHandler) recordChange(op ChangeOp, id interface{}, record []byte) {
+	self.changes.version++
+	size := self.ChangeLogSize
+	if size == 0 {
+		size = DefaultChangeLogSize
+	}
+	if size < 0 {
+		self.changes.floor = self.changes.version
+		return
+	}
+	entry := changeEntry{version: self.changes.version, op: op, id: id, record: record}
+	if record != nil {
+		// record was just set, see refBlobs
+		entry.blobs = self.blobs.keys[id]
+		self.logBlobs(entry.blobs)
+	}
+	self.changes.entries = append(self.changes.entries, entry)
+	if drop := len(self.changes.entries) - size; drop > 0 {
+		for _, entry := range self.changes.entries[:drop] {
+			self.unlogBlobs(entry.blobs)
+		}
+		self.changes.floor = self.changes.entries[drop-1].version
+		self.changes.entries = append(self.changes.entries[:0], self.changes.entries[drop:]...)
+	}
+}
+
+// resetChanges bumps the version and forgets the retained changes, for when
+// the collection is changed in a way not represented by individual changes
+func (self *FileStoreHandler) resetChanges() {
+	self.changes.version++
+	self.changes.floor = self.changes.version
+	for _, entry := range self.changes.entries {
+		self.unlogBlobs(entry.blobs)
+	}
+	self.changes.entries = nil
+	// Nor by the write-ahead log
+	self.wal.pending = nil
+	self.wal.full = true
+}
+
+// Version returns the current version of the collection, incremented by
+// every change
+func (self *FileStoreHandler) Version() uint64 {
+	self.RLock()
+	defer self.RUnlock()
+	return self.changes.version
+}
+
+// ChangesSince calls fn with every change which happened after version, in
+// order, and returns the version of the last one (version itself if there was
+// none). A replica can poll it with the returned version to stay in sync.
+// Only the last ChangeLogSize changes are retained: when some of the changes
+// following version are gone, or when version comes from another handler
+// process, ErrResyncRequired is returned and the replica must resync the
+// whole collection. Iteration stops at the first error returned by fn. fn is
+// called without holding the handler's lock.
+func (self *FileStoreHandler) ChangesSince(ctx context.Context, version uint64, fn func(change Change) error) (newVersion uint64, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	var changes []Change
+	self.RLock()
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		if err := self.checkOpen(); err != nil {
+			return err
+		}
+		if version < self.changes.floor || version > self.changes.version {
+			return ErrResyncRequired
+		}
+		for _, entry := range self.changes.entries {
+			if entry.version <= version {
+				continue
+			}
+			change := Change{Version: entry.version, Op: entry.op, ID: entry.id}
+			if entry.record != nil {
+				// Decoded with the lock held, the blobs of the record
+				// may be removed once it is replaced
+				var item resource.Item
+				if err := decodeRecord(entry.record, &item); err != nil {
+					return err
+				}
+				if err := self.resolveBlobs(&item); err != nil {
+					return err
+				}
+				change.Item = self.present(&item)
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	self.RUnlock()
+	if err != nil {
+		return version, err
+	}
+
+	newVersion = version
+	for _, change := range changes {
+		if err := fn(change); err != nil {
+			return newVersion, err
+		}
+		newVersion = change.Version
+	}
+	return newVersion, nil
+}
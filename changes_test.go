@@ -0,0 +1,76 @@
+package filestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestChanges(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", nil)
+	h.ChangeLogSize = 2
+	ctx := context.Background()
+	v0 := h.Version()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})})
+	var got []Change
+	v1, err := h.ChangesSince(ctx, v0, func(c Change) error { got = append(got, c); return nil })
+	if err != nil || len(got) != 1 || got[0].Op != ChangeInsert || got[0].Item.ID != "a" || v1 != v0+1 {
+		t.Fatal(err, got)
+	}
+	h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{})})
+	h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{})})
+	if _, err := h.ChangesSince(ctx, v0, func(c Change) error { return nil }); err != ErrResyncRequired {
+		t.Fatal(err)
+	}
+	got = nil
+	if _, err := h.ChangesSince(ctx, v1, func(c Change) error { got = append(got, c); return nil }); err != nil || len(got) != 2 {
+		t.Fatal(err, got)
+	}
+}
+
+func TestChangesBlobs(t *testing.T) {
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithBlobThreshold(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	ctx := context.Background()
+	v0 := h.Version()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"b": []byte("large")})})
+	original, _ := h.Get(ctx, "a")
+	h.Update(ctx, mkitem("a", map[string]interface{}{"b": []byte("larger")}), original)
+	var got []string
+	if _, err := h.ChangesSince(ctx, v0, func(c Change) error {
+		b, ok := c.Item.Payload["b"].([]byte)
+		if !ok {
+			t.Fatalf("%T", c.Item.Payload["b"])
+		}
+		got = append(got, string(b))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "large" || got[1] != "larger" {
+		t.Fatal(got)
+	}
+	// The blob of the first change is removed once the change is dropped
+	old := h.blobFile(blobHash([]byte("large")))
+	if _, err := os.Stat(old); err != nil {
+		t.Fatal(err)
+	}
+	h.ChangeLogSize = 1
+	h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{})})
+	h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{})})
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
+
+func blobHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,31 @@
+package filestore
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestChecksum(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"id": "a", "v": "hello world"})})
+	h.Close()
+	data, _ := ioutil.ReadFile(h.database_file)
+	data[len(data)-3] ^= 0x40
+	ioutil.WriteFile(h.database_file, data, 0644)
+	_, err = NewHandlerWithOptions(d, "c")
+	if !errors.Is(err, ErrChecksum) {
+		t.Fatalf("got %v", err)
+	}
+	h2, err := NewHandlerWithOptions(d, "c", WithRecoverFromCorruption())
+	if err != nil || h2.Len() != 0 {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,131 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+type saveSpy struct{ saves int }
+
+func (s *saveSpy) Printf(format string, v ...interface{}) {
+	if strings.HasPrefix(format, "Saved database") {
+		s.saves++
+	}
+}
+
+func TestClearPersistsOnce(t *testing.T) {
+	spy := &saveSpy{}
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithDebugLog(spy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	ctx := context.Background()
+	var items []*resource.Item
+	for i := 0; i < 10; i++ {
+		items = append(items, mkitem(fmt.Sprint(i), map[string]interface{}{"odd": i%2 == 1}))
+	}
+	if err := h.Insert(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	spy.saves = 0
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "odd", Value: true}})
+	n, err := h.Clear(ctx, l)
+	if err != nil || n != 5 || spy.saves != 1 || h.idCount() != 5 {
+		t.Fatal(n, err, spy.saves)
+	}
+}
+
+// batchFS stalls the first datafile write once armed and fails the third one
+type batchFS struct {
+	OSFileSystem
+	armed, writes    int32
+	entered, release chan struct{}
+}
+
+func (f *batchFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if atomic.LoadInt32(&f.armed) == 1 && strings.HasSuffix(name, ".tmp") {
+		switch atomic.AddInt32(&f.writes, 1) {
+		case 1:
+			f.entered <- struct{}{}
+			<-f.release
+		case 3:
+			return errors.New("write failed")
+		}
+	}
+	return f.OSFileSystem.WriteFile(name, data, perm)
+}
+
+func TestClearBatches(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	fs := &batchFS{entered: make(chan struct{}), release: make(chan struct{})}
+	h, err := NewHandlerWithOptions(d, "c", WithFileSystem(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ClearBatchSize = 3
+	var items []*resource.Item
+	for i := 0; i < 9; i++ {
+		items = append(items, mkitem(i+1, map[string]interface{}{}))
+	}
+	if err := h.Insert(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&fs.armed, 1)
+	type result struct {
+		n   int
+		err error
+	}
+	cleared := make(chan result, 1)
+	go func() {
+		n, err := h.Clear(ctx, resource.NewLookup())
+		cleared <- result{n, err}
+	}()
+	// The first batch is being saved with the lock held, a read waits for it
+	<-fs.entered
+	seen := make(chan int, 1)
+	go func() {
+		n, _ := h.Count(ctx, resource.NewLookup())
+		seen <- n
+	}()
+	time.Sleep(20 * time.Millisecond)
+	fs.release <- struct{}{}
+	// The read runs in between the first two batches
+	if n := <-seen; n != 6 {
+		t.Fatal(n)
+	}
+	// The third batch can't be saved, its items are restored
+	if r := <-cleared; r.err == nil || r.n != 6 {
+		t.Fatal(r.n, r.err)
+	}
+	if h.Len() != 3 {
+		t.Fatal(h.Len())
+	}
+	for _, id := range []int{7, 8, 9} {
+		if _, err := h.Get(ctx, id); err != nil {
+			t.Fatal(id, err)
+		}
+	}
+	atomic.StoreInt32(&fs.armed, 0)
+	h.Close()
+	h, err = NewHandlerWithOptions(d, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if got := sortedIDs(t, h); !reflect.DeepEqual(got, []interface{}{7, 8, 9}) {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,24 @@
+package filestore
+
+import "time"
+
+// Clock tells the time to a handler, so the tests of the expiry and of the
+// timestamps can control it
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the Clock used when none is set
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// now returns the current time of the handler's Clock
+func (self *FileStoreHandler) now() time.Time {
+	if self.Clock == nil {
+		return realClock{}.Now()
+	}
+	return self.Clock.Now()
+}
@@ -0,0 +1,33 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+func TestFakeClockTTL(t *testing.T) {
+	c := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithClock(c), WithTTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	ctx := context.Background()
+	it := mkitem("a", map[string]interface{}{"x": 1})
+	it.Updated = c.t
+	h.Insert(ctx, []*resource.Item{it})
+	if n, _ := h.Count(ctx, resource.NewLookup()); n != 1 {
+		t.Fatal(n)
+	}
+	c.t = c.t.Add(2 * time.Hour)
+	if n, _ := h.Count(ctx, resource.NewLookup()); n != 0 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,74 @@
+package filestore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestClose(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	ctx := context.Background()
+	h.BeginBulkLoad()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"id": "a"})})
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"id": "b"})}); err != ErrClosed {
+		t.Fatal(err)
+	}
+	h2 := newH(t, d, "c", nil)
+	if n, _ := h2.Count(ctx, resource.NewLookup()); n != 1 {
+		t.Fatal(n)
+	}
+}
+
+func TestClosedReads(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", nil)
+	ctx := context.Background()
+	item := mkitem("a", map[string]interface{}{})
+	h.Insert(ctx, []*resource.Item{item})
+	h.Close()
+	l := resource.NewLookup()
+	var into map[string]map[string]interface{}
+	var buf bytes.Buffer
+	errs := map[string]error{}
+	_, errs["find"] = h.Find(ctx, l, 1, 10)
+	_, errs["get"] = h.Get(ctx, "a")
+	_, errs["multiget"] = h.MultiGet(ctx, []interface{}{"a"})
+	_, errs["count"] = h.Count(ctx, l)
+	_, errs["aggregate"] = h.Aggregate(ctx, l, "x")
+	_, errs["distinct"] = h.Distinct(ctx, "x", l)
+	_, errs["offset"] = h.FindWithOffset(ctx, l, 0, 10)
+	_, errs["fields"] = h.FindFields(ctx, l, []string{"id"}, 1, 10)
+	_, errs["duplicates"] = h.FindDuplicates(ctx, "x")
+	_, errs["snapshot"] = h.Snapshot(ctx)
+	_, errs["validate"] = h.ValidateInsert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{})})
+	_, errs["verify"] = h.Verify(ctx)
+	_, errs["changes"] = h.ChangesSince(ctx, 0, func(Change) error { return nil })
+	_, errs["writeto"] = h.WriteTo(&buf)
+	_, errs["deleted"] = h.FindDeleted(ctx, l, 1, 10)
+	errs["each"] = h.FindEach(ctx, l, func(*resource.Item) error { return nil })
+	errs["export"] = h.Export(ctx, &buf)
+	errs["decode"] = h.Decode(ctx, &into)
+	errs["warm"] = h.Warm(ctx)
+	errs["reindex"] = h.Reindex(ctx)
+	errs["reload"] = h.Reload(ctx)
+	errs["update"] = h.Update(ctx, item, item)
+	errs["delete"] = h.Delete(ctx, item)
+	_, errs["clear"] = h.Clear(ctx, l)
+	for op, err := range errs {
+		if err != ErrClosed {
+			t.Error(op, err)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Fatal(buf.String())
+	}
+}
@@ -0,0 +1,153 @@
+package filestore
+
+import (
+	"sync"
+	"time"
+)
+
+// With CoalesceWrites set, a write doesn't save the datafile itself: it joins
+// the batch of writes waiting for the next save and waits for it without
+// holding the handler's lock. A single flusher saves the datafile for a whole
+// batch: it encodes the collection under the lock, then writes it without the
+// lock so the next writes can update the memory and form the next batch in
+// the meantime. Under concurrent writes, the datafile is written about once
+// per write duration instead of once per write.
+//
+// The readers may see a write before it is saved. All the writes of a batch
+// fail if its save fails and undo their own changes, see undo.go: an item
+// changed again by another write in the meantime keeps that change.
+// CoalesceWrites is ignored with WAL, FlushInterval, MergeOnSave or
+// PerItemFiles and by the memory handlers.
+
+// flushBatch is a group of writes persisted by the same save
+type flushBatch struct {
+	// done is closed once the save is done, err is its error
+	done chan struct{}
+	err  error
+	// back counts the writes of the batch which didn't get the lock back
+	back sync.WaitGroup
+}
+
+// coalescer tracks the batches of writes
+type coalescer struct {
+	// next is the batch the writes join, nil if there's none
+	next *flushBatch
+	// flushing is set while the flusher runs
+	flushing bool
+}
+
+// coalescing tells if the writes are persisted by the flusher
+func (self *FileStoreHandler) coalescing() bool {
+	return self.CoalesceWrites && !self.inMemory() && !self.PerItemFiles && !self.MergeOnSave
+}
+
+// coalesce joins the next batch of writes to save and waits for its save. It
+// must be called with the write lock held, which is released while waiting.
+func (self *FileStoreHandler) coalesce() error {
+	self.dirty = true
+	b := self.coalescer.next
+	if b == nil {
+		b = &flushBatch{done: make(chan struct{})}
+		self.coalescer.next = b
+	}
+	if !self.coalescer.flushing {
+		self.coalescer.flushing = true
+		go self.flushBatches()
+	}
+	b.back.Add(1)
+	self.Unlock()
+	<-b.done
+	self.Lock()
+	b.back.Done()
+	return b.err
+}
+
+// flushBatches saves the batches of writes until no write is waiting
+func (self *FileStoreHandler) flushBatches() {
+	self.Lock()
+	defer self.Unlock()
+	for self.coalescer.next != nil {
+		if self.groupSyncing() {
+			// Let the writes arriving within the window join the batch
+			self.Unlock()
+			time.Sleep(self.SyncBatchWindow)
+			self.Lock()
+		}
+		b := self.coalescer.next
+		self.coalescer.next = nil
+		b.err = self.flushBatch()
+		close(b.done)
+		if b.err != nil {
+			// Let the writes of the batch undo their changes before the
+			// next batch is saved, they do it before releasing the lock
+			self.Unlock()
+			b.back.Wait()
+			self.Lock()
+		}
+	}
+	self.coalescer.flushing = false
+}
+
+// flushBatch saves the datafile for the writes of a batch. It is called with
+// the write lock held, which is released while the datafile is written.
+func (self *FileStoreHandler) flushBatch() error {
+	if self.closed {
+		// Saved by Shutdown
+		if self.dirty {
+			return ErrClosed
+		}
+		return nil
+	}
+	start := time.Now()
+	data, err := self.encodeDatafile()
+	if err != nil {
+		return err
+	}
+	self.saveGen++
+	gen, published := self.saveGen, self.eventSeq
+	self.Unlock()
+	err = self.writeDatafile(gen, data)
+	self.Lock()
+	if err != nil {
+		return err
+	}
+	self.stampDatafile()
+	self.removeGarbageBlobs()
+	self.lastSave = self.now()
+	if self.saveGen == gen && self.coalescer.next == nil {
+		// Nothing changed while the datafile was written
+		self.saveIndexes(data)
+		self.dirty = false
+	}
+	// Only publish the events of the saved writes, the events of the writes
+	// made in the meantime wait for the next save
+	self.publishUpTo(published)
+	self.debugFields("Saved database", map[string]interface{}{
+		"items":    self.idCount(),
+		"bytes":    len(data),
+		"duration": time.Since(start),
+	})
+	return nil
+}
+
+// writeDatafile writes data, the datafile content encoded by the save number
+// gen, unless the content of a later save was already written. The backups
+// are rotated first, see Backups.
+func (self *FileStoreHandler) writeDatafile(gen uint64, data []byte) error {
+	self.writeMu.Lock()
+	defer self.writeMu.Unlock()
+	if gen < self.writtenGen {
+		return nil
+	}
+	if err := self.rotateBackups(data); err != nil {
+		return err
+	}
+	err := self.retryIO(func() error {
+		return writeFileAtomicFS(self.fileSystem(), self.database_file, data, self.fileMode(), self.Durable)
+	})
+	if err == nil {
+		self.writtenGen = gen
+		self.setFileSize(data)
+	}
+	return err
+}
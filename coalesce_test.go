@@ -0,0 +1,134 @@
+package filestore
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type slowFS struct {
+	OSFileSystem
+	writes int64
+}
+
+func (f *slowFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	atomic.AddInt64(&f.writes, 1)
+	time.Sleep(2 * time.Millisecond)
+	return f.OSFileSystem.WriteFile(name, data, perm)
+}
+
+// stallFS fails the writes made while failing is set, once released
+type stallFS struct {
+	OSFileSystem
+	failing int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (f *stallFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if atomic.LoadInt32(&f.failing) == 1 {
+		f.entered <- struct{}{}
+		<-f.release
+		return errors.New("write failed")
+	}
+	return f.OSFileSystem.WriteFile(name, data, perm)
+}
+
+func TestCoalesceFailedBatch(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	fs := &stallFS{failing: 1, entered: make(chan struct{}), release: make(chan struct{})}
+	h, err := NewHandlerWithOptions(d, "c", WithCoalesceWrites(), WithFileSystem(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := h.Subscribe()
+	errs := make(chan error, 2)
+	go func() { errs <- h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})}) }()
+	// The save of a is being written without the lock
+	<-fs.entered
+	atomic.StoreInt32(&fs.failing, 0)
+	go func() { errs <- h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{})}) }()
+	for h.Len() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(fs.release)
+	failed := 0
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Fatal("failed", failed)
+	}
+	// The undo of a keeps b
+	if ids := h.IDs(); len(ids) != 1 || ids[0] != "b" {
+		t.Fatal(ids)
+	}
+	if _, err := h.Get(ctx, "a"); err != resource.ErrNotFound {
+		t.Fatal(err)
+	}
+	if e := <-events; e.ID != "b" || e.Op != ChangeInsert {
+		t.Fatal(e)
+	}
+	select {
+	case e := <-events:
+		t.Fatal("unexpected event", e)
+	default:
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	h, _ = NewHandler(d, "c", nil)
+	defer h.Close()
+	if ids := h.IDs(); len(ids) != 1 || ids[0] != "b" {
+		t.Fatal(ids)
+	}
+}
+
+func TestCoalesceWrites(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	fs := &slowFS{}
+	h, err := NewHandlerWithOptions(d, "c", WithCoalesceWrites(), WithFileSystem(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := h.Subscribe()
+	const n = 200
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := h.Insert(ctx, []*resource.Item{mkitem(i+1, map[string]interface{}{})}); err != nil {
+				t.Error(err)
+			}
+			if i%10 == 0 {
+				h.Find(ctx, resource.NewLookup(), 1, 5)
+			}
+		}(i)
+	}
+	wg.Wait()
+	writes := atomic.LoadInt64(&fs.writes)
+	t.Logf("%d writes for %d inserts", writes, n)
+	if writes > n/4 {
+		t.Fatal(writes)
+	}
+	if len(events) != n {
+		t.Fatal("events", len(events))
+	}
+	h.Close()
+	h, _ = NewHandler(d, "c", nil)
+	defer h.Close()
+	if h.Len() != n {
+		t.Fatal(h.Len())
+	}
+}
@@ -0,0 +1,179 @@
+package filestore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Codec serializes the items stored by the handler. The codec used to write a
+// record is recorded with it so it is always read back with the right Codec,
+// see RegisterCodec.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// GobCodec is a Codec using encoding/gob, the default
+type GobCodec struct{}
+
+// JSONCodec is a Codec using encoding/json. The datafile of a handler using
+// it is a human readable JSON array of the items, but JSON doesn't keep Go
+// types: numbers, including numeric ids, are read back as float64 and times
+// in payloads as strings.
+type JSONCodec struct{}
+
+// Tags of the codecs shipped with the package. Untagged records written by
+// older versions are decoded as gob.
+const (
+	CodecGob  byte = 0x80
+	CodecJSON byte = 0x81
+)
+
+var (
+	codecs    = map[byte]Codec{}
+	codecTags = map[reflect.Type]byte{}
+)
+
+func init() {
+	RegisterCodec(CodecGob, GobCodec{})
+	RegisterCodec(CodecJSON, JSONCodec{})
+}
+
+// RegisterCodec makes a Codec available under tag. The tag is written at the
+// start of every record to find the Codec to read it with, it must be in the
+// 0x80-0xf7 range which can never start a gob stream so untagged legacy
+// records are still recognized. Codecs are identified by their type, the
+// value given here is the one used to decode. It panics if the tag is invalid
+// or already in use, and must be called before any handler using it is
+// created.
+func RegisterCodec(tag byte, c Codec) {
+	if tag < 0x80 || tag > 0xf7 {
+		panic(fmt.Sprintf("filestore: invalid codec tag %#x", tag))
+	}
+	if _, found := codecs[tag]; found {
+		panic(fmt.Sprintf("filestore: codec tag %#x already registered", tag))
+	}
+	codecs[tag] = c
+	codecTags[reflect.TypeOf(c)] = tag
+}
+
+// codecTag returns the tag c was registered with
+func codecTag(c Codec) (byte, error) {
+	tag, found := codecTags[reflect.TypeOf(c)]
+	if !found {
+		return 0, fmt.Errorf("filestore: codec %T isn't registered", c)
+	}
+	return tag, nil
+}
+
+// codec returns the codec the handler writes its records with
+func (self *FileStoreHandler) codec() Codec {
+	if self.Codec == nil {
+		return GobCodec{}
+	}
+	return self.Codec
+}
+
+var registerGobTypes sync.Once
+
+// gobTypes registers the concrete types found in payloads and indexes, it is
+// only done once gob is used.
+//
+// With gob, the payloads can hold nil, the bools, numbers and strings, []byte,
+// time.Time, the maps and slices of these types registered below and the
+// types registered with RegisterType or gob.Register. They are read back with their Go type,
+// except for an empty []byte which is read back as nil and the times which
+// keep their instant and offset but lose their monotonic clock reading and
+// location name. Other types fail to encode.
+func gobTypes() {
+	registerGobTypes.Do(func() {
+		gob.Register(map[string]interface{}{})
+		gob.Register([]interface{}{})
+		gob.Register(time.Time{})
+		gob.Register(compositeKey{})
+		gob.Register(blobRef{})
+		gob.Register([]map[string]interface{}{})
+		gob.Register(map[string]string{})
+		gob.Register([]string{})
+		gob.Register([]int{})
+		gob.Register([]int64{})
+		gob.Register([]float64{})
+		gob.Register([]bool{})
+	})
+}
+
+// RegisterType registers the concrete type of value with gob, so the
+// payloads can hold values of this type, like a custom struct or enum, and
+// read them back with it. It wraps gob.Register and panics like it if another
+// type was registered under the same name. The types must be registered
+// before the handlers storing or loading them are created, a record holding
+// an unregistered type can't be written and one read back fails to decode.
+func RegisterType(value interface{}) {
+	gob.Register(value)
+}
+
+// bufferPool recycles the buffers used by GobCodec
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer is the capacity above which a buffer isn't returned to the
+// pool so a single datafile sized encode doesn't stay in memory forever
+const maxPooledBuffer = 64 << 10
+
+// readerPool recycles the readers used by GobCodec
+var readerPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Reader) },
+}
+
+// Marshal implements Codec
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	gobTypes()
+	data := bufferPool.Get().(*bytes.Buffer)
+	data.Reset()
+	defer func() {
+		if data.Cap() <= maxPooledBuffer {
+			bufferPool.Put(data)
+		}
+	}()
+	if err := gob.NewEncoder(data).Encode(v); err != nil {
+		return nil, err
+	}
+	// The buffer is reused, hand out a copy of its content
+	encoded := make([]byte, data.Len())
+	copy(encoded, data.Bytes())
+	return encoded, nil
+}
+
+// Unmarshal implements Codec
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	gobTypes()
+	reader := readerPool.Get().(*bytes.Reader)
+	reader.Reset(data)
+	defer func() {
+		// Don't keep the record referenced from the pool
+		reader.Reset(nil)
+		readerPool.Put(reader)
+	}()
+	return gob.NewDecoder(reader).Decode(v)
+}
+
+// Marshal implements Codec
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements Codec
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// gobDecode decodes data with GobCodec
+func gobDecode(data []byte, v interface{}) error {
+	return GobCodec{}.Unmarshal(data, v)
+}
@@ -0,0 +1,120 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestJSONCodec(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"id": "a", "name": "gobby"})}); err != nil {
+		t.Fatal(err)
+	}
+	h.Codec = JSONCodec{}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"id": "b", "name": "jsonny"})}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(d + "/c")
+	_, b, _ = splitHeader(b)
+	if b[0] != datafileCollection || !strings.Contains(string(b), `"jsonny"`) || !strings.Contains(string(b), `"gobby"`) {
+		t.Fatal(string(b))
+	}
+	h2 := newH(t, d, "c", nil)
+	l, err := h2.Find(ctx, resource.NewLookup(), 1, -1)
+	if err != nil || l.Total != 2 || l.Items[0].ID != "a" || l.Items[1].Payload["name"] != "jsonny" {
+		t.Fatal(l, err)
+	}
+	// back to gob
+	if err := h2.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{"id": "c"})}); err != nil {
+		t.Fatal(err)
+	}
+	h3 := newH(t, d, "c", nil)
+	if l, _ := h3.Find(ctx, resource.NewLookup(), 1, -1); l.Total != 3 {
+		t.Fatal(l)
+	}
+}
+
+func TestCodecMixedRecords(t *testing.T) {
+	d := tmpdir(t)
+	ctx := context.Background()
+	h := newH(t, d, "c", nil)
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"name": "gob"})}); err != nil {
+		t.Fatal(err)
+	}
+	// A record written before the records were tagged and one written with
+	// another codec, kept as they are by the gob datafiles
+	legacy, err := GobCodec{}.Marshal(mkitem("b", map[string]interface{}{"name": "legacy"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged, err := encodeRecordWith(JSONCodec{}, mkitem("c", map[string]interface{}{"name": "json"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Lock()
+	h.setRecord("b", legacy)
+	h.appendID("b")
+	h.setRecord("c", tagged)
+	h.appendID("c")
+	err = h.saveDatafile()
+	h.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(h *FileStoreHandler) map[interface{}]interface{} {
+		t.Helper()
+		l, err := h.Find(ctx, resource.NewLookup(), 1, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := map[interface{}]interface{}{}
+		for _, item := range l.Items {
+			names[item.ID] = item.Payload["name"]
+		}
+		return names
+	}
+	want := map[interface{}]interface{}{"a": "gob", "b": "legacy", "c": "json"}
+	h = newH(t, d, "c", nil)
+	if got := names(h); !reflect.DeepEqual(got, want) {
+		t.Fatal(got)
+	}
+	if h.items["a"][0] != CodecGob || h.items["b"][0] >= 0x80 || h.items["c"][0] != CodecJSON {
+		t.Fatal("records rewritten")
+	}
+
+	// Switching to JSON rewrites the datafile as a JSON collection
+	h.Codec = JSONCodec{}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("d", map[string]interface{}{"name": "d"})}); err != nil {
+		t.Fatal(err)
+	}
+	want["d"] = "d"
+	h = newH(t, d, "c", nil)
+	h.Codec = JSONCodec{}
+	if got := names(h); !reflect.DeepEqual(got, want) {
+		t.Fatal(got)
+	}
+	// And back to gob
+	h.Codec = nil
+	original, err := h.Get(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Update(ctx, mkitem("b", map[string]interface{}{"name": "updated"}), original); err != nil {
+		t.Fatal(err)
+	}
+	want["b"] = "updated"
+	h = newH(t, d, "c", nil)
+	if got := names(h); !reflect.DeepEqual(got, want) {
+		t.Fatal(got)
+	}
+	if h.items["b"][0] != CodecGob || h.items["d"][0] != CodecJSON {
+		t.Fatal("records not kept")
+	}
+}
@@ -0,0 +1,84 @@
+package filestore
+
+import "golang.org/x/net/context"
+
+// CompactResult reports the effect of Compact
+type CompactResult struct {
+	// Removed is the number of expired items removed
+	Removed int
+	// Reclaimed is the number of bytes the datafile shrank by, or the
+	// stored records for a memory handler
+	Reclaimed int64
+}
+
+// Compact removes the expired items, re-encodes all the records with the
+// current codec, rebuilds the indexes and the memory structures from
+// scratch and rewrites the datafile. The soft deleted items are kept, see
+// Purge. The handler is locked for the whole compaction, which is left
+// undone if ctx is canceled before the structures are replaced.
+func (self *FileStoreHandler) Compact(ctx context.Context) (result CompactResult, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return result, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		ids := make([]interface{}, 0, len(self.ids))
+		records := make([][]byte, 0, len(self.ids))
+		var expired []interface{}
+		for i, id := range self.liveIDs() {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			item, _, err := self.decodeItem(id)
+			if err != nil {
+				return err
+			}
+			if self.expired(item) {
+				expired = append(expired, id)
+				continue
+			}
+			record, err := self.encodeRecord(item)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+			records = append(records, record)
+		}
+
+		before := self.fileStamp.size
+		if self.inMemory() {
+			before = self.memoryBytes
+		}
+		for _, id := range expired {
+			self.recordChange(ChangeDelete, id, nil)
+			old, _ := self.eventRecord(id)
+			self.notify(ChangeDelete, id, nil, old)
+		}
+		for id := range self.items {
+			self.removeRecord(id)
+		}
+		// A new map releases the buckets of the removed items
+		self.items = make(map[interface{}][]byte, len(ids))
+		for i, id := range ids {
+			self.setRecord(id, records[i])
+		}
+		self.setIDs(ids)
+		if err := self.rebuildIndexes(); err != nil {
+			return err
+		}
+		result.Removed = len(expired)
+		if err := self.saveDatafile(); err != nil {
+			return err
+		}
+		if self.inMemory() {
+			result.Reclaimed = before - self.memoryBytes
+		} else {
+			result.Reclaimed = before - self.fileStamp.size
+		}
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,41 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestCompact(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", []string{"u"})
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		it := mkitem(fmt.Sprint(i), map[string]interface{}{"u": i})
+		if i < 4 {
+			it.Updated = time.Now().Add(-time.Hour)
+		}
+		if err := h.Insert(ctx, []*resource.Item{it}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.TTL = time.Minute
+	r, err := h.Compact(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Removed != 4 || r.Reclaimed <= 0 {
+		t.Fatalf("%+v", r)
+	}
+	h.TTL = 0
+	h = newH(t, d, "c", []string{"u"})
+	if n := h.idCount(); n != 6 {
+		t.Fatal(n)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("x", map[string]interface{}{"u": 5})}); err == nil {
+		t.Fatal("index lost")
+	}
+}
@@ -0,0 +1,121 @@
+package filestore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+)
+
+// Compressor compresses the datafile. The algorithm used to write a datafile
+// is recorded in it so it is always read back with the right Compressor, see
+// RegisterCompressor.
+type Compressor interface {
+	Compress(data []byte) []byte
+	Decompress(data []byte) ([]byte, error)
+}
+
+// GzipCompressor is a Compressor using gzip, the default when compression is
+// enabled
+type GzipCompressor struct {
+	// Level is the gzip compression level, gzip.DefaultCompression if zero
+	Level int
+}
+
+// Compress implements Compressor
+func (c GzipCompressor) Compress(data []byte) []byte {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		w = gzip.NewWriter(&buf)
+	}
+	// Writes to a bytes.Buffer can't fail
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// Decompress implements Compressor
+func (c GzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// CompressionGzip is the tag of GzipCompressor
+const CompressionGzip byte = 0xa0
+
+var (
+	compressors    = map[byte]Compressor{}
+	compressorTags = map[reflect.Type]byte{}
+)
+
+func init() {
+	RegisterCompressor(CompressionGzip, GzipCompressor{})
+}
+
+// RegisterCompressor makes a Compressor available under tag. The tag is
+// written at the start of the compressed datafiles to find the Compressor to
+// read them with. Like the record format tags, it must be in the 0x80-0xf7
+// range so an uncompressed datafile is still recognized. Compressors are
+// identified by their type, the value given here is the one used to
+// decompress. It panics if the tag is invalid or already in use, and must be
+// called before any handler using it is created.
+func RegisterCompressor(tag byte, c Compressor) {
+	if tag < 0x80 || tag > 0xf7 {
+		panic(fmt.Sprintf("filestore: invalid compressor tag %#x", tag))
+	}
+	if tag == datafileOrdered || tag == datafileItems || tag == datafileCollection || tag == datafileEncrypted {
+		panic(fmt.Sprintf("filestore: compressor tag %#x is reserved", tag))
+	}
+	if _, found := compressors[tag]; found {
+		panic(fmt.Sprintf("filestore: compressor tag %#x already registered", tag))
+	}
+	compressors[tag] = c
+	compressorTags[reflect.TypeOf(c)] = tag
+}
+
+// compress compresses data with c and prefixes it with c's tag
+func compress(c Compressor, data []byte) ([]byte, error) {
+	tag, found := compressorTags[reflect.TypeOf(c)]
+	if !found {
+		return nil, fmt.Errorf("filestore: compressor %T isn't registered", c)
+	}
+	return append([]byte{tag}, c.Compress(data)...), nil
+}
+
+// gzipMagic starts any gzip stream. It can't start a gob stream either as
+// 0x8b is an invalid gob length prefix.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress decompresses data if it starts with a compressor tag or is a
+// bare gzip stream, like a datafile compressed by an external tool, and
+// returns it as is otherwise
+func decompress(data []byte) ([]byte, error) {
+	if len(data) > 0 {
+		if c, found := compressors[data[0]]; found {
+			return c.Decompress(data[1:])
+		}
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		return GzipCompressor{}.Decompress(data)
+	}
+	return data, nil
+}
+
+// compressor returns the Compressor the datafile is written with, nil if it
+// isn't compressed
+func (self *FileStoreHandler) compressor() Compressor {
+	if self.Compressor == nil && self.Compress {
+		return GzipCompressor{}
+	}
+	return self.Compressor
+}
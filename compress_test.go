@@ -0,0 +1,50 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestCompress(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	h.Compressor = GzipCompressor{}
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"name": "x"})}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(d + "/c")
+	_, b, _ = splitHeader(b)
+	if b[0] != CompressionGzip || b[1] != 0x1f {
+		t.Fatal(b[:3])
+	}
+	h2 := newH(t, d, "c", nil)
+	if l, _ := h2.Find(ctx, resource.NewLookup(), 1, -1); l.Total != 1 {
+		t.Fatal(l)
+	}
+}
+
+func TestCompressBool(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	h.Compress = true
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"name": "x"})}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(d + "/c")
+	_, b, _ = splitHeader(b)
+	if b[0] != CompressionGzip {
+		t.Fatal(b[:3])
+	}
+	// bare gzip file
+	plain, _ := decompress(b)
+	ioutil.WriteFile(d+"/c", GzipCompressor{}.Compress(plain), 0644)
+	h2 := newH(t, d, "c", nil)
+	if l, _ := h2.Find(ctx, resource.NewLookup(), 1, -1); l.Total != 1 || l.Items[0].Payload["name"] != "x" {
+		t.Fatal(l)
+	}
+}
@@ -0,0 +1,104 @@
+package filestore
+
+import (
+	"errors"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// CopyFrom inserts all the items of src in the handler, preserving their ids
+// and ETags, and persists them at once. It returns the number of items
+// copied. An item with the id of an item of the handler is a
+// resource.ErrConflict unless overwrite is set, in which case it replaces it.
+// Nothing is copied if an item conflicts, fails the unique checks or if ctx is
+// canceled before the copy is stored. The expired and soft deleted items of
+// src aren't copied.
+func (self *FileStoreHandler) CopyFrom(ctx context.Context, src *FileStoreHandler, overwrite bool) (copied int, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	if src == self {
+		return 0, errors.New("filestore: can't copy a handler into itself")
+	}
+	items, err := src.copyItems(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return 0, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		staged := make([]*resource.Item, len(items))
+		records := make([][]byte, len(items))
+		for i, item := range items {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			if _, found, _ := self.peek(item.ID); found && !overwrite {
+				return resource.ErrConflict
+			}
+			invalid, err := self.checkUnique(ctx, item)
+			if err != nil {
+				return err
+			}
+			if invalid != nil {
+				return invalid
+			}
+			if staged[i], records[i], err = self.encode(item); err != nil {
+				return err
+			}
+		}
+		added, grow, keep, err := self.checkBatch(staged, records)
+		if err != nil {
+			return err
+		}
+		if err := self.makeRoom(added, grow, keep); err != nil {
+			return err
+		}
+
+		for i, item := range staged {
+			if _, visible, _ := self.peek(item.ID); !visible {
+				if _, found := self.items[item.ID]; found {
+					// Replaces an expired or soft deleted item
+					self.delete(item.ID)
+				}
+				self.appendID(item.ID)
+			}
+			self.storeRecord(item, records[i])
+		}
+		copied = len(staged)
+		return self.persistData()
+	})
+	if err != nil {
+		return 0, err
+	}
+	count(&self.counters.inserts, copied)
+	return copied, nil
+}
+
+// copyItems returns a copy of the visible items in order, holding the read
+// lock
+func (self *FileStoreHandler) copyItems(ctx context.Context) ([]*resource.Item, error) {
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	items := make([]*resource.Item, 0, self.idCount())
+	for i, id := range self.liveIDs() {
+		if err := checkCanceled(ctx, i); err != nil {
+			return nil, err
+		}
+		item, found, err := self.fetch(id)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,40 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestCopyFrom(t *testing.T) {
+	ctx := context.Background()
+	src := newH(t, tmpdir(t), "src", nil)
+	dst := newH(t, tmpdir(t), "dst", []string{"name"})
+	if err := src.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"name": "a"}), mkitem("b", map[string]interface{}{"name": "b"})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"name": "old"})}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := dst.CopyFrom(ctx, src, false); err != resource.ErrConflict || n != 0 || dst.idCount() != 1 {
+		t.Fatal(n, err)
+	}
+	n, err := dst.CopyFrom(ctx, src, true)
+	if err != nil || n != 2 || dst.idCount() != 2 {
+		t.Fatal(n, err)
+	}
+	s, _ := src.Get(ctx, "a")
+	d, _ := dst.Get(ctx, "a")
+	if s.ETag != d.ETag {
+		t.Fatal(s, d)
+	}
+	if _, err := dst.CopyFrom(ctx, dst, true); err == nil {
+		t.Fatal("self copy")
+	}
+	c, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := newH(t, tmpdir(t), "x", nil).CopyFrom(c, src, false); err == nil {
+		t.Fatal("not canceled")
+	}
+}
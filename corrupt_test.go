@@ -0,0 +1,26 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestCorruptRecordError(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	a := mkitem("a", map[string]interface{}{})
+	h.Insert(ctx, []*resource.Item{a})
+	h.items["a"] = []byte{0x80, 1, 2, 3}
+	h.cache.invalidate("a")
+	if err := h.Update(ctx, mkitem("a", map[string]interface{}{}), a); err == nil || err == resource.ErrNotFound {
+		t.Fatal(err)
+	}
+	if err := h.Delete(ctx, a); err == nil || err == resource.ErrNotFound {
+		t.Fatal(err)
+	}
+	if _, err := h.Merge(ctx, "a", map[string]interface{}{}, ""); err == nil || err == resource.ErrNotFound {
+		t.Fatal(err)
+	}
+}
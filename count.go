@@ -0,0 +1,52 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// Count returns the number of items matching the lookup without building,
+// sorting or copying the result set
+func (self *FileStoreHandler) Count(ctx context.Context, lookup *resource.Lookup) (total int, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return 0, err
+	}
+	if err := self.checkLookup(lookup); err != nil {
+		return 0, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		if ids, ok := self.indexCandidates(lookup); ok {
+			total = len(ids)
+			return nil
+		}
+		ids, ok := self.rangeCandidates(lookup)
+		if !ok {
+			if err := self.checkFullScan(lookup); err != nil {
+				return err
+			}
+			ids = self.ids
+		}
+		for i, id := range ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			item, found, err := self.peekValid(id)
+			if err != nil {
+				return err
+			}
+			if !found {
+				// Expired or deleted, see peek
+				continue
+			}
+			if self.match(lookup, item.Payload) {
+				total++
+			}
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,28 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestPerPageZero(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"n": i})})
+	}
+	l := resource.NewLookup()
+	l.SetSorts([]string{"-n"})
+	for _, c := range []struct{ perPage, n int }{{-1, 5}, {0, 0}, {2, 2}} {
+		res, err := h.Find(ctx, l, 1, c.perPage)
+		if err != nil || res.Total != 5 || len(res.Items) != c.n || res.Items == nil {
+			t.Fatal(c, res, err)
+		}
+	}
+	if s, e := pageBounds(5, 3, 0); s != e {
+		t.Fatal("bounds")
+	}
+}
@@ -0,0 +1,65 @@
+package filestore
+
+import (
+	"sort"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// Scan returns the items following afterID in the order of their ids, see
+// sortIDs, up to limit of them, all of them if limit <= 0. A nil afterID
+// starts from the first item. nextID is the afterID of the next call, nil when
+// there are no more items, so a long export can be resumed where it stopped,
+// even after a restart. afterID doesn't need to be the id of a stored item.
+// The items inserted behind the cursor during a walk are missed, the others
+// are returned once. The expired and soft deleted items are skipped.
+//
+// The ids are sorted on every call, the cost of a call grows with the size of
+// the collection and not only with limit.
+func (self *FileStoreHandler) Scan(ctx context.Context, afterID interface{}, limit int) (items []*resource.Item, nextID interface{}, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, nil, err
+	}
+	if afterID != nil {
+		if err := checkID(afterID); err != nil {
+			return nil, nil, err
+		}
+		afterID = canonicalID(afterID)
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		ids := self.snapshotIDs()
+		sortIDs(ids)
+		start := 0
+		if afterID != nil {
+			start = sort.Search(len(ids), func(i int) bool { return lessID(afterID, ids[i]) })
+		}
+		items = []*resource.Item{}
+		for i := start; i < len(ids); i++ {
+			if err := checkCanceled(ctx, i-start); err != nil {
+				return err
+			}
+			if limit > 0 && len(items) == limit {
+				nextID = items[len(items)-1].ID
+				break
+			}
+			item, found, err := self.fetch(ids[i])
+			if err != nil {
+				return err
+			}
+			if found {
+				items = append(items, self.present(item))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	count(&self.counters.finds, 1)
+	return items, nextID, nil
+}
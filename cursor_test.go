@@ -0,0 +1,55 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestScanCursor(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithSoftDelete())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	var items []*resource.Item
+	for _, i := range []int{7, 3, 11, 1, 5, 9, 2, 8, 4, 10, 6} {
+		items = append(items, mkitem(i, map[string]interface{}{}))
+	}
+	h.Insert(ctx, items)
+	h.Delete(ctx, &resource.Item{ID: 6})
+	var seen []interface{}
+	var cursor interface{}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("no end")
+		}
+		page, next, err := h.Scan(ctx, cursor, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page {
+			seen = append(seen, item.ID)
+		}
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+	want := []int{1, 2, 3, 4, 5, 7, 8, 9, 10, 11}
+	if len(seen) != len(want) {
+		t.Fatal(seen)
+	}
+	for i, id := range want {
+		if seen[i] != id {
+			t.Fatal(seen)
+		}
+	}
+	// From an id not stored, as int64
+	page, next, err := h.Scan(ctx, int64(6), -1)
+	if err != nil || len(page) != 5 || page[0].ID != 7 || next != nil {
+		t.Fatal(page, next, err)
+	}
+}
@@ -0,0 +1,234 @@
+package filestore
+
+import (
+	"fmt"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// Datafile layout tags. An untagged datafile is a bare gob encoded map of the
+// records, written by older versions and only read.
+const (
+	// datafileOrdered tags a datafile storing the records in the ids order
+	datafileOrdered byte = 0x90
+	// datafileItems tags a datafile written by a handler with a codec other
+	// than gob. It is followed by the codec's tag and the list of items in
+	// the ids order encoded with this codec, so the whole file is in the
+	// codec's format. Superseded by datafileCollection, only read.
+	datafileItems byte = 0x91
+	// datafileCollection is the layout written by a handler with a codec
+	// other than gob. It is like datafileItems with a collectionDatafile in
+	// place of the list of items.
+	datafileCollection byte = 0x92
+)
+
+// orderedDatafile is the layout of the datafiles written with the gob codec
+type orderedDatafile struct {
+	IDs     []interface{}
+	Records [][]byte
+	// Sequence is the last id assigned by AutoIncrement
+	Sequence int
+}
+
+// collectionDatafile is the content of a datafileCollection layout
+type collectionDatafile struct {
+	Sequence int              `json:"sequence"`
+	Items    []*resource.Item `json:"items"`
+}
+
+// datafileContent holds what parseDatafile decoded from a datafile
+type datafileContent struct {
+	items map[interface{}][]byte
+	// ids is only set if the datafile stores the order of the ids
+	ids      []interface{}
+	sequence int
+}
+
+// decodeDatafile replaces the handler's items with the content of an encoded
+// datafile and loads its indexes. The current items are left untouched if
+// data can't be parsed.
+func (self *FileStoreHandler) decodeDatafile(data []byte) error {
+	plain, err := self.openDatafile(data)
+	if err != nil {
+		return err
+	}
+	content, err := parseDatafile(plain)
+	if err != nil {
+		return err
+	}
+
+	for k := range self.items {
+		self.removeRecord(k)
+	}
+
+	// The ids stored before they were canonicalized are canonicalized too
+	var ids []interface{}
+	if content.ids != nil {
+		ids = make([]interface{}, len(content.ids))
+		for i, k := range content.ids {
+			ids[i] = canonicalID(k)
+			self.setRecord(ids[i], content.items[k])
+		}
+	} else {
+		for k, v := range content.items {
+			self.setRecord(canonicalID(k), v)
+			ids = append(ids, canonicalID(k))
+		}
+		// The order isn't stored, use one which doesn't change between
+		// two loads so the pages stay stable
+		sortIDs(ids)
+	}
+	self.setIDs(ids)
+	self.sequence = content.sequence
+	for _, id := range self.ids {
+		self.advanceSequence(id)
+	}
+	return self.loadIndexes(data)
+}
+
+// parseDatafile decodes the content of an encoded datafile
+func parseDatafile(data []byte) (datafileContent, error) {
+	data, err := decompress(data)
+	if err != nil {
+		return datafileContent{}, err
+	}
+
+	if len(data) > 1 && (data[0] == datafileItems || data[0] == datafileCollection) {
+		return parseCollectionDatafile(data[0], data[1], data[2:])
+	}
+
+	if len(data) > 0 && data[0] == datafileOrdered {
+		var ordered orderedDatafile
+		if err := gobDecode(data[1:], &ordered); err != nil {
+			return datafileContent{}, err
+		}
+		if len(ordered.IDs) != len(ordered.Records) {
+			return datafileContent{}, fmt.Errorf("filestore: corrupted datafile, %d ids for %d records", len(ordered.IDs), len(ordered.Records))
+		}
+		items := make(map[interface{}][]byte, len(ordered.IDs))
+		for i, id := range ordered.IDs {
+			items[id] = ordered.Records[i]
+		}
+		if ordered.IDs == nil {
+			ordered.IDs = []interface{}{}
+		}
+		return datafileContent{items: items, ids: ordered.IDs, sequence: ordered.Sequence}, nil
+	}
+
+	var items map[interface{}][]byte
+	if err := gobDecode(data, &items); err != nil {
+		return datafileContent{}, err
+	}
+	return datafileContent{items: items}, nil
+}
+
+// encodeDatafile returns the handler's items in the datafile format, storing
+// the order of the ids so it is preserved through a save and load
+func (self *FileStoreHandler) encodeDatafile() ([]byte, error) {
+	content := datafileContent{items: self.items, ids: self.liveIDs(), sequence: self.sequence}
+	return self.encodeContent(content, func(id interface{}) (*resource.Item, bool, error) {
+		item, found, err := self.decodeItem(id)
+		if err == nil && found {
+			// The blobs stay in their files and the derived fields aren't
+			// persisted
+			item, err = self.externalize(self.withoutDerivedFields(item))
+		}
+		return item, found, err
+	})
+}
+
+// encodeContent returns content in the datafile format of the handler, decode
+// returning the decoded item of an id of content
+func (self *FileStoreHandler) encodeContent(content datafileContent, decode func(id interface{}) (*resource.Item, bool, error)) ([]byte, error) {
+	var data []byte
+	var err error
+	if _, isGob := self.codec().(GobCodec); !isGob {
+		data, err = self.encodeCollectionDatafile(content, decode)
+	} else {
+		ordered := orderedDatafile{
+			IDs:      content.ids,
+			Records:  make([][]byte, len(content.ids)),
+			Sequence: content.sequence,
+		}
+		for i, id := range content.ids {
+			ordered.Records[i] = content.items[id]
+		}
+		if data, err = self.serialize(&ordered); err == nil {
+			data = append([]byte{datafileOrdered}, data...)
+		}
+	}
+	if err == nil && self.compressor() != nil {
+		data, err = compress(self.compressor(), data)
+	}
+	if err == nil {
+		data, err = self.encrypt(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return addHeader(data), nil
+}
+
+// parseCollectionDatafile decodes the items of a datafileItems or
+// datafileCollection layout with the codec registered under tag and turns
+// them back into records
+func parseCollectionDatafile(layout byte, tag byte, data []byte) (datafileContent, error) {
+	c, found := codecs[tag]
+	if !found {
+		return datafileContent{}, fmt.Errorf("filestore: unknown codec tag %#x in datafile", tag)
+	}
+	var collection collectionDatafile
+	var err error
+	if layout == datafileItems {
+		err = c.Unmarshal(data, &collection.Items)
+	} else {
+		err = c.Unmarshal(data, &collection)
+	}
+	if err != nil {
+		return datafileContent{}, err
+	}
+	content := datafileContent{
+		items:    make(map[interface{}][]byte, len(collection.Items)),
+		ids:      make([]interface{}, 0, len(collection.Items)),
+		sequence: collection.Sequence,
+	}
+	for _, item := range collection.Items {
+		if item == nil {
+			return datafileContent{}, fmt.Errorf("filestore: corrupted datafile, null item")
+		}
+		record, err := encodeRecordWith(c, item)
+		if err != nil {
+			return datafileContent{}, err
+		}
+		if _, found := content.items[item.ID]; !found {
+			content.ids = append(content.ids, item.ID)
+		}
+		content.items[item.ID] = record
+	}
+	return content, nil
+}
+
+// encodeCollectionDatafile returns content in the datafileCollection layout
+func (self *FileStoreHandler) encodeCollectionDatafile(content datafileContent, decode func(id interface{}) (*resource.Item, bool, error)) ([]byte, error) {
+	c := self.codec()
+	tag, err := codecTag(c)
+	if err != nil {
+		return nil, err
+	}
+	collection := collectionDatafile{
+		Sequence: content.sequence,
+		Items:    make([]*resource.Item, 0, len(content.ids)),
+	}
+	for _, id := range content.ids {
+		item, _, err := decode(id)
+		if err != nil {
+			return nil, err
+		}
+		collection.Items = append(collection.Items, item)
+	}
+	encoded, err := c.Marshal(&collection)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{datafileCollection, tag}, encoded...), nil
+}
@@ -0,0 +1,58 @@
+package filestore
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// countingFS counts the reads of the OS filesystem
+type countingFS struct {
+	OSFileSystem
+	reads int64
+}
+
+func (f *countingFS) ReadFile(name string) ([]byte, error) {
+	atomic.AddInt64(&f.reads, 1)
+	return f.OSFileSystem.ReadFile(name)
+}
+
+func TestSaveWithoutReadBack(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	fs := &countingFS{}
+	h, err := NewHandlerWithOptions(d, "c", WithFileSystem(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := atomic.LoadInt64(&fs.reads)
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprint(i)
+		if err := h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{"n": i})}); err != nil {
+			t.Fatal(err)
+		}
+		if i%3 == 0 {
+			if err := h.Delete(ctx, &resource.Item{ID: id}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if n := atomic.LoadInt64(&fs.reads); n != reads {
+		t.Fatal("datafile read back", n-reads)
+	}
+	memory, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+	h.Close()
+	h, err = NewHandler(d, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	disk, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+	if !reflect.DeepEqual(memory, disk) {
+		t.Fatal(memory, disk)
+	}
+}
@@ -0,0 +1,87 @@
+package filestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+// Decode decodes the payloads of all the items into a user provided value,
+// mapping payloads to the target type using encoding/json. into must be a
+// pointer to either:
+//   - a map whose key type the item ids are assignable to, or convertible to
+//     with the same kind, like a map[interface{}]SomeStruct or a
+//     map[string]*SomeStruct for string ids;
+//   - a slice, like a []SomeStruct, filled in the handler's id order.
+//
+// A nil map is allocated, matching entries of a non nil one are replaced. A
+// slice is replaced. An error is returned for any other target, or when an id
+// or a payload doesn't fit the target's types.
+func (self *FileStoreHandler) Decode(ctx context.Context, into interface{}) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	target := reflect.ValueOf(into)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("filestore: Decode needs a non nil pointer, got %T", into)
+	}
+	dest := target.Elem()
+	if dest.Kind() != reflect.Map && dest.Kind() != reflect.Slice {
+		return fmt.Errorf("filestore: Decode needs a pointer to a map or a slice, got %T", into)
+	}
+	elemType := dest.Type().Elem()
+
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		var slice reflect.Value
+		if dest.Kind() == reflect.Map && dest.IsNil() {
+			dest.Set(reflect.MakeMap(dest.Type()))
+		} else if dest.Kind() == reflect.Slice {
+			slice = reflect.MakeSlice(dest.Type(), 0, len(self.ids))
+		}
+		for _, id := range self.ids {
+			item, found, err := self.peek(id)
+			if err != nil {
+				return err
+			}
+			if !found {
+				// Expired or deleted, see peek
+				continue
+			}
+			data, err := json.Marshal(self.present(item).Payload)
+			if err != nil {
+				return err
+			}
+			value := reflect.New(elemType)
+			if err := json.Unmarshal(data, value.Interface()); err != nil {
+				return fmt.Errorf("filestore: can't decode item %v into %s: %v", id, elemType, err)
+			}
+			if dest.Kind() == reflect.Slice {
+				slice = reflect.Append(slice, value.Elem())
+				continue
+			}
+			key := reflect.ValueOf(id)
+			keyType := dest.Type().Key()
+			if !key.IsValid() {
+				return fmt.Errorf("filestore: can't use nil id as a %s", keyType)
+			} else if !key.Type().AssignableTo(keyType) {
+				// Only between types of the same kind, an int converts to
+				// the string of its rune
+				if key.Kind() != keyType.Kind() || !key.Type().ConvertibleTo(keyType) {
+					return fmt.Errorf("filestore: can't use id %v of type %T as a %s", id, id, keyType)
+				}
+				key = key.Convert(keyType)
+			}
+			dest.SetMapIndex(key, value.Elem())
+		}
+		if dest.Kind() == reflect.Slice {
+			dest.Set(slice)
+		}
+		return nil
+	})
+}
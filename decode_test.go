@@ -0,0 +1,73 @@
+package filestore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type decoded struct {
+	Name string `json:"name"`
+	N    int    `json:"n"`
+}
+
+type label string
+
+func TestDecode(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{
+		mkitem("b", map[string]interface{}{"name": "b", "n": 2}),
+		mkitem("a", map[string]interface{}{"name": "a", "n": 1}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]decoded
+	if err := h.Decode(ctx, &m); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, map[string]decoded{"a": {"a", 1}, "b": {"b", 2}}) {
+		t.Fatal(m)
+	}
+	var labels map[label]*decoded
+	if err := h.Decode(ctx, &labels); err != nil || labels["a"].N != 1 {
+		t.Fatal(labels, err)
+	}
+	var s []decoded
+	if err := h.Decode(ctx, &s); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, []decoded{{"b", 2}, {"a", 1}}) {
+		t.Fatal(s)
+	}
+}
+
+func TestDecodeMismatch(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem(65, map[string]interface{}{"name": "a"}), mkitem(66, map[string]interface{}{"name": "b"})}); err != nil {
+		t.Fatal(err)
+	}
+	// The int ids aren't turned into the strings of their runes
+	var m map[string]decoded
+	if err := h.Decode(ctx, &m); err == nil {
+		t.Fatal(m)
+	}
+	var ints map[int]decoded
+	if err := h.Decode(ctx, &ints); err != nil || ints[65].Name != "a" {
+		t.Fatal(ints, err)
+	}
+	var wrong []struct {
+		Name int `json:"name"`
+	}
+	if err := h.Decode(ctx, &wrong); err == nil {
+		t.Fatal("expected err")
+	}
+	for _, into := range []interface{}{nil, m, &struct{}{}, new(int)} {
+		if err := h.Decode(ctx, into); err == nil {
+			t.Errorf("decoded into %T", into)
+		}
+	}
+}
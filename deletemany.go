@@ -0,0 +1,54 @@
+package filestore
+
+import "golang.org/x/net/context"
+
+// DeleteMany deletes the items with the given ids, without checking their
+// ETag, and persists the collection once. The ids not found are skipped and
+// the number of items deleted is returned. With SoftDelete, the items are
+// only marked deleted like Delete does.
+func (self *FileStoreHandler) DeleteMany(ctx context.Context, ids []interface{}) (deleted int, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return 0, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		err := self.deleteEach(ctx, ids, &deleted)
+		if deleted > 0 {
+			// Don't leave the items already deleted unpersisted if the
+			// deletion stopped halfway
+			if e := self.persistData(); err == nil {
+				err = e
+			}
+		}
+		return err
+	})
+	count(&self.counters.deletes, deleted)
+	return deleted, err
+}
+
+// deleteEach deletes the items of ids, counting the deleted items
+func (self *FileStoreHandler) deleteEach(ctx context.Context, ids []interface{}, deleted *int) error {
+	for i, id := range ids {
+		if err := checkCanceled(ctx, i); err != nil {
+			return err
+		}
+		if err := checkID(id); err != nil {
+			return err
+		}
+		item, found, err := self.peek(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		if err := self.remove(item); err != nil {
+			return err
+		}
+		*deleted++
+	}
+	return nil
+}
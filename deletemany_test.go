@@ -0,0 +1,34 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestDeleteMany(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", []string{"u"})
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"u": i})})
+	}
+	n, err := h.DeleteMany(ctx, []interface{}{"1", "3", "nope", "3", "5"})
+	if err != nil || n != 3 {
+		t.Fatal(n, err)
+	}
+	h = newH(t, d, "c", []string{"u"})
+	l, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+	got := ""
+	for _, it := range l.Items {
+		got += it.ID.(string)
+	}
+	if got != "024" {
+		t.Fatal(got)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("x", map[string]interface{}{"u": 1})}); err != nil {
+		t.Fatal("index not updated", err)
+	}
+}
@@ -0,0 +1,109 @@
+package filestore
+
+import "github.com/rs/rest-layer/resource"
+
+// With DerivedFields set, the value of each derived field is computed from
+// the payload of an item when its record is stored or loaded and kept in
+// memory next to the records, it isn't persisted. The decoded items hold the
+// derived fields so the lookups filter and sort on them like on any field, and
+// the IndexedFields and SortedIndexes may name them. The derived fields are
+// removed from the items handed out and from the payloads stored, a payload
+// can't set them. The functions get a decoded payload they must not modify,
+// and aren't called again until the record changes or is reloaded.
+
+// deriveFields computes the derived fields of the record of id, which was
+// just set. The records are all set by setRecord, the loaded ones too.
+func (self *FileStoreHandler) deriveFields(id interface{}) {
+	if len(self.DerivedFields) == 0 {
+		return
+	}
+	delete(self.derived, id)
+	data, _, err := self.record(id)
+	if err != nil {
+		return
+	}
+	var item resource.Item
+	if err := decodeRecord(data, &item); err != nil {
+		// Reported when the item is decoded
+		return
+	}
+	if err := self.resolveBlobs(&item); err != nil {
+		return
+	}
+	values := make(map[string]interface{}, len(self.DerivedFields))
+	for field, derive := range self.DerivedFields {
+		values[field] = derive(item.Payload)
+	}
+	if self.derived == nil {
+		self.derived = map[interface{}]map[string]interface{}{}
+	}
+	self.derived[id] = values
+}
+
+// addDerivedFields sets the derived fields of item, which was just decoded
+func (self *FileStoreHandler) addDerivedFields(item *resource.Item) {
+	values := self.derived[item.ID]
+	if len(values) == 0 {
+		return
+	}
+	if item.Payload == nil {
+		item.Payload = make(map[string]interface{}, len(values))
+	}
+	for field, value := range values {
+		item.Payload[field] = value
+	}
+}
+
+// withDerivedFields returns item with its derived fields set, the payload of
+// item itself isn't modified
+func (self *FileStoreHandler) withDerivedFields(item *resource.Item) *resource.Item {
+	values := self.derived[item.ID]
+	if len(values) == 0 {
+		return item
+	}
+	payload := make(map[string]interface{}, len(item.Payload)+len(values))
+	for k, v := range item.Payload {
+		payload[k] = v
+	}
+	for field, value := range values {
+		payload[field] = value
+	}
+	derived := *item
+	derived.Payload = payload
+	return &derived
+}
+
+// withoutDerivedFields returns item without its derived fields, the payload of
+// item itself isn't modified
+func (self *FileStoreHandler) withoutDerivedFields(item *resource.Item) *resource.Item {
+	if item == nil || !self.hasDerivedFields(item.Payload) {
+		return item
+	}
+	payload := make(map[string]interface{}, len(item.Payload))
+	for k, v := range item.Payload {
+		if _, derived := self.DerivedFields[k]; !derived {
+			payload[k] = v
+		}
+	}
+	stripped := *item
+	stripped.Payload = payload
+	return &stripped
+}
+
+// dropDerivedFields removes the derived fields of payload, which the caller
+// owns
+func (self *FileStoreHandler) dropDerivedFields(payload map[string]interface{}) {
+	for field := range self.DerivedFields {
+		delete(payload, field)
+	}
+}
+
+// hasDerivedFields tells if payload holds any derived field
+func (self *FileStoreHandler) hasDerivedFields(payload map[string]interface{}) bool {
+	for field := range self.DerivedFields {
+		if _, found := payload[field]; found {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestDerivedFields(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	calls := 0
+	area := func(p map[string]interface{}) interface{} {
+		calls++
+		w, _ := p["w"].(int)
+		h, _ := p["h"].(int)
+		return w * h
+	}
+	open := func() *FileStoreHandler {
+		h, err := NewHandlerWithOptions(d, "c", WithDerivedField("area", area), WithIndexedFields("area"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	h := open()
+	if err := h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"w": 2, "h": 3}),
+		mkitem("b", map[string]interface{}{"w": 5, "h": 5}),
+		mkitem("c", map[string]interface{}{"w": 1, "h": 4, "area": 100}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	check := func(h *FileStoreHandler) {
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.GreaterOrEqual{Field: "area", Value: 5}})
+		l.SetSorts([]string{"-area"})
+		list, err := h.Find(ctx, l, 1, -1)
+		if err != nil || list.Total != 2 || list.Items[0].ID != "b" || list.Items[1].ID != "a" {
+			t.Fatal(list, err)
+		}
+		if _, found := list.Items[0].Payload["area"]; found {
+			t.Fatal("derived field handed out", list.Items[0].Payload)
+		}
+		l = resource.NewLookup()
+		l.AddQuery(schema.Query{schema.Equal{Field: "area", Value: 4}})
+		if list, err := h.Find(ctx, l, 1, -1); err != nil || list.Total != 1 || list.Items[0].ID != "c" {
+			t.Fatal(list, err)
+		}
+	}
+	check(h)
+	// Updating recomputes the value
+	o, _ := h.Get(ctx, "a")
+	u := mkitem("a", map[string]interface{}{"w": 10, "h": 10})
+	if err := h.Update(ctx, u, o); err != nil {
+		t.Fatal(err)
+	}
+	l := resource.NewLookup()
+	l.SetSorts([]string{"-area"})
+	if list, _ := h.Find(ctx, l, 1, -1); list.Items[0].ID != "a" {
+		t.Fatal(list)
+	}
+	o, _ = h.Get(ctx, "a")
+	if err := h.Update(ctx, mkitem("a", map[string]interface{}{"w": 2, "h": 3}), o); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+
+	// Not persisted, recomputed on load
+	p, err := NewHandler(d, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if item, err := p.Get(ctx, id); err != nil || item.Payload["area"] != nil {
+			t.Fatal(item, err)
+		}
+	}
+	p.Close()
+	calls = 0
+	h = open()
+	defer h.Close()
+	if calls != 3 {
+		t.Fatal("calls", calls)
+	}
+	check(h)
+}
+
+func TestDerivedFieldsNilPayload(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithDerivedField("n", func(p map[string]interface{}) interface{} {
+		return len(p)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.Insert(ctx, []*resource.Item{{ID: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "n", Value: 0}})
+	if list, err := h.Find(ctx, l, 1, -1); err != nil || list.Total != 1 || list.Items[0].ID != "a" {
+		t.Fatal(list, err)
+	}
+	if item, err := h.Get(ctx, "a"); err != nil || item.Payload["n"] != nil {
+		t.Fatal(item, err)
+	}
+}
@@ -0,0 +1,30 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDirectoryMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no execute bits on windows")
+	}
+	d := filepath.Join(tmpdir(t), "a", "b")
+	h, err := NewHandler(d, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	for _, dir := range []string{d, filepath.Dir(d)} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The umask may clear the bits of the group and the others
+		if !info.IsDir() || info.Mode().Perm()&0100 == 0 {
+			t.Fatal(dir, info.Mode())
+		}
+	}
+}
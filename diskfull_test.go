@@ -0,0 +1,62 @@
+package filestore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// fullFS is a memFS whose temporary files fail to be written once full is set,
+// leaving half of their content like a full disk
+type fullFS struct {
+	*memFS
+	full bool
+}
+
+func (f *fullFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if f.full && strings.HasSuffix(name, ".tmp") {
+		f.memFS.WriteFile(name, data[:len(data)/2], perm)
+		return &os.PathError{Op: "write", Path: name, Err: syscall.ENOSPC}
+	}
+	return f.memFS.WriteFile(name, data, perm)
+}
+
+func TestFullDisk(t *testing.T) {
+	ctx := context.Background()
+	fs := &fullFS{memFS: &memFS{files: map[string][]byte{}}}
+	h, err := NewHandlerWithOptions("/data", "c", WithFileSystem(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := fs.ReadFile(h.database_file)
+	fs.full = true
+	err = h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"x": strings.Repeat("x", 1000)})})
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Fatal(err)
+	}
+	after, _ := fs.ReadFile(h.database_file)
+	if !bytes.Equal(before, after) {
+		t.Fatal("datafile changed")
+	}
+	for name := range fs.files {
+		if strings.HasSuffix(name, ".tmp") {
+			t.Fatal("temporary file left", name)
+		}
+	}
+	if h.Len() != 1 {
+		t.Fatal(h.Len())
+	}
+	fs.full = false
+	if err := h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+}
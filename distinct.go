@@ -0,0 +1,105 @@
+package filestore
+
+import (
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// Distinct returns the distinct values field takes in the items matching the
+// lookup, sorted like the ids by sortIDs. Items where the field is missing or
+// null are skipped. Without a filter, the values of an indexed top level
+// field are taken from its index without decoding every item.
+func (self *FileStoreHandler) Distinct(ctx context.Context, field string, lookup *resource.Lookup) (values []interface{}, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := self.checkLookup(lookup); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		if len(lookup.Filter()) == 0 && self.MatchFunc == nil {
+			var ok bool
+			if values, ok, err = self.indexDistinct(field); ok || err != nil {
+				return err
+			}
+		}
+		ids, ok := self.indexCandidates(lookup)
+		if !ok {
+			if ids, ok = self.rangeCandidates(lookup); !ok {
+				if err := self.checkFullScan(lookup); err != nil {
+					return err
+				}
+				ids = self.ids
+			}
+		}
+		seen := map[interface{}]bool{}
+		for i, id := range ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			item, found, err := self.peekValid(id)
+			if err != nil {
+				return err
+			}
+			if !found {
+				// Expired or deleted, see peek
+				continue
+			}
+			if !self.match(lookup, item.Payload) {
+				continue
+			}
+			value := item.GetField(field)
+			if value == nil {
+				continue
+			}
+			if key := indexKey(value); !seen[key] {
+				seen[key] = true
+				values = append(values, value)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortIDs(values)
+	return values, nil
+}
+
+// indexDistinct returns the distinct values of field from its index, false if
+// it isn't indexed or its index folds the values
+func (self *FileStoreHandler) indexDistinct(field string) ([]interface{}, bool, error) {
+	idx := self.fieldIndex(field)
+	if idx == nil || idx.Fold || strings.Contains(field, ".") {
+		return nil, false, nil
+	}
+	var values []interface{}
+	for key, ids := range idx.IDs {
+		if key == nil {
+			continue
+		}
+		ids = self.visible(ids)
+		if len(ids) == 0 {
+			continue
+		}
+		if _, composite := key.(compositeKey); !composite {
+			values = append(values, key)
+			continue
+		}
+		// Take the value itself from one of the items holding it
+		item, found, err := self.peek(ids[0])
+		if err != nil {
+			return nil, false, err
+		}
+		if found {
+			values = append(values, item.Payload[field])
+		}
+	}
+	return values, true, nil
+}
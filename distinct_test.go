@@ -0,0 +1,53 @@
+package filestore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestDistinct(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		var opts []Option
+		if indexed {
+			opts = append(opts, WithIndexedFields("status"))
+		}
+		h, err := NewHandlerWithOptions(tmpdir(t), "c", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		items := []*resource.Item{
+			mkitem(1, map[string]interface{}{"status": "open", "n": 1}),
+			mkitem(2, map[string]interface{}{"status": "closed", "n": 1}),
+			mkitem(3, map[string]interface{}{"status": "open", "n": 2}),
+			mkitem(4, map[string]interface{}{"status": nil, "n": 2}),
+			mkitem(5, map[string]interface{}{"n": 2}),
+			mkitem(6, map[string]interface{}{"status": []interface{}{"a"}, "n": 3}),
+		}
+		if err := h.Insert(ctx, items); err != nil {
+			t.Fatal(err)
+		}
+		got, err := h.Distinct(ctx, "status", resource.NewLookup())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []interface{}{[]interface{}{"a"}, "closed", "open"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("indexed %v: got %#v", indexed, got)
+		}
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.Equal{Field: "n", Value: 2}})
+		got, err = h.Distinct(ctx, "status", l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, []interface{}{"open"}) {
+			t.Fatalf("filtered: %#v", got)
+		}
+		h.Close()
+	}
+}
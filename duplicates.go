@@ -0,0 +1,117 @@
+package filestore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// FindDuplicates scans the collection and returns, for each value of field
+// held by more than one item, the ids of the items sharing it. Values that
+// can't be used as map keys (maps, slices) are returned as their index key,
+// and the values of the CaseInsensitiveUniqueFields folded by foldValue.
+// Items lacking the field are ignored.
+func (self *FileStoreHandler) FindDuplicates(ctx context.Context, field string) (dups map[interface{}][]interface{}, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		dups, err = self.findDuplicatesNoLock(field)
+		return err
+	})
+	return dups, err
+}
+
+func (self *FileStoreHandler) findDuplicatesNoLock(field string) (map[interface{}][]interface{}, error) {
+	idx, err := self.buildIndex(field)
+	if err != nil {
+		return nil, err
+	}
+	dups := map[interface{}][]interface{}{}
+	for key, ids := range idx.IDs {
+		if len(ids) > 1 {
+			dups[key] = ids
+		}
+	}
+	return dups, nil
+}
+
+// Reindex rebuilds the indexes of the unique fields from the stored items. It
+// fails without touching the current indexes if a unique field holds duplicate
+// values, FindDuplicates tells which items have to be fixed.
+func (self *FileStoreHandler) Reindex(ctx context.Context) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkOpen(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		for _, field := range self.UniqueFields {
+			dups, err := self.findDuplicatesNoLock(field)
+			if err != nil {
+				return err
+			}
+			if len(dups) > 0 {
+				return &rest.Error{Code: 422, Message: fmt.Sprintf("Unique field '%s' holds %d duplicated values", field, len(dups))}
+			}
+		}
+		return self.rebuildIndexes()
+	})
+}
+
+// SetUniqueFields replaces the UniqueFields of a live handler. The stored
+// items are checked against the new fields first: if some hold duplicate
+// values the constraint isn't changed and the error lists their ids.
+func (self *FileStoreHandler) SetUniqueFields(ctx context.Context, fields []string) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkOpen(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		previous := self.UniqueFields
+		self.UniqueFields = append([]string(nil), fields...)
+		err := self.validateUniqueFields()
+		for _, field := range self.UniqueFields {
+			if err != nil {
+				break
+			}
+			var dups map[interface{}][]interface{}
+			if dups, err = self.findDuplicatesNoLock(field); err == nil && len(dups) > 0 {
+				err = duplicatesError(field, dups)
+			}
+		}
+		if err == nil {
+			err = self.rebuildIndexes()
+		}
+		if err != nil {
+			self.UniqueFields = previous
+		}
+		return err
+	})
+}
+
+// duplicatesError returns the error reporting the items holding the
+// duplicated values dups of a unique field
+func duplicatesError(field string, dups map[interface{}][]interface{}) error {
+	var ids []interface{}
+	for _, dup := range dups {
+		ids = append(ids, dup...)
+	}
+	sortIDs(ids)
+	list := make([]string, len(ids))
+	for i, id := range ids {
+		list[i] = fmt.Sprint(id)
+	}
+	return &rest.Error{Code: 422, Message: fmt.Sprintf("Unique field '%s' holds duplicated values in items %s", field, strings.Join(list, ", "))}
+}
@@ -0,0 +1,76 @@
+package filestore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestFindDuplicatesAndReindex(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	// A datafile written without the unique constraint
+	h := newH(t, d, "c", nil)
+	if err := h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"email": "x@y", "tags": []interface{}{"t"}}),
+		mkitem("b", map[string]interface{}{"email": "z@y", "tags": []interface{}{"t"}}),
+		mkitem("c", map[string]interface{}{"email": "x@y"}),
+		mkitem("d", map[string]interface{}{}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	h = newH(t, d, "c", []string{"email"})
+
+	dups, err := h.FindDuplicates(ctx, "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dups, map[interface{}][]interface{}{"x@y": {"a", "c"}}) {
+		t.Fatal(dups)
+	}
+	// Values which can't be map keys are reported by their index key
+	if dups, err := h.FindDuplicates(ctx, "tags"); err != nil || len(dups) != 1 {
+		t.Fatal(dups, err)
+	} else {
+		for key, ids := range dups {
+			if key != indexKey([]interface{}{"t"}) || !reflect.DeepEqual(ids, []interface{}{"a", "b"}) {
+				t.Fatal(key, ids)
+			}
+		}
+	}
+
+	// The indexes are left untouched while the duplicates remain
+	before := h.fieldIndex("email")
+	if err := h.Reindex(ctx); err == nil {
+		t.Fatal("expected err")
+	}
+	if h.fieldIndex("email") != before {
+		t.Fatal("indexes replaced")
+	}
+	original, err := h.Get(ctx, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Update(ctx, mkitem("c", map[string]interface{}{"email": "c@y"}), original); err != nil {
+		t.Fatal(err)
+	}
+	if dups, err := h.FindDuplicates(ctx, "email"); err != nil || len(dups) != 0 {
+		t.Fatal(dups, err)
+	}
+	// An index gone wrong is rebuilt from the items
+	h.fieldIndex("email").remove("a")
+	if err := h.Reindex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for value, want := range map[string][]interface{}{"x@y": {"a"}, "z@y": {"b"}, "c@y": {"c"}} {
+		if ids := h.fieldIndex("email").lookup(value); !reflect.DeepEqual(ids, want) {
+			t.Errorf("%s: %v", value, ids)
+		}
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("e", map[string]interface{}{"email": "x@y"})}); err == nil {
+		t.Fatal("duplicate inserted")
+	}
+}
@@ -0,0 +1,69 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// FindEach calls fn with each item matching the lookup, in the order Find
+// would return them, decoding the items one at a time instead of building the
+// whole list. It stops at the first error returned by fn, which it returns, or
+// once ctx is canceled. A lookup with a sort still buffers the matching items
+// to sort them. The handler is read locked until FindEach returns, fn must not
+// write to it. fn owns the items it gets.
+func (self *FileStoreHandler) FindEach(ctx context.Context, lookup *resource.Lookup, fn func(item *resource.Item) error) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return err
+	}
+	if err := self.checkLookup(lookup); err != nil {
+		return err
+	}
+	count(&self.counters.finds, 1)
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if len(lookup.Sort()) > 0 {
+			list, err := self.findWindow(ctx, lookup, pageWindow(1, -1))
+			if err != nil {
+				return err
+			}
+			for i, item := range list.Items {
+				if err := checkCanceled(ctx, i); err != nil {
+					return err
+				}
+				if err := fn(self.present(item)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		ids, fromIndex := self.indexCandidates(lookup)
+		if !fromIndex {
+			var ok bool
+			if ids, ok = self.rangeCandidates(lookup); !ok {
+				if err := self.checkFullScan(lookup); err != nil {
+					return err
+				}
+				ids = self.ids
+			}
+		}
+		for i, id := range ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			item, found, err := self.peekValid(id)
+			if err != nil {
+				return err
+			}
+			if !found || (!fromIndex && !self.match(lookup, item.Payload)) {
+				continue
+			}
+			if err := fn(self.present(cloneItem(item))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,51 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestFindEach(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", []string{"u"})
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"u": i, "odd": i%2 == 1})})
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "odd", Value: true}})
+	got := ""
+	if err := h.FindEach(ctx, l, func(it *resource.Item) error { got += it.ID.(string); it.Payload["u"] = -1; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got != "135" {
+		t.Fatal(got)
+	}
+	stop := errors.New("stop")
+	n := 0
+	if err := h.FindEach(ctx, resource.NewLookup(), func(it *resource.Item) error {
+		n++
+		if n == 2 {
+			return stop
+		}
+		return nil
+	}); err != stop || n != 2 {
+		t.Fatal(err, n)
+	}
+	l = resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "u", Value: 3}})
+	got = ""
+	h.FindEach(ctx, l, func(it *resource.Item) error { got += it.ID.(string); return nil })
+	if got != "3" {
+		t.Fatal(got)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := h.FindEach(cctx, resource.NewLookup(), func(*resource.Item) error { return nil }); err == nil {
+		t.Fatal("not canceled")
+	}
+}
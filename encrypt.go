@@ -0,0 +1,81 @@
+package filestore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// datafileEncrypted tags a datafile encrypted with AES-GCM. It is followed by
+// the random nonce and the sealed content.
+const datafileEncrypted byte = 0xb0
+
+var (
+	// ErrEncrypted is returned when reading an encrypted datafile without a
+	// key
+	ErrEncrypted = errors.New("filestore: datafile is encrypted and no key is configured")
+	// ErrDecrypt is returned when an encrypted datafile can't be decrypted,
+	// the key is wrong or the datafile is corrupted
+	ErrDecrypt = errors.New("filestore: can't decrypt datafile, wrong key or corrupted data")
+)
+
+// NewEncryptedHandler creates a handler like NewHandler with a datafile
+// encrypted with AES-256-GCM using key, which must be 32 bytes long. An
+// unencrypted datafile is still read and gets encrypted on the next save. The
+// persisted indexes are encrypted as well.
+func NewEncryptedHandler(directory string, collection string, uniqueFields []string, key []byte) (*FileStoreHandler, error) {
+	return NewHandlerWithOptions(directory, collection, WithUniqueFields(uniqueFields...), WithEncryptionKey(key))
+}
+
+func (self *FileStoreHandler) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(self.encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt encrypts data if the handler has a key and returns it as is
+// otherwise
+func (self *FileStoreHandler) encrypt(data []byte) ([]byte, error) {
+	if self.encryptionKey == nil {
+		return data, nil
+	}
+	gcm, err := self.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, 0, 1+len(nonce)+len(data)+gcm.Overhead())
+	sealed = append(sealed, datafileEncrypted)
+	sealed = append(sealed, nonce...)
+	return gcm.Seal(sealed, nonce, data, nil), nil
+}
+
+// decrypt decrypts data if it is encrypted and returns it as is otherwise
+func (self *FileStoreHandler) decrypt(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != datafileEncrypted {
+		return data, nil
+	}
+	if self.encryptionKey == nil {
+		return nil, ErrEncrypted
+	}
+	gcm, err := self.gcm()
+	if err != nil {
+		return nil, err
+	}
+	data = data[1:]
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plain, nil
+}
@@ -0,0 +1,47 @@
+package filestore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestEncrypt(t *testing.T) {
+	d := tmpdir(t)
+	ctx := context.Background()
+	h := newH(t, d, "c", []string{"name"})
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"id": "a", "name": "secretvalue"})})
+	h.Close()
+	key := bytes.Repeat([]byte{7}, 32)
+	e, err := NewEncryptedHandler(d, "c", []string{"name"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.PersistIndexes = true
+	e.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"id": "b", "name": "othersecret"})})
+	e.Close()
+	for _, f := range []string{"/c", "/c.idx"} {
+		b, _ := ioutil.ReadFile(d + f)
+		_, b, _ = splitHeader(b)
+		if b[0] != datafileEncrypted || bytes.Contains(b, []byte("secret")) {
+			t.Fatal(f)
+		}
+	}
+	if _, err := NewHandler(d, "c", nil); err != ErrEncrypted {
+		t.Fatal(err)
+	}
+	if _, err := NewEncryptedHandler(d, "c", nil, bytes.Repeat([]byte{8}, 32)); err != ErrDecrypt {
+		t.Fatal(err)
+	}
+	e2, err := NewEncryptedHandler(d, "c", nil, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := e2.Count(ctx, resource.NewLookup()); n != 2 {
+		t.Fatal(n)
+	}
+	e2.Close()
+}
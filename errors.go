@@ -0,0 +1,84 @@
+package filestore
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/rs/rest-layer/rest"
+)
+
+var (
+	// ErrMissingID is returned when inserting an item with a zero value id
+	// and neither IDGenerator nor AutoIncrement is configured
+	ErrMissingID = &rest.Error{Code: 422, Message: "Missing item ID"}
+	// ErrMemoryLimit is returned when a write would make the stored records
+	// exceed MaxMemoryBytes
+	ErrMemoryLimit = &rest.Error{Code: 507, Message: "Memory limit exceeded"}
+	// ErrClosed is returned by the operations on a handler after Close
+	ErrClosed = &rest.Error{Code: 503, Message: "Handler closed"}
+	// ErrReadOnly is returned when writing to a handler with ReadOnly set
+	ErrReadOnly = &rest.Error{Code: 405, Message: "Read only collection"}
+	// ErrTooManyResults is returned by the finds which would return more
+	// than MaxResultItems items
+	ErrTooManyResults = &rest.Error{Code: 422, Message: "Too many items requested, paginate the query"}
+)
+
+// checkWritable returns the error of a write to the handler, if it's closed or
+// read only
+func (self *FileStoreHandler) checkWritable() error {
+	if err := self.checkOpen(); err != nil {
+		return err
+	}
+	if self.ReadOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// checkOpen returns ErrClosed if the handler is closed. The reads fail like
+// the writes once the handler is closed, only the accessors without an error
+// like Len, IDs or Stats keep answering.
+func (self *FileStoreHandler) checkOpen() error {
+	if self.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// uniqueIssue is the issue reported on the fields of a unique constraint
+// violated by a write
+const uniqueIssue = "must be unique"
+
+// uniqueViolation returns the error of a write violating the unique constraint
+// on fields. It is a *rest.Error, which rest-layer turns into a 422 response,
+// holding an issue on each field, see UniqueViolation.
+func uniqueViolation(fields ...string) *rest.Error {
+	issues := make(map[string][]interface{}, len(fields))
+	for _, field := range fields {
+		issues[field] = []interface{}{uniqueIssue}
+	}
+	message := "Unique precondition failed on field '" + fields[0] + "'"
+	if len(fields) > 1 {
+		message = "Unique precondition failed on fields '" + strings.Join(fields, "', '") + "'"
+	}
+	return &rest.Error{Code: 422, Message: message, Issues: issues}
+}
+
+// UniqueViolation tells if err, or an error it wraps, was returned for a write
+// violating one of the UniqueFields or UniqueCompositeFields, and returns the
+// fields of the constraint, sorted
+func UniqueViolation(err error) (fields []string, ok bool) {
+	var e *rest.Error
+	if !errors.As(err, &e) || e.Code != 422 || len(e.Issues) == 0 {
+		return nil, false
+	}
+	for field, issues := range e.Issues {
+		if len(issues) != 1 || issues[0] != uniqueIssue {
+			return nil, false
+		}
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields, true
+}
@@ -0,0 +1,42 @@
+package filestore
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// ETag computes the ETag the handler assigns to a payload. It is derived from
+// the content only so equal payloads get the same ETag on every replica, and
+// clients can compute the ETag to expect for a conditional request.
+//
+// The payload is canonicalized with encoding/json: object keys are sorted,
+// there is no insignificant whitespace, strings are HTML escaped and numbers
+// use their shortest representation. The ETag is the lowercase hexadecimal md5
+// of this JSON document, the same way rest-layer computes ETags in
+// resource.NewItem.
+func ETag(payload map[string]interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", md5.Sum(data)), nil
+}
+
+// generateETag returns item with the ETag of its payload if it has none and
+// GenerateETags is set. The ETag is computed before the timestamps and the
+// shadow fields are added, so it only depends on the payload given.
+func (self *FileStoreHandler) generateETag(item *resource.Item) (*resource.Item, error) {
+	if !self.GenerateETags || item.ETag != "" {
+		return item, nil
+	}
+	etag, err := ETag(item.Payload)
+	if err != nil {
+		return nil, err
+	}
+	tagged := *item
+	tagged.ETag = etag
+	return &tagged, nil
+}
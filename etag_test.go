@@ -0,0 +1,30 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestEmptyETag(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{}), mkitem("b", map[string]interface{}{})})
+	a, _ := h.Get(ctx, "a")
+	if err := h.Update(ctx, mkitem("a", map[string]interface{}{"v": 1}), a); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Update(ctx, mkitem("a", map[string]interface{}{"v": 2}), a); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+	if err := h.Update(ctx, mkitem("a", map[string]interface{}{"v": 3}), &resource.Item{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Delete(ctx, &resource.Item{ID: "b", ETag: "x"}); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+	if err := h.Delete(ctx, &resource.Item{ID: "b"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,119 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+)
+
+// EventBufferSize is the number of events buffered for each subscriber, the
+// events overflowing it are dropped
+var EventBufferSize = 256
+
+// Event notifies a change of the collection to the subscribers. Its items are
+// shared by all the subscribers and must not be modified.
+type Event struct {
+	Op ChangeOp
+	ID interface{}
+	// Item is the item as stored by the change, nil for a ChangeDelete
+	Item *resource.Item
+	// Old is the item before the change, nil for a ChangeInsert
+	Old *resource.Item
+}
+
+// Subscribe returns a channel receiving an event for every change made by
+// Insert, Update, Delete, Clear and the other writes, once it is saved to
+// disk. Delivery never blocks the writes: the events a subscriber doesn't
+// consume fast enough to fit in EventBufferSize are dropped, a subscriber
+// needing every change should use ChangesSince. The channel is closed by
+// Close.
+func (self *FileStoreHandler) Subscribe() <-chan Event {
+	self.Lock()
+	defer self.Unlock()
+	c := make(chan Event, EventBufferSize)
+	if self.closed {
+		close(c)
+		return c
+	}
+	self.subscribers = append(self.subscribers, c)
+	return c
+}
+
+// pendingEvent is an event waiting for its change to be saved, seq numbers the
+// events in the order they were queued
+type pendingEvent struct {
+	Event
+	seq uint64
+}
+
+// notify queues the event of a change until it is saved
+func (self *FileStoreHandler) notify(op ChangeOp, id interface{}, record, old []byte) {
+	if len(self.subscribers) == 0 {
+		return
+	}
+	event := Event{Op: op, ID: id}
+	if record != nil {
+		event.Item = self.eventItem(record)
+	}
+	if old != nil {
+		event.Old = self.eventItem(old)
+	}
+	self.eventSeq++
+	self.pending = append(self.pending, pendingEvent{Event: event, seq: self.eventSeq})
+}
+
+func (self *FileStoreHandler) eventItem(record []byte) *resource.Item {
+	var item resource.Item
+	if err := decodeRecord(record, &item); err != nil {
+		return nil
+	}
+	if err := self.resolveBlobs(&item); err != nil {
+		return nil
+	}
+	return self.present(&item)
+}
+
+// publish sends the pending events to the subscribers without blocking
+func (self *FileStoreHandler) publish() {
+	self.publishUpTo(self.eventSeq)
+}
+
+// publishUpTo sends the pending events queued up to the event numbered seq,
+// the ones queued after it keep waiting
+func (self *FileStoreHandler) publishUpTo(seq uint64) {
+	rest := self.pending[:0]
+	for _, event := range self.pending {
+		if event.seq > seq {
+			rest = append(rest, event)
+			continue
+		}
+		for _, c := range self.subscribers {
+			select {
+			case c <- event.Event:
+			default:
+				// Slow subscriber
+			}
+		}
+	}
+	self.pending = rest
+	if len(self.pending) == 0 {
+		self.pending = nil
+	}
+}
+
+// dropEvents removes the pending events numbered from after from up to to,
+// the events of a change undone
+func (self *FileStoreHandler) dropEvents(from, to uint64) {
+	rest := self.pending[:0]
+	for _, event := range self.pending {
+		if event.seq <= from || event.seq > to {
+			rest = append(rest, event)
+		}
+	}
+	self.pending = rest
+}
+
+func (self *FileStoreHandler) closeSubscribers() {
+	for _, c := range self.subscribers {
+		close(c)
+	}
+	self.subscribers = nil
+}
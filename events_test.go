@@ -0,0 +1,33 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestSubscribe(t *testing.T) {
+	d := tmpdir(t)
+	ctx := context.Background()
+	h := newH(t, d, "c", nil)
+	c := h.Subscribe()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"id": "a"})})
+	e := <-c
+	if e.Op != ChangeInsert || e.ID != "a" || e.Item == nil || e.Old != nil {
+		t.Fatal(e)
+	}
+	l, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+	h.Update(ctx, mkitem("a", map[string]interface{}{"id": "a", "x": 1}), l.Items[0])
+	if e := <-c; e.Op != ChangeUpdate || e.Old == nil || e.Item.Payload["x"] != 1 {
+		t.Fatal(e)
+	}
+	h.Clear(ctx, resource.NewLookup())
+	if e := <-c; e.Op != ChangeDelete || e.Item != nil || e.Old == nil {
+		t.Fatal(e)
+	}
+	h.Close()
+	if _, ok := <-c; ok {
+		t.Fatal("not closed")
+	}
+}
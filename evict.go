@@ -0,0 +1,128 @@
+package filestore
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/rs/rest-layer/rest"
+)
+
+// ErrFull is returned when an insert would make the collection hold more
+// than MaxItems items and Eviction is EvictNone
+var ErrFull = &rest.Error{Code: 507, Message: "Item limit reached"}
+
+// EvictionPolicy tells what an insert does when the collection holds
+// MaxItems items
+type EvictionPolicy int
+
+const (
+	// EvictNone rejects the insert with ErrFull
+	EvictNone EvictionPolicy = iota
+	// EvictOldest removes the items inserted first
+	EvictOldest
+	// EvictLRU removes the items least recently inserted, updated or read
+	EvictLRU
+)
+
+// accessLog tracks the order in which the items were last accessed for
+// EvictLRU. It has its own lock as the reads update it holding the handler's
+// read lock only.
+type accessLog struct {
+	sync.Mutex
+	tick uint64
+	last map[interface{}]uint64
+}
+
+// touch records an access to the item with id
+func (self *FileStoreHandler) touch(id interface{}) {
+	if self.Eviction != EvictLRU {
+		return
+	}
+	a := &self.access
+	a.Lock()
+	defer a.Unlock()
+	if a.last == nil {
+		a.last = map[interface{}]uint64{}
+	}
+	a.tick++
+	a.last[id] = a.tick
+}
+
+// forget drops the accesses of the removed item with id
+func (self *FileStoreHandler) forget(id interface{}) {
+	if self.Eviction != EvictLRU {
+		return
+	}
+	self.access.Lock()
+	defer self.access.Unlock()
+	delete(self.access.last, id)
+}
+
+// checkRoom tells if makeRoom can make added more items, growing the memory
+// of the records by grow bytes, fit in MaxItems and MaxMemoryBytes without
+// evicting the items of keep. Nothing is evicted.
+func (self *FileStoreHandler) checkRoom(added int, grow int64, keep map[interface{}]bool) error {
+	if self.MaxItems > 0 && self.idCount()+added > self.MaxItems {
+		if self.Eviction == EvictNone || added > self.MaxItems {
+			return ErrFull
+		}
+	}
+	if self.MaxMemoryBytes > 0 && self.memoryBytes+grow > self.MaxMemoryBytes {
+		if self.Eviction == EvictNone {
+			return ErrMemoryLimit
+		}
+		// Only the other items can be evicted
+		kept := int64(0)
+		for id := range keep {
+			if record, found := self.items[id]; found {
+				kept += recordSize(record)
+			}
+		}
+		if kept+grow > self.MaxMemoryBytes {
+			return ErrMemoryLimit
+		}
+	}
+	return nil
+}
+
+// makeRoom makes sure added more items, growing the memory of the records by
+// grow bytes, fit in MaxItems and MaxMemoryBytes, evicting the items
+// designated by the Eviction policy other than the ones of keep. The count
+// includes the expired and soft deleted items not removed yet. Nothing is
+// evicted if they can't fit, see checkRoom. The caller is responsible of
+// persisting the evictions.
+func (self *FileStoreHandler) makeRoom(added int, grow int64, keep map[interface{}]bool) error {
+	if err := self.checkRoom(added, grow, keep); err != nil {
+		return err
+	}
+	full := func() bool {
+		return self.MaxItems > 0 && self.idCount()+added > self.MaxItems ||
+			self.MaxMemoryBytes > 0 && self.memoryBytes+grow > self.MaxMemoryBytes
+	}
+	if !full() {
+		return nil
+	}
+	ids := self.snapshotIDs()
+	if self.Eviction == EvictLRU {
+		self.access.Lock()
+		// Items never accessed since the handler was created come first,
+		// in the handler's order
+		sort.SliceStable(ids, func(i, j int) bool {
+			return self.access.last[ids[i]] < self.access.last[ids[j]]
+		})
+		self.access.Unlock()
+	}
+	evicted := 0
+	for _, id := range ids {
+		if !full() {
+			break
+		}
+		if keep[id] {
+			continue
+		}
+		self.delete(id)
+		evicted++
+	}
+	self.debugf("Evicted %d items of database %s", evicted, self.database_file)
+	return nil
+}
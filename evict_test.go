@@ -0,0 +1,50 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestMaxItems(t *testing.T) {
+	ctx := context.Background()
+	ins := func(h *FileStoreHandler, ids ...string) error {
+		var items []*resource.Item
+		for _, id := range ids {
+			items = append(items, mkitem(id, map[string]interface{}{"v": id}))
+		}
+		return h.Insert(ctx, items)
+	}
+	h := NewMemoryHandler(0)
+	h.MaxItems = 2
+	ins(h, "a", "b")
+	if err := ins(h, "c"); err != ErrFull {
+		t.Fatal(err)
+	}
+	h.Eviction = EvictOldest
+	if err := ins(h, "c"); err != nil || fmt.Sprint(h.liveIDs()) != "[b c]" {
+		t.Fatal(err, h.liveIDs())
+	}
+	if err := ins(h, "x", "y", "z"); err != ErrFull {
+		t.Fatal(err)
+	}
+
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithMaxItems(3, EvictLRU), WithUniqueFields("v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	ins(h, "a", "b", "c")
+	h.Get(ctx, "a")
+	if err := ins(h, "d"); err != nil || fmt.Sprint(h.liveIDs()) != "[a c d]" {
+		t.Fatal(err, h.liveIDs())
+	}
+	if err := ins(h, "b"); err != nil || fmt.Sprint(h.liveIDs()) != "[a d b]" {
+		t.Fatal(err, h.liveIDs())
+	}
+	if ids := h.fieldIndex("v").lookup("c"); len(ids) != 0 {
+		t.Fatal(ids)
+	}
+}
@@ -0,0 +1,49 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestExistsFilter(t *testing.T) {
+	ctx := context.Background()
+	for _, opts := range [][]Option{{WithIndexedFields("x")}, {}} {
+		h, err := NewHandlerWithOptions(tmpdir(t), "c", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Insert(ctx, []*resource.Item{
+			mkitem(1, map[string]interface{}{"x": 1}),
+			mkitem(2, map[string]interface{}{}),
+			mkitem(3, map[string]interface{}{"x": nil}),
+			mkitem(4, map[string]interface{}{"y": 1}),
+			mkitem(5, map[string]interface{}{"x": "a"}),
+		})
+		for _, c := range []struct {
+			exp  schema.Expression
+			want []interface{}
+		}{
+			{schema.Exist{Field: "x"}, []interface{}{1, 3, 5}},
+			{schema.NotExist{Field: "x"}, []interface{}{2, 4}},
+		} {
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{c.exp})
+			res, err := h.Find(ctx, l, 1, -1)
+			if err != nil || res.Total != len(c.want) || len(res.Items) != len(c.want) {
+				t.Fatal(c.exp, res, err)
+			}
+			for i, it := range res.Items {
+				if it.ID != c.want[i] {
+					t.Fatal(c.exp, i, it.ID)
+				}
+			}
+			if n, _ := h.Count(ctx, l); n != len(c.want) {
+				t.Fatal(n)
+			}
+		}
+		h.Close()
+	}
+}
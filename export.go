@@ -0,0 +1,117 @@
+package filestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// exportedItem is the JSON representation of an item written by Export
+type exportedItem struct {
+	ID      interface{}            `json:"id"`
+	ETag    string                 `json:"etag"`
+	Updated time.Time              `json:"updated"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// Export writes all the items to w as a JSON array, for inspection or backup.
+// The items are sorted by id like sortIDs does, whatever their insertion
+// order: numbers by value, strings lexically, then the other ids by type name
+// and formatted value. Along with the payload members encoded in key order,
+// this makes two handlers holding the same items export the same bytes, for
+// meaningful diffs of exports kept under version control. The items are
+// encoded one by one under the read lock, so the whole collection is never
+// held in memory twice.
+func (self *FileStoreHandler) Export(ctx context.Context, w io.Writer) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		ids := self.snapshotIDs()
+		sortIDs(ids)
+		written := 0
+		for i, id := range ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			item, found, err := self.peek(id)
+			if err != nil {
+				return err
+			}
+			if !found {
+				// Expired or deleted, see peek
+				continue
+			}
+			item = self.present(item)
+			data, err := json.Marshal(exportedItem{ID: item.ID, ETag: item.ETag, Updated: item.Updated, Payload: item.Payload})
+			if err != nil {
+				return err
+			}
+			sep := ",\n"
+			if written == 0 {
+				sep = "\n"
+			}
+			if _, err := io.WriteString(w, sep); err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			written++
+		}
+		_, err := io.WriteString(w, "\n]\n")
+		return err
+	})
+}
+
+// Import inserts the items of a JSON array written by Export, all or nothing
+// like Insert. JSON doesn't keep Go types: numbers, including numeric ids,
+// are read back as float64. Items without an ETag get one computed from
+// their payload. Items without an id take the one of their IDField.
+func (self *FileStoreHandler) Import(ctx context.Context, r io.Reader) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("filestore: import expects a JSON array, got %v", tok)
+	}
+	items := []*resource.Item{}
+	for i := 0; dec.More(); i++ {
+		if err := checkCanceled(ctx, i); err != nil {
+			return err
+		}
+		var e exportedItem
+		if err := dec.Decode(&e); err != nil {
+			return err
+		}
+		if e.ETag == "" {
+			etag, err := ETag(e.Payload)
+			if err != nil {
+				return err
+			}
+			e.ETag = etag
+		}
+		if e.ID == nil {
+			// Exported by another tool
+			e.ID = e.Payload[self.idField()]
+		}
+		items = append(items, &resource.Item{ID: e.ID, ETag: e.ETag, Updated: e.Updated, Payload: e.Payload})
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return self.Insert(ctx, items)
+}
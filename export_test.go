@@ -0,0 +1,35 @@
+package filestore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestExportImport(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"id": "a", "n": 1}),
+		mkitem("b", map[string]interface{}{"id": "b", "s": []interface{}{"x"}}),
+	})
+	var buf bytes.Buffer
+	if err := h.Export(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	h2 := NewMemoryHandler(0)
+	if err := h2.Import(ctx, bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err, buf.String())
+	}
+	l, _ := h2.Find(ctx, resource.NewLookup(), 1, -1)
+	if l.Total != 2 || l.Items[0].ID != "a" || l.Items[0].Payload["n"] != float64(1) {
+		t.Fatal(l)
+	}
+	var empty bytes.Buffer
+	NewMemoryHandler(0).Export(ctx, &empty)
+	if empty.String() != "[\n]\n" {
+		t.Fatal(empty.String())
+	}
+}
@@ -0,0 +1,43 @@
+package filestore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestExportDeterministic(t *testing.T) {
+	items := []*resource.Item{
+		mkitem(3, map[string]interface{}{"z": 1, "a": 2}),
+		mkitem(1, map[string]interface{}{"b": "x"}),
+		mkitem(20, map[string]interface{}{"c": []interface{}{1, 2}}),
+		mkitem(2, map[string]interface{}{"d": true}),
+	}
+	ctx := context.Background()
+	export := func(order []int) []byte {
+		h, err := NewHandlerWithOptions(tmpdir(t), "c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close()
+		for _, i := range order {
+			if err := h.Insert(ctx, []*resource.Item{items[i]}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var buf bytes.Buffer
+		if err := h.Export(ctx, &buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	a, b := export([]int{0, 1, 2, 3}), export([]int{3, 2, 1, 0})
+	if !bytes.Equal(a, b) {
+		t.Fatalf("exports differ:\n%s\n%s", a, b)
+	}
+	if i1, i2, i20 := bytes.Index(a, []byte(`"id":1,`)), bytes.Index(a, []byte(`"id":2,`)), bytes.Index(a, []byte(`"id":20,`)); !(i1 < i2 && i2 < i20) {
+		t.Fatalf("not sorted by id:\n%s", a)
+	}
+}
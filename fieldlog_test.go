@@ -0,0 +1,78 @@
+package filestore
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type fieldLog struct {
+	sync.Mutex
+	msgs   []string
+	fields []map[string]interface{}
+}
+
+func (l *fieldLog) Printf(format string, v ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.msgs = append(l.msgs, fmt.Sprintf(format, v...))
+	l.fields = append(l.fields, nil)
+}
+
+func (l *fieldLog) LogFields(msg string, fields map[string]interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.msgs = append(l.msgs, msg)
+	l.fields = append(l.fields, fields)
+}
+
+func (l *fieldLog) find(msg string) map[string]interface{} {
+	l.Lock()
+	defer l.Unlock()
+	for i := len(l.msgs) - 1; i >= 0; i-- {
+		if l.msgs[i] == msg {
+			return l.fields[i]
+		}
+	}
+	return nil
+}
+
+func TestFieldLogger(t *testing.T) {
+	d := tmpdir(t)
+	ctx := context.Background()
+	l := &fieldLog{}
+	h, err := NewHandlerWithOptions(d, "c", WithDebugLog(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{}), mkitem("b", map[string]interface{}{})})
+	saved := l.find("Saved database")
+	if saved == nil || saved["items"] != 2 || saved["bytes"].(int) <= 0 || saved["database"] != h.database_file {
+		t.Fatal(saved)
+	}
+	if _, ok := saved["duration"].(time.Duration); !ok {
+		t.Fatal(saved)
+	}
+	h.Close()
+	l = &fieldLog{}
+	h, _ = NewHandlerWithOptions(d, "c", WithDebugLog(l))
+	read := l.find("Read database")
+	if read == nil || read["items"] != 2 || read["bytes"].(int) <= 0 || read["replayed"] != 0 {
+		t.Fatal(read)
+	}
+	h.Close()
+	// A plain logger gets the fields in the message
+	var buf bytes.Buffer
+	h, _ = NewHandlerWithOptions(d, "c", WithDebugLog(log.New(&buf, "", 0)))
+	h.Close()
+	if !strings.Contains(buf.String(), "Read database "+h.database_file+": bytes=") || !strings.Contains(buf.String(), " items=2 replayed=0") {
+		t.Fatal(buf.String())
+	}
+}
@@ -0,0 +1,35 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestFileMode(t *testing.T) {
+	d := filepath.Join(tmpdir(t), "sub")
+	h, err := NewHandlerWithOptions(d, "c", WithFileMode(0600, 0700))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{})})
+	info, _ := os.Stat(filepath.Join(d, "c"))
+	if info.Mode().Perm() != 0600 {
+		t.Fatal(info.Mode())
+	}
+	dinfo, _ := os.Stat(d)
+	if dinfo.Mode().Perm() != 0700 {
+		t.Fatal(dinfo.Mode())
+	}
+	h.Close()
+	h, _ = NewHandlerWithOptions(d, "c", WithFileMode(0640, 0))
+	h.Insert(context.Background(), []*resource.Item{mkitem("b", map[string]interface{}{})})
+	info, _ = os.Stat(filepath.Join(d, "c"))
+	if info.Mode().Perm() != 0640 {
+		t.Fatal(info.Mode())
+	}
+	h.Close()
+}
@@ -0,0 +1,33 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestStatsFileSize(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < 5; i++ {
+		h.Insert(ctx, []*resource.Item{mkitem(i, map[string]interface{}{"x": "some payload"})})
+		info, err := os.Stat(filepath.Join(d, "c"))
+		if err != nil || info.Size() != h.Stats().FileSize {
+			t.Fatal(info.Size(), h.Stats().FileSize, err)
+		}
+	}
+	size := h.Stats().FileSize
+	h.Close()
+	h, _ = NewHandler(d, "c", nil)
+	defer h.Close()
+	if h.Stats().FileSize != size {
+		t.Fatal(h.Stats().FileSize, size)
+	}
+}
@@ -1,323 +1,1330 @@
 package filestore
 
 import (
-	"bytes"
-	"encoding/gob"
-	"io/ioutil"
-	"log"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/rs/rest-layer/resource"
-	"github.com/rs/rest-layer/rest"
 	"github.com/rs/rest-layer/schema"
 	"golang.org/x/net/context"
 )
 
+// FileStoreHandler stores the items of a collection in memory and persists
+// them to its datafile.
+//
+// Locking: every method changing the items, the ids list, the indexes or the
+// persisted state (Insert, Update, Delete and the other writes, the sweeper,
+// the flusher) holds the write lock while it checks and changes them, including
+// the unique checks. The save holds it too, except with CoalesceWrites, where
+// the writes wait for their save without the lock, and SyncBatchWindow, where
+// they wait for the sync of the log without it. Clear also releases it between
+// its batches with ClearBatchSize. The other writes may then change the memory
+// in the meantime: a write which can't be persisted undoes its own changes by
+// id, see undo.go. The reads (Find, Get, Count...) hold the read lock and don't
+// modify any of these structures. The only state changed under the read lock is
+// the decoded items cache, which has its own mutex and only holds items nobody
+// modifies, see cloneItem, and the atomic Stats counters. The methods suffixed
+// by NoLock expect the caller to hold one of the locks.
 type FileStoreHandler struct {
 	sync.RWMutex
 	// If latency is set, the handler will introduce an artificial latency on
 	// all operations
-	Latency       time.Duration
-	items         map[interface{}][]byte
-	ids           []interface{}
+	Latency time.Duration
+	// If PerItemLatency is set, Insert, Clear and Find also wait this long for
+	// each item they insert, clear or return, on top of Latency
+	PerItemLatency time.Duration
+	// If GenerateETags is set, the items stored without an ETag are given
+	// the ETag of their payload, see ETag, so the conditional updates and
+	// deletes always have one to check
+	GenerateETags bool
+	// If NullsFirst is set, the items missing a sort field, or holding nil,
+	// come first rather than last in both directions
+	NullsFirst bool
+	// If MatchFunc is set, it tells which items match the lookups in place
+	// of the filter of rest-layer, for the operators the filter doesn't have.
+	// The indexes can't tell what it matches and aren't used by the lookups.
+	MatchFunc func(lookup *resource.Lookup, payload map[string]interface{}) bool
+	// DerivedFields are the fields computed from the payloads by their
+	// function and only kept in memory, to filter and sort on values costly
+	// to compute, see derived.go
+	DerivedFields map[string]func(payload map[string]interface{}) interface{}
+	derived       map[interface{}]map[string]interface{}
+	// If ReadOnlyResults is set, Find, MultiGet and All return the items
+	// shared with the cache instead of copies, saving the deep copy of each
+	// item read. The caller must then treat the returned items and their
+	// payloads, including the nested maps and slices, as immutable: a
+	// change would be seen by the other readers and may be stored back by
+	// the next write. A stored item is never changed in place, a write
+	// replaces it, so a returned item stays a consistent snapshot.
+	ReadOnlyResults bool
+	// If OpTimeout is set, each operation is given at most this duration,
+	// the wait for the handler's lock included, on top of the deadline of
+	// its context and fails with context.DeadlineExceeded past it
+	OpTimeout time.Duration
+	// OnOperation, if set, is called at the end of every Insert, Update,
+	// Delete, Clear and Find with the context of the operation, its name in
+	// lowercase, its duration and the number of items it inserted, updated,
+	// deleted, cleared or returned. It is called without holding the
+	// handler's lock.
+	OnOperation func(ctx context.Context, op string, dur time.Duration, n int)
+
+	items map[interface{}][]byte
+	ids   []interface{}
+	// idPos holds the position of each id in ids and idHoles the number of
+	// removed ids, see ids.go
+	idPos         map[interface{}]int
+	idHoles       int
 	directory     string
 	collection    string
 	database_file string
 	UniqueFields  []string
+	// CaseInsensitiveUniqueFields lists UniqueFields whose string values
+	// are compared lowercased and trimmed, so "Foo@x.com " conflicts with
+	// "foo@x.com". The values are stored as given.
+	CaseInsensitiveUniqueFields []string
+	// UniqueCompositeFields lists groups of fields whose combination of
+	// values must be unique, like {"tenant_id", "email"}. Items lacking one
+	// of the fields aren't checked against the group.
+	UniqueCompositeFields [][]string
+	// IndexedFields lists top level fields indexed like the UniqueFields
+	// without being unique, so the lookups made of a single equality or
+	// inclusion on one of them are served from the index
+	IndexedFields []string
+	// SortedIndexes lists top level numeric fields whose items are kept
+	// sorted by value, so the lookups made only of ranges on one of them
+	// only decode the items in the range
+	SortedIndexes []string
+	// If StrictQueries is set, lookups containing constructs the handler
+	// can't evaluate return an error, and so do the finds, counts, distincts
+	// and FindEach filtering on a field with an index which can't serve
+	// them, instead of silently scanning every item
+	StrictQueries bool
+	// MaxResultItems is the number of items from which the finds fail with
+	// ErrTooManyResults instead of copying them all, which only happens to
+	// the queries without pagination or with huge pages.
+	// DefaultMaxResultItems if zero and unlimited if negative.
+	MaxResultItems int
+	// If SkipFindTotals is set, the scans of the finds without sort stop
+	// once they matched enough items to fill the requested page, and report
+	// a Total of -1, unknown, when they stopped before the end. Count gives
+	// the total on demand. Otherwise the whole collection is scanned to
+	// compute an exact Total.
+	SkipFindTotals bool
+	// If SkipInitialLoad is set, the handler starts empty without reading the
+	// datafile, for a loader regenerating the collection from scratch. The
+	// existing items are lost: the datafile is replaced by the first save,
+	// the one made by Close included. It can't be used with PerItemFiles.
+	SkipInitialLoad bool
+	// If SkipCorrupt is set, the items which can't be decoded are skipped by
+	// Find, FindEach, Count and Clear instead of failing them. Each skip is
+	// logged and counted in Stats.Corrupt.
+	SkipCorrupt bool
+	// IDField is the payload field holding the id of the items, set by the
+	// id generators and protected by Merge and Patch. Defaults to
+	// DefaultIDField.
+	IDField string
+	// IDGenerator, if set, is called to assign an id to inserted items with
+	// a zero value id
+	IDGenerator func() (interface{}, error)
+	// If AutoIncrement is set and IDGenerator isn't, inserted items with a
+	// zero value id get the next int of a persisted sequence, see id.go
+	AutoIncrement bool
+	sequence      int
+	// If MergeOnSave is set, changes made to the datafile by another process
+	// are merged before saving instead of being overwritten, see mergeDatafile.
+	// Handlers created by NewHandler lock their datafile, use NewSharedHandler
+	// for handlers sharing one.
+	MergeOnSave bool
+	// If MaxMemoryBytes is set, writes making MemoryBytes exceed it are
+	// rejected, but for Insert, Upsert and ImportJSONL which evict items
+	// according to Eviction like for MaxItems
+	MaxMemoryBytes int64
+	memoryBytes    int64
+	// If MaxItems is set, inserts making the collection hold more items
+	// are rejected or evict items, according to Eviction. access tracks the
+	// accesses for EvictLRU.
+	MaxItems  int
+	Eviction  EvictionPolicy
+	access    accessLog
+	lifecycle lifecycle
+	// closed is set by Shutdown once the final save is done
+	closed      bool
+	bulkLoading bool
+	// txn is the state Rollback restores, set by Begin
+	txn *datafileContent
+	// If PersistIndexes is set, the indexes are saved along the datafile and
+	// loaded from there instead of being rebuilt when the data didn't change
+	PersistIndexes bool
+	// If ClearBatchSize is set, Clear persists its changes by batches of this
+	// size, see Clear
+	ClearBatchSize int
+	// If Compressor is set, the datafile is compressed with it. Compressed
+	// and uncompressed datafiles are both read whatever this setting is.
+	Compressor Compressor
+	// If Compress is set and Compressor is nil, the datafile is compressed
+	// with GzipCompressor
+	Compress bool
+	// If RecoverFromCorruption is set, a datafile which can't be decoded
+	// when the handler is created is moved aside and the handler starts
+	// empty instead of returning the error, see quarantineDatafile
+	RecoverFromCorruption bool
+	// IORetries is the number of times the read or write of the datafile is
+	// tried again when it fails with a transient error, waiting IORetryDelay,
+	// DefaultIORetryDelay if zero, before the first retry and twice as long
+	// before each following one. Other errors fail at once.
+	IORetries    int
+	IORetryDelay time.Duration
+	// FileSystem holds the datafile, the OS filesystem if nil. On another
+	// FileSystem the handler doesn't take the lock file of NewHandler and
+	// WAL and PersistIndexes aren't used, PerItemFiles are always on the OS
+	// filesystem.
+	FileSystem FileSystem
+	// If Durable is set, which NewHandlerWithOptions does by default, the
+	// files are synced on every save so a save is never lost, even to a
+	// power failure. Without it, a save is only durable once the system
+	// writes it back, see Sync.
+	Durable bool
+	// FileMode is the permissions of the datafile and of the other files of
+	// the handler, set on every write, DefaultFileMode if zero. DirMode is
+	// the permissions of the directories created by the handler,
+	// DefaultDirMode if zero.
+	FileMode os.FileMode
+	DirMode  os.FileMode
+	// MaxPayloadDepth limits how deep maps and slices can be nested in a
+	// stored payload, DefaultMaxPayloadDepth if zero
+	MaxPayloadDepth int
+	// If ValidatePayload is set, the payloads holding values which would
+	// break the responses or comparisons, like NaN or infinite floats, are
+	// rejected, see checkScalar
+	ValidatePayload bool
+	// NormalizedFields lists the fields maintained with a lowercased shadow
+	// field named after NormalizedSuffix ("name" gets "name_lc" by default)
+	// on every store. The shadow fields can be filtered, sorted and indexed
+	// for case insensitive lookups while the original field keeps its case.
+	NormalizedFields []string
+	NormalizedSuffix string
+	// If HideNormalizedFields is set, the shadow fields are removed from the
+	// items returned by the handler
+	HideNormalizedFields bool
+	// ChangeLogSize is the number of changes retained for ChangesSince,
+	// DefaultChangeLogSize if zero and none if negative
+	ChangeLogSize int
+	changes       changeLog
+	// Codec serializes the stored items, GobCodec if nil. Items written
+	// with another codec are still read whatever this setting is.
+	Codec Codec
+	// If SoftDelete is set, Delete and Clear only mark the items deleted,
+	// see softdelete.go
+	SoftDelete bool
+	// If TTL is set, items expire TTL after their Updated time, see
+	// expired
+	TTL time.Duration
+	// sweeping is set once the worker removing expired items is started
+	sweeping bool
+	// If FlushInterval is set, writes are only persisted by a background
+	// worker, at most once per interval, see Flush
+	FlushInterval time.Duration
+	// dirty is set when the memory holds changes not saved yet
+	dirty    bool
+	flushing bool
+	// If RestartWorkers is set, a background worker which panics is
+	// restarted. Otherwise it stays stopped and the handler is degraded: the
+	// writes are saved synchronously even with FlushInterval set and the
+	// expired items are hidden but no longer removed. The panics are logged and
+	// counted in Stats either way.
+	RestartWorkers bool
+	degraded       bool
+	// ParallelScanThreshold is the number of items from which the scans of
+	// Find are spread over all the CPUs, DefaultParallelScanThreshold if zero
+	// and never if negative
+	ParallelScanThreshold int
+	// subscribers are the channels returned by Subscribe and pending the
+	// events waiting for their change to be saved, numbered by eventSeq
+	subscribers []chan Event
+	pending     []pendingEvent
+	eventSeq    uint64
+	// cache holds the decoded items
+	cache itemCache
+	// indexes holds the index of each unique field
+	indexes map[string]*fieldIndex
+	// sortedIndexes holds the index of each field of SortedIndexes
+	sortedIndexes map[string]*sortedIndex
+	// encryptionKey is the AES key the datafile is encrypted with, see
+	// NewEncryptedHandler
+	encryptionKey []byte
+	// shared is set when the datafile isn't locked, see NewSharedHandler
+	shared bool
+	// If ReadOnly is set, the writes fail with ErrReadOnly and the handler
+	// never writes to its directory, which only needs to be readable: the
+	// datafile isn't locked, the expired items aren't removed from it and it
+	// can't be recovered from corruption.
+	ReadOnly bool
+	// Clock tells the time used to stamp and expire the items, the system
+	// time if nil
+	Clock Clock
+	// If Timestamps is set, the items are stamped with the time of their
+	// insertion and last write, see CreatedAtField
+	Timestamps bool
+	// If PerItemFiles is set, each item is stored in its own file, see
+	// itemfiles.go. changedItems are the ids whose file must be written or
+	// removed by the next save, changedOrder lists them in the order of their
+	// first change with OrderedWrites.
+	PerItemFiles bool
+	changedItems map[interface{}]bool
+	changedOrder []interface{}
+	// If OrderedWrites is set with PerItemFiles, a save writes the item files
+	// in the order the items were changed, so a consumer watching the
+	// directory gets a batch in its input order. The datafile and the WAL
+	// always keep the order of the writes.
+	OrderedWrites bool
+	// BlobThreshold is the size from which the top level []byte fields are
+	// stored in sidecar files rather than in the records, never if zero, see
+	// blob.go
+	BlobThreshold int
+	blobs         blobCounts
+	// If OffloadRecords is set with PerItemFiles, the records aren't kept in
+	// memory once saved but read from their file when needed, trading I/O
+	// for memory, see offload.go
+	OffloadRecords bool
+	// If WAL is set, the writes append their changes to a write-ahead log
+	// instead of rewriting the datafile, which is rewritten once the log
+	// holds WALCompactSize changes (DefaultWALCompactSize if zero), see
+	// wal.go
+	WAL            bool
+	WALCompactSize int
+	wal            walState
+	// Backups is the number of previous datafiles kept next to the datafile,
+	// named after it with the suffixes .1 (the most recent) to .Backups. Each
+	// full rewrite of the datafile rotates them. See RollbackToBackup.
+	Backups int
+	// If CoalesceWrites is set, the concurrent writes share the saves of the
+	// datafile, see coalesce.go
+	CoalesceWrites bool
+	coalescer      coalescer
+	// If SyncBatchWindow is set, with Durable, the writes arriving within
+	// this duration share a single sync of the log of WAL or a single save
+	// of CoalesceWrites, see groupsync.go
+	SyncBatchWindow time.Duration
+	syncer          groupSyncer
+	// saveGen numbers the saves of the datafile and writtenGen is the last
+	// one written, writeMu serializes the writes of the datafile which the
+	// flusher makes without holding the handler's lock
+	saveGen    uint64
+	writeMu    sync.Mutex
+	writtenGen uint64
+	// Logger receives the errors and warnings of the handler, nothing is
+	// logged if nil. If DebugLog is set, it also receives messages about the
+	// routine work like every save, the loads and the saves with their
+	// counts, sizes and durations, see FieldLogger.
+	Logger   Logger
+	DebugLog bool
+	// fileStamp identifies the datafile version last read or written
+	fileStamp fileStamp
+	// lastSave is the time of the last successful save and counters the
+	// operations counted for Stats
+	lastSave time.Time
+	counters *opCounters
+}
+
+// DefaultMaxResultItems is the number of items a find can return when
+// MaxResultItems isn't set
+const DefaultMaxResultItems = 100000
+
+// The rest-layer interfaces implemented by the handler
+var (
+	_ resource.Storer      = (*FileStoreHandler)(nil)
+	_ resource.MultiGetter = (*FileStoreHandler)(nil)
+)
+
+// NewHandler creates a handler storing the collection in a datafile of
+// directory, loading the existing items if the datafile exists. An error is
+// returned if the directory can't be created, the datafile can't be read or
+// decoded, or is already opened by another handler (ErrLocked). The datafile
+// stays locked until the handler is closed. See NewHandlerWithOptions for the
+// other settings.
+func NewHandler(directory string, collection string, uniqueFields []string) (*FileStoreHandler, error) {
+	return NewHandlerWithOptions(directory, collection, WithUniqueFields(uniqueFields...))
+}
+
+// NewSharedHandler creates a handler like NewHandler for a datafile shared
+// with other handlers: the datafile isn't locked and MergeOnSave is set so
+// the changes written by the others aren't overwritten.
+func NewSharedHandler(directory string, collection string, uniqueFields []string) (*FileStoreHandler, error) {
+	return NewHandlerWithOptions(directory, collection, WithUniqueFields(uniqueFields...), WithShared())
+}
+
+// open loads the datafile
+func (self *FileStoreHandler) open() error {
+	self.rebuildIndexes()
+	if self.SkipInitialLoad {
+		// The first persist rewrites the datafile, which isn't loaded so the
+		// log can't follow it, and removes the log of the replaced one
+		self.wal.onDisk = true
+		self.wal.full = true
+		self.debugf("Skipped loading database %s", self.database_file)
+		return nil
+	}
+	return self.readDatafile()
+}
+
+// openLocked locks the datafile and loads it, the lock is released by Close
+func (self *FileStoreHandler) openLocked() error {
+	release, err := self.lockDatafile()
+	if err != nil {
+		return err
+	}
+	if err := self.open(); err != nil {
+		release()
+		return err
+	}
+	self.onShutdown(release)
+	return nil
 }
 
-func init() {
-	gob.Register(map[string]interface{}{})
-	gob.Register(time.Time{})
+// MustNewHandler is like NewHandler but panics on error. It eases the
+// migration of the callers of NewHandler from before it returned an error.
+func MustNewHandler(directory string, collection string, uniqueFields []string) *FileStoreHandler {
+	f, err := NewHandler(directory, collection, uniqueFields)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// NewSlowHandler creates a handler like NewHandler with the specified latency,
+// to simulate a slow storage in the tests of a REST API
+func NewSlowHandler(directory string, collection string, latency time.Duration) (*FileStoreHandler, error) {
+	return NewHandlerWithOptions(directory, collection, WithLatency(latency))
 }
 
-// NewHandler creates an empty memory handler
-func NewHandler(directory string, collection string, uniqueFields []string) *FileStoreHandler {
-	os.MkdirAll(directory, 0664)
-	f := &FileStoreHandler{
+// NewMemoryHandler creates an empty memory handler with specified latency. It
+// has no datafile: its items only live in memory, nothing is ever persisted
+// and the disk is never touched.
+func NewMemoryHandler(latency time.Duration) *FileStoreHandler {
+	f := newHandler("", "")
+	f.Latency = latency
+	return f
+}
+
+// newHandler returns an empty handler storing the collection in a datafile of
+// directory, or only in memory if both are empty. Nothing is loaded.
+func newHandler(directory string, collection string) *FileStoreHandler {
+	return &FileStoreHandler{
 		items:         map[interface{}][]byte{},
 		ids:           []interface{}{},
 		directory:     directory,
 		collection:    collection,
-		database_file: directory + "/" + collection,
-		UniqueFields:  uniqueFields,
+		database_file: filepath.Join(directory, collection),
+		indexes:       map[string]*fieldIndex{},
+		changes:       newChangeLog(),
+		counters:      &opCounters{},
+		Durable:       true,
 	}
-	f.readDatafile()
-	return f
 }
 
-// NewSlowHandler creates an empty memory handler with specified latency
-func NewSlowHandler(latency time.Duration) *FileStoreHandler {
-	return &FileStoreHandler{
-		Latency: latency,
-		items:   map[interface{}][]byte{},
-		ids:     []interface{}{},
-	}
+// inMemory tells if the handler has no datafile to persist its items to
+func (self *FileStoreHandler) inMemory() bool {
+	return self.database_file == ""
 }
 
-func (self *FileStoreHandler) readDatafile() {
-	if _, err := os.Stat(self.database_file); os.IsNotExist(err) {
-		log.Println("Database " + self.database_file + " doesn't exist for collection " + self.collection)
-		return
+func (self *FileStoreHandler) readDatafile() error {
+	if self.inMemory() {
+		return nil
+	}
+	if self.PerItemFiles {
+		return self.readItemFiles()
+	}
+	if _, err := self.fileSystem().Stat(self.database_file); os.IsNotExist(err) {
+		self.debugf("Database %s doesn't exist for collection %s", self.database_file, self.collection)
+		replayed, err := self.replayWAL(nil)
+		if err == nil && replayed > 0 && !self.ReadOnly {
+			err = self.saveDatafile()
+		}
+		return err
 	}
 
-	data, err := ioutil.ReadFile(self.database_file)
+	start := time.Now()
+	var data []byte
+	err := self.retryIO(func() (err error) {
+		data, err = self.fileSystem().ReadFile(self.database_file)
+		return err
+	})
 
 	if err != nil {
-		log.Println("Error reading database file " + self.database_file)
-		panic(err)
+		self.logf("Error reading database file %s: %v", self.database_file, err)
+		return err
 	}
 
-	dec := gob.NewDecoder(bytes.NewBuffer(data))
-
-	var items map[interface{}][]byte
-	if err := dec.Decode(&items); err != nil {
-		log.Println("Error reading database file " + self.database_file)
-		panic(err)
+	if err := self.decodeDatafile(data); err != nil {
+		if self.RecoverFromCorruption && !self.ReadOnly && err != ErrEncrypted && !errors.Is(err, ErrUnsupportedVersion) {
+			return self.quarantineDatafile(err)
+		}
+		self.logf("Error reading database file %s: %v", self.database_file, err)
+		return err
 	}
-
-	for k := range self.items {
-		delete(self.items, k)
+	self.stampDatafile()
+	self.setFileSize(data)
+	replayed, err := self.replayWAL(data)
+	if err != nil {
+		return err
+	}
+	self.debugFields("Read database", map[string]interface{}{
+		"items":    self.idCount(),
+		"bytes":    len(data),
+		"replayed": replayed,
+		"duration": time.Since(start),
+	})
+	if version, _, _ := splitHeader(data); (version < DatafileVersion || replayed > 0) && !self.ReadOnly {
+		// Migrate the datafile to the current version or write the changes
+		// of the log to it
+		return self.saveDatafile()
 	}
+	return nil
+}
 
-	self.ids = nil
+func (self *FileStoreHandler) saveDatafile() error {
+	if self.inMemory() {
+		self.publish()
+		return nil
+	}
 
-	for k, v := range items {
-		self.items[k] = v
-		self.ids = append(self.ids, k)
+	start := time.Now()
+	if self.PerItemFiles {
+		changed := len(self.changedItems)
+		if err := self.saveItemFiles(); err != nil {
+			return err
+		}
+		self.removeGarbageBlobs()
+		self.lastSave = self.now()
+		self.dirty = false
+		self.publish()
+		self.debugFields("Saved database", map[string]interface{}{
+			"items":    self.idCount(),
+			"changed":  changed,
+			"duration": time.Since(start),
+		})
+		return nil
 	}
-	log.Println("Read database " + self.database_file)
-}
 
-func (self *FileStoreHandler) saveDatafile() {
+	if self.MergeOnSave {
+		if err := self.mergeDatafile(); err != nil {
+			return err
+		}
+	}
 
-	encoded_items, err := self.serialize(&self.items)
+	encoded_items, err := self.encodeDatafile()
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	err = ioutil.WriteFile(self.database_file, encoded_items, 0644)
+	self.saveGen++
+	err = self.writeDatafile(self.saveGen, encoded_items)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
+	self.stampDatafile()
+	self.removeGarbageBlobs()
+	self.lastSave = self.now()
+	self.saveIndexes(encoded_items)
+	self.truncateWAL(encoded_items)
+	self.dirty = false
+	self.publish()
 
-	log.Println("Saved database " + self.database_file)
+	self.debugFields("Saved database", map[string]interface{}{
+		"items":    self.idCount(),
+		"bytes":    len(encoded_items),
+		"duration": time.Since(start),
+	})
+	return nil
+}
 
+// needsSave tells if the memory holds changes the datafile doesn't have yet:
+// changes waiting for a flush or the end of a bulk load, item files to write
+// or changes only in the log
+func (self *FileStoreHandler) needsSave() bool {
+	return self.dirty || self.persistSuspended() || len(self.changedItems) > 0 || len(self.wal.pending) > 0 || self.wal.full || self.wal.size > 0
 }
 
-func (self *FileStoreHandler) persistData() {
-	self.saveDatafile()
-	self.readDatafile()
+// persistData writes the in-memory state to disk
+func (self *FileStoreHandler) persistData() error {
+	if self.persistSuspended() {
+		return nil
+	}
+	if self.FlushInterval > 0 && !self.inMemory() && !self.degraded {
+		self.dirty = true
+		self.startFlusher()
+		return nil
+	}
+	if self.walEnabled() {
+		return self.appendWAL()
+	}
+	if self.coalescing() {
+		return self.coalesce()
+	}
+	// The memory stays authoritative, there's no need to read back what was
+	// just written
+	return self.saveDatafile()
 }
 
-// store serialize the item using gob and store it in the handler's items map
+// store serialize the item with the handler's codec and store it in the handler's items map.
+// Only the memory is updated, the caller is responsible of persisting the
+// change with persistData.
 func (self *FileStoreHandler) store(item *resource.Item) error {
-	encoded_item, err := self.serialize(&item)
-
+	item, encoded_item, err := self.encode(item)
 	if err != nil {
 		return err
 	}
-	self.items[item.ID] = encoded_item
+	if err := self.checkMemory(item.ID, encoded_item); err != nil {
+		return err
+	}
+	self.storeRecord(item, encoded_item)
+	return nil
+}
 
-	self.persistData()
+// encode tags, stamps, normalizes and validates an item and returns it along
+// with its record, the handler is left untouched
+func (self *FileStoreHandler) encode(item *resource.Item) (*resource.Item, []byte, error) {
+	return self.encodeWith(item, self.encodeRecord)
+}
 
-	return nil
+// encodeWith is encode making the record with record
+func (self *FileStoreHandler) encodeWith(item *resource.Item, record func(*resource.Item) ([]byte, error)) (*resource.Item, []byte, error) {
+	item, err := self.generateETag(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	item = self.normalize(self.stamp(item))
+	if err := self.checkPayload(item.Payload); err != nil {
+		return nil, nil, err
+	}
+	encoded, err := record(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	return item, encoded, nil
 }
 
-func (self *FileStoreHandler) serialize(item interface{}) ([]byte, error) {
-	var data bytes.Buffer
-	enc := gob.NewEncoder(&data)
-	if err := enc.Encode(item); err != nil {
-		return nil, err
+// storeRecord stores the record of an item returned by encode
+func (self *FileStoreHandler) storeRecord(item *resource.Item, record []byte) {
+	op := ChangeInsert
+	old, found := self.eventRecord(item.ID)
+	if found {
+		op = ChangeUpdate
+	}
+	self.setRecord(item.ID, record)
+	self.advanceSequence(item.ID)
+	self.indexItem(self.withDerivedFields(item))
+	self.touch(item.ID)
+	self.recordChange(op, item.ID, record)
+	self.notify(op, item.ID, record, old)
+	if self.TTL > 0 {
+		self.startSweeper()
 	}
-	return data.Bytes(), nil
 }
 
-// fetch unserialize item's data and return a new item
+// serialize encodes the handler's own structures, like the datafile layout,
+// with gob whatever the codec of the records is
+func (self *FileStoreHandler) serialize(v interface{}) ([]byte, error) {
+	return GobCodec{}.Marshal(v)
+}
+
+// fetch unserialize item's data and return a new item owned by the caller
 func (self *FileStoreHandler) fetch(id interface{}) (*resource.Item, bool, error) {
-	data, found := self.items[id]
-	if !found {
-		return nil, false, nil
-	}
-	dec := gob.NewDecoder(bytes.NewBuffer(data))
-	var item resource.Item
-	if err := dec.Decode(&item); err != nil {
-		return nil, true, err
+	item, found, err := self.peek(id)
+	if item == nil {
+		return nil, found, err
 	}
-	return &item, true, nil
+	self.touch(id)
+	clone := cloneItem(item)
+	self.dropDerivedFields(clone.Payload)
+	return clone, true, nil
 }
 
-// delete removes an item by this id with no look
+// delete removes an item by this id with no look, the caller is responsible of
+// persisting the change. The id is compared the same
+// way the items map does, by dynamic type and value.
 func (self *FileStoreHandler) delete(id interface{}) {
-	delete(self.items, id)
-	// Remove id from id list
-	for i, _id := range self.ids {
-		if _id == id {
-			if i >= len(self.ids)-1 {
-				self.ids = self.ids[:i]
-			} else {
-				self.ids = append(self.ids[:i], self.ids[i+1:]...)
-			}
-			break
-		}
-	}
-	self.persistData()
+	old, _ := self.eventRecord(id)
+	self.removeRecord(id)
+	self.unindexItem(id)
+	self.recordChange(ChangeDelete, id, nil)
+	self.notify(ChangeDelete, id, nil, old)
+	self.removeID(id)
 }
 
 // Insert inserts new items in memory
+//
+// The insert is all or nothing: the items are all encoded before any of them
+// is stored, and the stored items are removed again if the datafile can't be
+// saved. The items evicted to make room for them, see MaxItems, stay evicted.
 func (self *FileStoreHandler) Insert(ctx context.Context, items []*resource.Item) (err error) {
+	defer func(start time.Time) { self.observe(ctx, "insert", start, len(items), err) }(time.Now())
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
 	self.Lock()
 	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
 	err = handleWithLatency(self.Latency, ctx, func() error {
-
+		if err := self.waitItems(ctx, len(items)); err != nil {
+			return err
+		}
+		self.canonicalizeIDs(items...)
+		if err := self.generateIDs(items); err != nil {
+			return err
+		}
+		seen := map[interface{}]bool{}
 		for _, item := range items {
-			if _, found := self.items[item.ID]; found {
+			// Checked first as the map of seen ids can't hold any id
+			if err := checkID(item.ID); err != nil {
+				return err
+			}
+			if seen[item.ID] {
 				return resource.ErrConflict
 			}
+			seen[item.ID] = true
+			invalid, err := self.validateInsert(ctx, item)
+			if err != nil {
+				return err
+			}
+			if invalid != nil {
+				return invalid
+			}
+		}
 
-			for _, uniqueField := range self.UniqueFields {
-				lookup := resource.NewLookup()
-				queries := schema.Query{}
-				queries = append(queries, schema.Equal{Field: uniqueField, Value: item.Payload[uniqueField]})
-				lookup.AddQuery(queries)
-				res, err := self.findNoLock(ctx, lookup, 1, -1)
-				if err != nil {
-					return err
-				}
-
-				if len(res.Items) > 0 {
-					return &rest.Error{422, "Unique precondition failed on field '" + uniqueField + "'", nil}
-				}
+		// Stage all the records so a failure doesn't leave the batch half
+		// inserted
+		staged := make([]*resource.Item, len(items))
+		records := make([][]byte, len(items))
+		for i, item := range items {
+			if staged[i], records[i], err = self.encode(item); err != nil {
+				return err
 			}
+		}
+		added, grow, keep, err := self.checkBatch(staged, records)
+		if err != nil {
+			return err
+		}
+		if err := self.makeRoom(added, grow, keep); err != nil {
+			return err
+		}
 
+		for _, item := range staged {
+			if _, found := self.items[item.ID]; found {
+				// Replaces an expired or soft deleted item
+				self.delete(item.ID)
+			}
 		}
-		for _, item := range items {
+		undo := self.beginUndo()
+		for i, item := range staged {
 			// Store ids in ordered slice for sorting
-			self.ids = append(self.ids, item.ID)
+			self.appendID(item.ID)
+			self.storeRecord(item, records[i])
+			undo.change(item.ID, nil)
+		}
+		self.sealUndo(undo)
+		if err := self.persistData(); err != nil {
+			self.undo(undo)
+			return err
+		}
+		count(&self.counters.inserts, len(staged))
+		return nil
+	})
+	return err
+}
+
+func (self *FileStoreHandler) validateInsert(ctx context.Context, item *resource.Item) (invalid error, err error) {
+	if err := checkID(item.ID); err != nil {
+		return err, nil
+	}
+	if isZeroID(item.ID) {
+		if self.IDGenerator == nil && !self.AutoIncrement {
+			return ErrMissingID, nil
+		}
+		// The id will be generated on insert
+	} else if _, found, _ := self.peek(item.ID); found {
+		return resource.ErrConflict, nil
+	}
+
+	return self.checkUnique(ctx, item)
+}
 
-			if err := self.store(item); err != nil {
+// validateUniqueFields checks that the UniqueFields and UniqueCompositeFields
+// name top level fields of the payload, the only ones the unique checks and
+// the indexes look at
+func (self *FileStoreHandler) validateUniqueFields() error {
+	check := func(field string) error {
+		if field == "" {
+			return fmt.Errorf("filestore: empty unique field name")
+		}
+		if strings.Contains(field, ".") {
+			return fmt.Errorf("filestore: unique field '%s' isn't a top level field", field)
+		}
+		return nil
+	}
+	seen := map[string]bool{}
+	for _, field := range self.UniqueFields {
+		if err := check(field); err != nil {
+			return err
+		}
+		if seen[field] {
+			return fmt.Errorf("filestore: unique field '%s' listed twice", field)
+		}
+		seen[field] = true
+	}
+	for _, fields := range self.UniqueCompositeFields {
+		for _, field := range fields {
+			if err := check(field); err != nil {
 				return err
 			}
 		}
+	}
+	for _, field := range append(append([]string(nil), self.IndexedFields...), self.SortedIndexes...) {
+		if err := check(field); err != nil {
+			return err
+		}
+	}
+	for _, field := range self.CaseInsensitiveUniqueFields {
+		if !seen[field] {
+			return fmt.Errorf("filestore: case insensitive field '%s' isn't a unique field", field)
+		}
+	}
+	return nil
+}
+
+// checkUnique tells if item has the same values as another stored item for
+// one of the UniqueFields or UniqueCompositeFields. The stored item with the
+// same id as item, the one it replaces, isn't a conflict. An item lacking a
+// unique field, or holding nil in it, never conflicts on this field.
+func (self *FileStoreHandler) checkUnique(ctx context.Context, item *resource.Item) (invalid error, err error) {
+	for _, uniqueField := range self.UniqueFields {
+		value, found := item.Payload[uniqueField]
+		if !found || value == nil {
+			continue
+		}
+		idx := self.fieldIndex(uniqueField)
+		if idx == nil && self.caseInsensitive(uniqueField) {
+			// A lookup can't match folded values, index them on the fly
+			if idx, err = self.buildIndex(uniqueField); err != nil {
+				return nil, err
+			}
+		}
+		if idx != nil {
+			// Resolve the check from the index without decoding any item
+			for _, id := range self.visible(idx.lookup(value)) {
+				if id != item.ID {
+					return uniqueViolation(uniqueField), nil
+				}
+			}
+			continue
+		}
+		lookup := resource.NewLookup()
+		queries := schema.Query{}
+		queries = append(queries, schema.Equal{Field: uniqueField, Value: value})
+		lookup.AddQuery(queries)
+		res, err := self.findNoLock(ctx, lookup, 1, -1)
+		if err != nil {
+			return nil, err
+		}
+
+		if conflicting(res, item.ID) {
+			return uniqueViolation(uniqueField), nil
+		}
+	}
+
+	for _, fields := range self.UniqueCompositeFields {
+		if len(fields) == 0 {
+			continue
+		}
+		group := schema.And{}
+		for _, field := range fields {
+			if value := item.Payload[field]; value != nil {
+				group = append(group, schema.Equal{Field: field, Value: value})
+			}
+		}
+		if len(group) < len(fields) {
+			continue
+		}
+		lookup := resource.NewLookup()
+		lookup.AddQuery(schema.Query{group})
+		res, err := self.findNoLock(ctx, lookup, 1, -1)
+		if err != nil {
+			return nil, err
+		}
+
+		if conflicting(res, item.ID) {
+			return uniqueViolation(fields...), nil
+		}
+	}
+	return nil, nil
+}
+
+// checkBatchUnique tells if two items of a batch written at once have the same
+// values for one of the UniqueFields or UniqueCompositeFields, which
+// checkUnique can't see as it checks each of them against the stored items
+// only
+func (self *FileStoreHandler) checkBatchUnique(items []*resource.Item) error {
+	if len(items) < 2 {
 		return nil
-	})
-	return err
+	}
+	for _, field := range self.UniqueFields {
+		idx := self.newFieldIndex(field)
+		for _, item := range items {
+			if value := item.Payload[field]; value != nil && len(idx.lookup(value)) > 0 {
+				return uniqueViolation(field)
+			}
+			idx.add(item.ID, item.Payload, field)
+		}
+	}
+	for _, fields := range self.UniqueCompositeFields {
+		if len(fields) == 0 {
+			continue
+		}
+		seen := map[interface{}]bool{}
+		for _, item := range items {
+			values := make([]interface{}, 0, len(fields))
+			for _, field := range fields {
+				if value := item.Payload[field]; value != nil {
+					values = append(values, value)
+				}
+			}
+			if len(values) < len(fields) {
+				continue
+			}
+			key := indexKey(values)
+			if seen[key] {
+				return uniqueViolation(fields...)
+			}
+			seen[key] = true
+		}
+	}
+	return nil
 }
 
-// Update replace an item by a new one in memory
+// checkBatch runs the checks of a write of the staged items which involve the
+// whole batch, records being their records: the unique values among the
+// items, MaxItems and MaxMemoryBytes. It returns the number of items the write
+// adds, the growth of the memory of the records and the ids to keep when
+// making room for them with makeRoom. The handler is left untouched.
+func (self *FileStoreHandler) checkBatch(staged []*resource.Item, records [][]byte) (added int, grow int64, keep map[interface{}]bool, err error) {
+	if invalid := self.checkBatchUnique(staged); invalid != nil {
+		return 0, 0, nil, invalid
+	}
+	keep = make(map[interface{}]bool, len(staged))
+	for i, item := range staged {
+		grow += recordSize(records[i])
+		if old, found := self.items[item.ID]; found {
+			grow -= recordSize(old)
+		} else {
+			added++
+		}
+		keep[item.ID] = true
+	}
+	if err := self.checkRoom(added, grow, keep); err != nil {
+		return 0, 0, nil, err
+	}
+	return added, grow, keep, nil
+}
+
+// conflicting tells if list holds an item other than the one with id
+func conflicting(list *resource.ItemList, id interface{}) bool {
+	for _, item := range list.Items {
+		if item.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// Update replace an item by a new one in memory. The ETag of original must
+// match the stored item's one, unless it is empty which makes the update
+// unconditional.
 func (self *FileStoreHandler) Update(ctx context.Context, item *resource.Item, original *resource.Item) (err error) {
+	defer func(start time.Time) { self.observe(ctx, "update", start, 1, err) }(time.Now())
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
 	self.Lock()
 	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
 	err = handleWithLatency(self.Latency, ctx, func() error {
-		o, found, err := self.fetch(original.ID)
+		self.canonicalizeIDs(item, original)
+		// Checked first as the maps can't hold any id
+		if err := checkID(original.ID); err != nil {
+			return err
+		}
+		if err := checkID(item.ID); err != nil {
+			return err
+		}
+		o, found, err := self.peek(original.ID)
+		if err != nil {
+			return err
+		}
 		if !found {
 			return resource.ErrNotFound
 		}
+		if original.ETag != "" && original.ETag != o.ETag {
+			return resource.ErrConflict
+		}
+		invalid, err := self.checkUnique(ctx, item)
 		if err != nil {
 			return err
 		}
-		if original.ETag != o.ETag {
-			return resource.ErrConflict
+		if invalid != nil {
+			return invalid
 		}
 		if err := self.store(item); err != nil {
 			return err
 		}
-		return nil
+		return self.persistData()
 	})
+	if err == nil {
+		count(&self.counters.updates, 1)
+	}
 	return err
 }
 
-// Delete deletes an item from memory
+// Delete deletes an item from memory. Like for Update, an empty ETag makes the
+// delete unconditional.
 func (self *FileStoreHandler) Delete(ctx context.Context, item *resource.Item) (err error) {
+	defer func(start time.Time) { self.observe(ctx, "delete", start, 1, err) }(time.Now())
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
 	self.Lock()
 	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
 	err = handleWithLatency(self.Latency, ctx, func() error {
-		o, found, err := self.fetch(item.ID)
-		if !found {
-			return resource.ErrNotFound
+		// Checked first as the maps can't hold any id
+		if err := checkID(item.ID); err != nil {
+			return err
 		}
+		o, found, err := self.peek(item.ID)
 		if err != nil {
 			return err
 		}
-		if item.ETag != o.ETag {
+		if !found {
+			return resource.ErrNotFound
+		}
+		if item.ETag != "" && item.ETag != o.ETag {
 			return resource.ErrConflict
 		}
-		self.delete(item.ID)
-		return nil
+		if err := self.remove(o); err != nil {
+			return err
+		}
+		return self.persistData()
 	})
+	if err == nil {
+		count(&self.counters.deletes, 1)
+	}
 	return err
 }
 
-// Clear clears all items from the memory store matching the lookup
+// Clear clears all items from the memory store matching the lookup. The
+// matching items are all removed from the memory first and the datafile is
+// then saved once, nothing is saved if no item matches. The items are restored
+// if the datafile can't be saved.
+//
+// If ClearBatchSize is set, the matching items are removed and persisted by
+// batches of this size and the lock is released in between so other operations
+// aren't blocked for the whole clear. The clear is then no longer atomic: other
+// operations may see it half done, items inserted while it runs aren't cleared
+// and a failure leaves the batches already persisted cleared, the items of the
+// batch which failed are restored.
+//
+// If ctx is canceled during the scan, the clear stops and the items already
+// removed stay removed.
 func (self *FileStoreHandler) Clear(ctx context.Context, lookup *resource.Lookup) (total int, err error) {
+	defer func(start time.Time) { self.observe(ctx, "clear", start, total, err) }(time.Now())
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
 	self.Lock()
 	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return 0, err
+	}
+	if err := self.checkLookup(lookup); err != nil {
+		return 0, err
+	}
 	err = handleWithLatency(self.Latency, ctx, func() error {
-		ids := make([]interface{}, len(self.ids))
-		copy(ids, self.ids)
-		for _, id := range ids {
-			item, _, err := self.fetch(id)
-			if err != nil {
+		ids := self.snapshotIDs()
+		// undo records the changes of the current batch
+		undo := self.beginUndo()
+		undo.ids = ids
+		fail := func(err error) error {
+			if undo.changed() {
+				self.undo(undo)
+			}
+			return err
+		}
+		persist := func() error {
+			self.sealUndo(undo)
+			if err := self.persistData(); err != nil {
+				self.undo(undo)
+				total -= len(undo.old)
 				return err
 			}
-			if !lookup.Filter().Match(item.Payload) {
+			return nil
+		}
+		stop := func(err error) error {
+			if undo.changed() {
+				// Don't leave the items already removed unpersisted
+				if err := persist(); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+		for i, id := range ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return stop(err)
+			}
+			item, found, err := self.peekValid(id)
+			if err != nil {
+				return fail(err)
+			}
+			if !found {
+				// Deleted by another operation in between two batches or
+				// expired
+				continue
+			}
+			if !self.match(lookup, item.Payload) {
 				continue
 			}
-			self.delete(item.ID)
+			if err := self.waitItems(ctx, 1); err != nil {
+				return stop(err)
+			}
+			record := self.items[id]
+			if err := self.remove(item); err != nil {
+				return fail(err)
+			}
+			undo.change(id, record)
 			total++
+			if self.ClearBatchSize > 0 && len(undo.old) >= self.ClearBatchSize {
+				if err := persist(); err != nil {
+					return err
+				}
+				// Let other operations run in between batches
+				self.Unlock()
+				self.Lock()
+				undo = self.beginUndo()
+				undo.ids = self.snapshotIDs()
+			}
 		}
-		return nil
+		return stop(nil)
 	})
-	self.persistData()
+	count(&self.counters.deletes, total)
 	return total, err
 }
 
-// Find items from memory matching the provided lookup
+// Find items from memory matching the provided lookup. All the matching items
+// are returned if perPage < 0, none of them if perPage == 0, in which case
+// only the total is computed, and the page of perPage items otherwise.
 func (self *FileStoreHandler) Find(ctx context.Context, lookup *resource.Lookup, page, perPage int) (list *resource.ItemList, err error) {
+	defer func(start time.Time) { self.observe(ctx, "find", start, listLen(list), err) }(time.Now())
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
 	self.RLock()
 	defer self.RUnlock()
-	return self.findNoLock(ctx, lookup, page, perPage)
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	w := pageWindow(page, perPage)
+	w.shared = self.ReadOnlyResults
+	list, err = self.findWindow(ctx, lookup, w)
+	count(&self.counters.finds, 1)
+	if list != nil {
+		for i, item := range list.Items {
+			list.Items[i] = self.present(item)
+		}
+	}
+	return list, err
 }
 
+// findNoLock runs the lookups built by the handler itself, like the ones of
+// checkUnique, which StrictQueries doesn't apply to
 func (self *FileStoreHandler) findNoLock(ctx context.Context, lookup *resource.Lookup, page, perPage int) (list *resource.ItemList, err error) {
+	return self.search(ctx, lookup, pageWindow(page, perPage), false)
+}
+
+// findWindow returns the window w of the items matching the lookup
+func (self *FileStoreHandler) findWindow(ctx context.Context, lookup *resource.Lookup, w window) (list *resource.ItemList, err error) {
+	if err := self.checkLookup(lookup); err != nil {
+		return nil, err
+	}
+	return self.search(ctx, lookup, w, true)
+}
+
+// search returns the window w of the items matching the lookup, checking with
+// checkFullScan before scanning all the items if strict is set
+func (self *FileStoreHandler) search(ctx context.Context, lookup *resource.Lookup, w window, strict bool) (list *resource.ItemList, err error) {
 	err = handleWithLatency(self.Latency, ctx, func() error {
-		items := []*resource.Item{}
-		// Apply filter
-		for _, id := range self.ids {
-			item, _, err := self.fetch(id)
-			if err != nil {
-				return err
-			}
-			if !lookup.Filter().Match(item.Payload) {
-				continue
-			}
-			items = append(items, item)
-		}
-		// Apply sort
-		if len(lookup.Sort()) > 0 {
-			s := sortableItems{lookup.Sort(), items}
-			sort.Sort(s)
-		}
-		// Apply pagination
-		total := len(items)
-		start := (page - 1) * perPage
-		end := total
-		if perPage > 0 {
-			end = start + perPage
-			if start > total-1 {
-				start = 0
-				end = 0
-			} else if end > total-1 {
-				end = total
+		if list, err = self.findFromIndex(lookup, w); list != nil || err != nil {
+			return err
+		}
+		ids, ok := self.rangeCandidates(lookup)
+		if !ok {
+			if strict {
+				if err := self.checkFullScan(lookup); err != nil {
+					return err
+				}
 			}
+			ids = self.ids
 		}
-		list = &resource.ItemList{total, page, items[start:end]}
-		return nil
+		list, err = self.scan(ctx, lookup, w, ids, self.peekValid)
+		return err
 	})
+	if err == nil {
+		if err = self.waitItems(ctx, len(list.Items)); err != nil {
+			list = nil
+		}
+	}
 	return list, err
 }
+
+// scan returns the window w of the items of ids matching the lookup among the
+// ones found by get, sorted and copied
+func (self *FileStoreHandler) scan(ctx context.Context, lookup *resource.Lookup, w window, ids []interface{}, get func(id interface{}) (*resource.Item, bool, error)) (*resource.ItemList, error) {
+	// Apply filter on a single snapshot of the ids, the total and the
+	// returned page are both derived from this snapshot
+	var items []*resource.Item
+	var err error
+	short := self.SkipFindTotals && w.need > 0 && len(lookup.Sort()) == 0
+	if short {
+		// Unsorted, the window is made of the first matches
+		items, err = self.filterChunk(ctx, lookup, ids, get, w.need)
+	} else {
+		items, err = self.filter(ctx, lookup, ids, get)
+	}
+	if err != nil {
+		return nil, err
+	}
+	start, end := w.bounds(len(items))
+	if err := self.checkResultSize(end - start); err != nil {
+		return nil, err
+	}
+	// Apply sort, unless only the total is requested
+	if start < end && len(lookup.Sort()) > 0 {
+		s := sortableItems{sort: lookup.Sort(), items: items, nullsFirst: self.NullsFirst}
+		sort.Stable(s)
+	}
+	// Apply pagination
+	list := paginate(items, w)
+	if short && len(items) == w.need {
+		// The scan stopped before counting all the matches
+		list.Total = -1
+	}
+	// The scanned items are shared with the cache
+	for i, item := range list.Items {
+		self.touch(item.ID)
+		list.Items[i] = w.copy(item)
+	}
+	return list, nil
+}
+
+// checkResultSize returns ErrTooManyResults if n items exceed MaxResultItems
+func (self *FileStoreHandler) checkResultSize(n int) error {
+	max := self.MaxResultItems
+	if max == 0 {
+		max = DefaultMaxResultItems
+	}
+	if max > 0 && n > max {
+		return ErrTooManyResults
+	}
+	return nil
+}
+
+// paginate returns the window w of items. The total is computed from the very
+// slice the window is cut from so it is never smaller than the window itself.
+func paginate(items []*resource.Item, w window) *resource.ItemList {
+	start, end := w.bounds(len(items))
+	return &resource.ItemList{Total: len(items), Page: w.page, Items: items[start:end]}
+}
+
+// listLen returns the number of items of list, which may be nil
+func listLen(list *resource.ItemList) int {
+	if list == nil {
+		return 0
+	}
+	return len(list.Items)
+}
+
+// window selects the part of the matching items returned by a find
+type window struct {
+	// page is the page number reported in the returned list
+	page   int
+	bounds func(total int) (start, end int)
+	// need is the number of first matches the window is cut from, 0 if it
+	// depends on the total
+	need int
+	// fields, if set, are the fields kept in the returned items, see
+	// FindFields
+	fields []string
+	// shared tells to hand out the shared items themselves, see
+	// ReadOnlyResults
+	shared bool
+}
+
+// copy returns the copy of a shared item handed out for the window
+func (w window) copy(item *resource.Item) *resource.Item {
+	if w.fields != nil {
+		return projectItem(item, w.fields)
+	}
+	if w.shared {
+		return item
+	}
+	return cloneItem(item)
+}
+
+// pageWindow returns the window of a page of perPage items
+func pageWindow(page, perPage int) window {
+	w := window{
+		page: page,
+		bounds: func(total int) (int, int) {
+			return pageBounds(total, page, perPage)
+		},
+	}
+	if perPage > 0 {
+		if page < 1 {
+			page = 1
+		}
+		w.need = page * perPage
+	}
+	return w
+}
+
+// pageBounds returns the bounds of the requested page in a list of total
+// elements. A page past the end is empty and the last page holds what is
+// left. All the elements are in the page if perPage < 0 and none of them if
+// perPage == 0.
+func pageBounds(total, page, perPage int) (start, end int) {
+	if perPage < 0 {
+		return 0, total
+	}
+	if perPage == 0 {
+		return 0, 0
+	}
+	if page < 1 {
+		page = 1
+	}
+	start = (page - 1) * perPage
+	if start >= total {
+		return total, total
+	}
+	end = start + perPage
+	if end > total {
+		end = total
+	}
+	return start, end
+}
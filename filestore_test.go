@@ -0,0 +1,89 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func tmpdir(t *testing.T) string {
+	d, err := ioutil.TempDir("", "fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(d) })
+	return d
+}
+
+func mkitem(id interface{}, p map[string]interface{}) *resource.Item {
+	p["id"] = id
+	i, _ := resource.NewItem(p)
+	return i
+}
+
+func TestBasic(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", []string{"name"})
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"name": "x", "n": 1}), mkitem("b", map[string]interface{}{"name": "y", "n": 2})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{"name": "x"})}); err == nil {
+		t.Fatal("expected unique err")
+	}
+	l, err := h.Find(ctx, resource.NewLookup(), 1, -1)
+	if err != nil || l.Total != 2 {
+		t.Fatal(err, l)
+	}
+	h2 := newH(t, d, "c", []string{"name"})
+	lk := resource.NewLookup()
+	lk.AddQuery(schema.Query{schema.Equal{Field: "name", Value: "y"}})
+	l, err = h2.Find(ctx, lk, 1, 10)
+	if err != nil || l.Total != 1 || l.Items[0].ID != "b" {
+		t.Fatal(err, l)
+	}
+	o := l.Items[0]
+	n := mkitem("b", map[string]interface{}{"name": "z"})
+	if err := h2.Update(ctx, n, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := h2.Delete(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	l, _ = h2.Find(ctx, resource.NewLookup(), 1, -1)
+	if l.Total != 1 {
+		t.Fatal(l)
+	}
+	c, err := h2.Clear(ctx, resource.NewLookup())
+	if err != nil || c != 1 {
+		t.Fatal(err, c)
+	}
+}
+
+var openHandlers = map[string]*FileStoreHandler{}
+
+// newH opens a handler, closing the previous one opened on the same datafile
+func newH(t *testing.T, d, c string, u []string) *FileStoreHandler {
+	p := filepath.Join(d, c)
+	if prev := openHandlers[p]; prev != nil {
+		prev.Close()
+	}
+	h, err := NewHandler(d, c, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openHandlers[p] = h
+	return h
+}
+
+func tmpdirTB(tb testing.TB) string {
+	if t, ok := tb.(*testing.T); ok {
+		return tmpdir(t)
+	}
+	return tb.(*testing.B).TempDir()
+}
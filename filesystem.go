@@ -0,0 +1,111 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// FileSystem is the storage holding the datafile, see
+// FileStoreHandler.FileSystem. The errors of a missing file must satisfy
+// os.IsNotExist.
+type FileSystem interface {
+	// ReadFile returns the content of the file name
+	ReadFile(name string) ([]byte, error)
+	// WriteFile writes data to the file name, creating or truncating it,
+	// and gives it the permissions perm even if it existed. The content may
+	// only be durable once the file is synced.
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	// Sync makes the content of the file, or the entries of the
+	// directory, name durable
+	Sync(name string) error
+	Stat(name string) (os.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+	// Rename replaces newpath by oldpath atomically, the rename is durable
+	// once the directory of newpath is synced
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+}
+
+// OSFileSystem is the FileSystem of the operating system, used when
+// FileStoreHandler.FileSystem isn't set
+type OSFileSystem struct{}
+
+// ReadFile implements FileSystem
+func (OSFileSystem) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+// WriteFile implements FileSystem, perm is applied regardless of the umask
+func (OSFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := ioutil.WriteFile(name, data, perm); err != nil {
+		return err
+	}
+	return os.Chmod(name, perm)
+}
+
+// Sync implements FileSystem
+func (OSFileSystem) Sync(name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		// A directory can only be opened for reading
+		if f, err = os.Open(name); err != nil {
+			return err
+		}
+	}
+	err = f.Sync()
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Stat implements FileSystem
+func (OSFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// MkdirAll implements FileSystem
+func (OSFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+// Rename implements FileSystem
+func (OSFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+// Remove implements FileSystem
+func (OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// DefaultFileMode and DefaultDirMode are the permissions of the files and of
+// the directories created by a handler when FileMode and DirMode aren't set
+const (
+	DefaultFileMode os.FileMode = 0644
+	DefaultDirMode  os.FileMode = 0755
+)
+
+// fileMode returns the permissions of the files of the handler
+func (self *FileStoreHandler) fileMode() os.FileMode {
+	if self.FileMode == 0 {
+		return DefaultFileMode
+	}
+	return self.FileMode
+}
+
+// dirMode returns the permissions of the directories created by the handler
+func (self *FileStoreHandler) dirMode() os.FileMode {
+	if self.DirMode == 0 {
+		return DefaultDirMode
+	}
+	return self.DirMode
+}
+
+// fileSystem returns the FileSystem holding the datafile
+func (self *FileStoreHandler) fileSystem() FileSystem {
+	if self.FileSystem == nil {
+		return OSFileSystem{}
+	}
+	return self.FileSystem
+}
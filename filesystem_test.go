@@ -0,0 +1,122 @@
+package filestore
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type memFS struct {
+	sync.Mutex
+	files map[string][]byte
+	syncs []string
+}
+
+type memInfo struct {
+	name string
+	size int64
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() os.FileMode  { return 0644 }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return false }
+func (i memInfo) Sys() interface{}   { return nil }
+
+func (m *memFS) ReadFile(name string) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	d, ok := m.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: name, Err: os.ErrNotExist}
+	}
+	return d, nil
+}
+func (m *memFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.Lock()
+	defer m.Unlock()
+	m.files[name] = append([]byte(nil), data...)
+	return nil
+}
+func (m *memFS) Stat(name string) (os.FileInfo, error) {
+	m.Lock()
+	defer m.Unlock()
+	d, ok := m.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return memInfo{name, int64(len(d))}, nil
+}
+func (m *memFS) Sync(name string) error {
+	m.Lock()
+	defer m.Unlock()
+	m.syncs = append(m.syncs, name)
+	return nil
+}
+func (m *memFS) MkdirAll(path string, perm os.FileMode) error { return nil }
+func (m *memFS) Rename(o, n string) error {
+	m.Lock()
+	defer m.Unlock()
+	m.files[n] = m.files[o]
+	delete(m.files, o)
+	return nil
+}
+func (m *memFS) Remove(name string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.files, name)
+	return nil
+}
+
+func TestMemFS(t *testing.T) {
+	fs := &memFS{files: map[string][]byte{}}
+	h, err := NewHandlerWithOptions("/nonexistent/dir", "c", WithFileSystem(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"id": "a"})}); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	if len(fs.files) != 1 {
+		t.Fatal(fs.files)
+	}
+	h2, err := NewHandlerWithOptions("/nonexistent/dir", "c", WithFileSystem(fs))
+	if err != nil || h2.Len() != 1 {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("/nonexistent"); !os.IsNotExist(err) {
+		t.Fatal("touched disk")
+	}
+}
+
+func TestDurable(t *testing.T) {
+	for _, durable := range []bool{true, false} {
+		fs := &memFS{files: map[string][]byte{}}
+		h, err := NewHandlerWithOptions("/x", "c", WithFileSystem(fs), WithDurable(durable))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"id": "a"})})
+		if durable && (len(fs.syncs) != 2 || !strings.HasPrefix(fs.syncs[0], "/x/c.") || !strings.HasSuffix(fs.syncs[0], ".tmp") || fs.syncs[1] != "/x") {
+			t.Fatal(fs.syncs)
+		}
+		if !durable && len(fs.syncs) != 0 {
+			t.Fatal(fs.syncs)
+		}
+		fs.syncs = nil
+		if err := h.Sync(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if durable && len(fs.syncs) != 0 || !durable && (len(fs.syncs) != 2 || fs.syncs[0] != "/x/c") {
+			t.Fatal(durable, fs.syncs)
+		}
+		h.Close()
+	}
+}
@@ -0,0 +1,21 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestFast(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", []string{"name"})
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"name": "x"}), mkitem("b", map[string]interface{}{"name": "y"})})
+	lk := resource.NewLookup()
+	lk.AddQuery(schema.Query{schema.Equal{Field: "name", Value: "y"}})
+	l, err := h.findFromIndex(lk, pageWindow(1, 10))
+	if err != nil || l == nil || l.Total != 1 || l.Items[0].ID != "b" {
+		t.Fatal(err, l)
+	}
+}
@@ -0,0 +1,117 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Flush saves the changes not persisted yet. With FlushInterval set, writes
+// return as soon as the memory is updated and are saved to disk in the
+// background: the changes made since the last flush are lost if the process
+// dies. Flush lets the caller make sure the changes made so far are on disk,
+// Close flushes a last time.
+func (self *FileStoreHandler) Flush(ctx context.Context) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if !self.dirty {
+			return nil
+		}
+		return self.saveDatafile()
+	})
+}
+
+// Sync is like Flush and also syncs the files of the handler when Durable
+// isn't set, for the callers trading the durability of every save for speed
+// to make a checkpoint
+func (self *FileStoreHandler) Sync(ctx context.Context) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if self.dirty {
+			if err := self.saveDatafile(); err != nil {
+				return err
+			}
+		}
+		if self.Durable || self.inMemory() {
+			return nil
+		}
+		return self.syncFiles()
+	})
+}
+
+// syncFiles syncs the datafile, or the item files, and the log along with
+// their directory
+func (self *FileStoreHandler) syncFiles() error {
+	fs := self.fileSystem()
+	var files []string
+	dir := filepath.Dir(self.database_file)
+	if self.PerItemFiles {
+		// Always on the OS filesystem
+		fs = OSFileSystem{}
+		for _, id := range self.liveIDs() {
+			files = append(files, filepath.Join(self.database_file, itemFileName(id)))
+		}
+		dir = self.database_file
+	} else {
+		files = append(files, self.database_file)
+		if self.wal.onDisk {
+			files = append(files, self.walFile())
+		}
+	}
+	for _, file := range files {
+		if err := fs.Sync(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// Best effort as not every platform supports syncing a directory
+	fs.Sync(dir)
+	return nil
+}
+
+// startFlusher starts the worker saving the pending changes every
+// FlushInterval if it isn't already running. It must be called with the
+// write lock held.
+func (self *FileStoreHandler) startFlusher() {
+	if self.flushing {
+		return
+	}
+	self.flushing = true
+	interval := self.FlushInterval
+	self.startWorker(stageFlusher, func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := self.flushDirty(); err != nil {
+					self.logf("Error flushing database %s: %v", self.database_file, err)
+				}
+			}
+		}
+	})
+}
+
+func (self *FileStoreHandler) flushDirty() error {
+	self.Lock()
+	defer self.Unlock()
+	if self.closed || !self.dirty || self.persistSuspended() {
+		return nil
+	}
+	return self.saveDatafile()
+}
@@ -0,0 +1,41 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestFlushInterval(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	h.FlushInterval = 30 * time.Millisecond
+	ctx := context.Background()
+	for i := 0; i < 50; i++ {
+		if err := h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"n": i})}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(80 * time.Millisecond)
+	check := func(want int) {
+		s := &FileStoreHandler{database_file: h.database_file, items: map[interface{}][]byte{}, indexes: map[string]*fieldIndex{}, changes: newChangeLog(), counters: &opCounters{}}
+		if err := s.readDatafile(); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.items) != want {
+			t.Fatal(len(s.items), want)
+		}
+	}
+	check(50)
+	h.Insert(ctx, []*resource.Item{mkitem("x", map[string]interface{}{})})
+	if err := h.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	check(51)
+	h.Insert(ctx, []*resource.Item{mkitem("y", map[string]interface{}{})})
+	h.Close()
+	check(52)
+}
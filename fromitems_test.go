@@ -0,0 +1,32 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestNewHandlerFromItems(t *testing.T) {
+	d := tmpdir(t)
+	items := []*resource.Item{mkitem(3, map[string]interface{}{}), mkitem(1, map[string]interface{}{}), mkitem(2, map[string]interface{}{})}
+	h, err := NewHandlerFromItems(d, "c", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	h, _ = NewHandler(d, "c", nil)
+	l, _ := h.Find(context.Background(), resource.NewLookup(), 1, -1)
+	if len(l.Items) != 3 || l.Items[0].ID != 3 || l.Items[1].ID != 1 {
+		t.Fatal(l.Items)
+	}
+	h.Close()
+	if _, err := NewHandlerFromItems(d, "c", items); err == nil {
+		t.Fatal("duplicates accepted")
+	}
+	h, err = NewHandler(d, "c", nil)
+	if err != nil {
+		t.Fatal("left locked", err)
+	}
+	h.Close()
+}
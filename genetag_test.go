@@ -0,0 +1,48 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestGenerateETags(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithGenerateETags(), WithTimestamps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	a := &resource.Item{ID: "a", Payload: map[string]interface{}{"id": "a", "n": 1}}
+	b := &resource.Item{ID: "b", Payload: map[string]interface{}{"id": "a", "n": 1}}
+	c := &resource.Item{ID: "c", ETag: "given", Payload: map[string]interface{}{"id": "c"}}
+	if err := h.Insert(ctx, []*resource.Item{a, b, c}); err != nil {
+		t.Fatal(err)
+	}
+	ga, _ := h.Get(ctx, "a")
+	gb, _ := h.Get(ctx, "b")
+	gc, _ := h.Get(ctx, "c")
+	want, _ := ETag(a.Payload)
+	if ga.ETag != want || gb.ETag != want || gc.ETag != "given" {
+		t.Fatal(ga.ETag, gb.ETag, gc.ETag)
+	}
+	update := &resource.Item{ID: "a", Payload: map[string]interface{}{"id": "a", "n": 2}}
+	if err := h.Update(ctx, update, ga); err != nil {
+		t.Fatal(err)
+	}
+	ga2, _ := h.Get(ctx, "a")
+	if ga2.ETag == "" || ga2.ETag == ga.ETag {
+		t.Fatal(ga2.ETag)
+	}
+	// The original ETag is stale now
+	if err := h.Update(ctx, &resource.Item{ID: "a", Payload: map[string]interface{}{"id": "a", "n": 3}}, ga); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+	if err := h.Delete(ctx, ga); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+	if err := h.Delete(ctx, ga2); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,35 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// Get returns the item with id, looked up directly in the items map, or
+// resource.ErrNotFound. Expired and soft deleted items aren't found.
+func (self *FileStoreHandler) Get(ctx context.Context, id interface{}) (item *resource.Item, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		if err := checkCanceled(ctx, 0); err != nil {
+			return err
+		}
+		if item, _, err = self.fetch(id); err == nil && item == nil {
+			return resource.ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	count(&self.counters.finds, 1)
+	return self.present(item), nil
+}
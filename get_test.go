@@ -0,0 +1,26 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestGet(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", nil)
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"x": 1})})
+	it, err := h.Get(ctx, "a")
+	if err != nil || it.Payload["x"] != 1 {
+		t.Fatal(it, err)
+	}
+	if _, err := h.Get(ctx, "b"); err != resource.ErrNotFound {
+		t.Fatal(err)
+	}
+	c, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := h.Get(c, "a"); err == nil {
+		t.Fatal("not canceled")
+	}
+}
@@ -0,0 +1,119 @@
+package filestore
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// With SyncBatchWindow set and Durable on, the writes arriving within the
+// window share a single sync instead of syncing each.
+//
+// With WAL, a write appends its frame to the log without syncing it, then
+// joins the batch of writes waiting for the next sync of the log and waits
+// for it without holding the handler's lock. A single syncer waits the window,
+// takes the batch and syncs the log once for all the frames appended so far.
+// The writes of the batch are released and their events published once the
+// sync is done: a write returning nil is durable, like with a sync per write.
+// All the writes of a batch fail if its sync fails and undo their own changes,
+// see undo.go, before the next batch is synced. The log is then rewritten by
+// the next save rather than appended to.
+//
+// With CoalesceWrites, the flusher waits the window before each save so the
+// writes arriving in the meantime join its batch, see coalesce.go.
+
+// syncBatch is a group of writes made durable by the same sync
+type syncBatch struct {
+	// done is closed once the sync is done, err is its error
+	done chan struct{}
+	err  error
+	// back counts the writes of the batch which didn't get the lock back
+	back sync.WaitGroup
+}
+
+// groupSyncer tracks the batches of writes waiting for a sync of the log
+type groupSyncer struct {
+	// next is the batch the writes join, nil if there's none
+	next *syncBatch
+	// syncing is set while the syncer runs
+	syncing bool
+}
+
+// groupSyncing tells if the writes wait for the window to share their syncs
+func (self *FileStoreHandler) groupSyncing() bool {
+	return self.SyncBatchWindow > 0 && self.Durable
+}
+
+// groupSync joins the next batch of writes to sync and waits for its sync. It
+// must be called with the write lock held, which is released while waiting.
+func (self *FileStoreHandler) groupSync() error {
+	b := self.syncer.next
+	if b == nil {
+		b = &syncBatch{done: make(chan struct{})}
+		self.syncer.next = b
+	}
+	if !self.syncer.syncing {
+		self.syncer.syncing = true
+		go self.syncBatches()
+	}
+	b.back.Add(1)
+	self.Unlock()
+	<-b.done
+	self.Lock()
+	b.back.Done()
+	return b.err
+}
+
+// syncBatches syncs the log for the batches of writes until no write is
+// waiting
+func (self *FileStoreHandler) syncBatches() {
+	for {
+		time.Sleep(self.SyncBatchWindow)
+		self.Lock()
+		b := self.syncer.next
+		if b == nil {
+			self.syncer.syncing = false
+			self.Unlock()
+			return
+		}
+		self.syncer.next = nil
+		// The frames and the events of the writes of the batch, all made
+		// before they joined it
+		published := self.eventSeq
+		self.Unlock()
+		err := syncFile(self.walFile())
+		self.Lock()
+		if err != nil {
+			// The frames may be lost, the next save rewrites the datafile
+			self.wal.full = true
+			self.dirty = true
+			b.err = err
+		} else {
+			self.publishUpTo(published)
+		}
+		self.Unlock()
+		close(b.done)
+		if b.err != nil {
+			// Let the writes of the batch undo their changes before the
+			// events of the next batch are published, they do it before
+			// releasing the lock
+			b.back.Wait()
+		}
+	}
+}
+
+// syncFile syncs the file at path, a missing file having been removed once
+// its content was saved elsewhere
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
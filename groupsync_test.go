@@ -0,0 +1,175 @@
+package filestore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestSyncBatchWindow(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	window := 150 * time.Millisecond
+	h, err := NewHandlerWithOptions(d, "c", WithWAL(), WithSyncBatchWindow(window))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := h.Subscribe()
+	var wg sync.WaitGroup
+	start := time.Now()
+	errs := make([]error, 10)
+	var released sync.Map
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"n": i})})
+			released.Store(i, time.Since(start))
+		}(i)
+	}
+	// Nothing is released nor published before the sync
+	select {
+	case e := <-events:
+		t.Fatal("published before the sync", e)
+	case <-time.After(window / 2):
+	}
+	n := 0
+	released.Range(func(k, v interface{}) bool { n++; return true })
+	if n != 0 {
+		t.Fatal("released before the sync", n)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	for i, err := range errs {
+		if err != nil {
+			t.Fatal(i, err)
+		}
+	}
+	released.Range(func(k, v interface{}) bool {
+		if v.(time.Duration) < window {
+			t.Fatal("released before the window", k, v)
+		}
+		return true
+	})
+	// The writes shared a few syncs
+	if elapsed > 4*window {
+		t.Fatal("not grouped", elapsed)
+	}
+	got := 0
+	for got < 10 {
+		select {
+		case <-events:
+			got++
+		case <-time.After(time.Second):
+			t.Fatal("events", got)
+		}
+	}
+	// Reads don't wait
+	if _, err := h.Get(ctx, "3"); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	h, err = NewHandlerWithOptions(d, "c", WithWAL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if n := h.Len(); n != 10 {
+		t.Fatal(n)
+	}
+}
+
+func TestSyncBatchWindowFailed(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithWAL(), WithSyncBatchWindow(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := h.Subscribe()
+	errs := make(chan error, 2)
+	for _, id := range []string{"a", "b"} {
+		go func(id string) { errs <- h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{})}) }(id)
+	}
+	for h.Len() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// Both frames are appended, the log can't be synced
+	wal := filepath.Join(d, "c.wal")
+	if err := os.Rename(wal, wal+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(wal, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err == nil {
+			t.Fatal("sync didn't fail")
+		}
+	}
+	if h.Len() != 0 {
+		t.Fatal(h.IDs())
+	}
+	// The next write rewrites the datafile and removes the log
+	if err := h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-events; e.ID != "c" {
+		t.Fatal("event of an undone write", e)
+	}
+	h.Close()
+	h, _ = NewHandlerWithOptions(crashCopy(t, d), "c", WithWAL())
+	defer h.Close()
+	if ids := h.IDs(); len(ids) != 1 || ids[0] != "c" {
+		t.Fatal(ids)
+	}
+}
+
+func TestSyncBatchWindowCoalesce(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithCoalesceWrites(), WithSyncBatchWindow(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	start := time.Now()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("released before the window")
+	}
+}
+
+func benchSync(b *testing.B, window time.Duration) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdirTB(b), "c", WithWAL(), WithSyncBatchWindow(window))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer h.Close()
+	var mu sync.Mutex
+	next := 0
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			mu.Lock()
+			next++
+			id := fmt.Sprint(next)
+			mu.Unlock()
+			if err := h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{})}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkSyncPerWrite(b *testing.B) { benchSync(b, 0) }
+func BenchmarkSyncGrouped(b *testing.B)  { benchSync(b, time.Millisecond) }
@@ -0,0 +1,188 @@
+package filestore
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+)
+
+// Item ids are used as keys of the items map and compared with == everywhere
+// else, so two ids only match when both their dynamic type and value are
+// equal. The ids of the integer types, like the int64 of a database driver,
+// are canonicalized to int by canonicalID when they fit in one, so int(1) and
+// int64(1) are the same id whichever is used to insert, read or delete the
+// item. Floats and named integer types aren't canonicalized: float64(1), what
+// JSON decodes, and int(1) are different ids. The zero value of any type
+// (nil, "", 0, an all zero UUID array...) is not a valid id as it can't be told
+// apart from an unset id. Such items are rejected by Insert unless an
+// IDGenerator is configured or AutoIncrement is set, in which case they get a
+// generated id.
+//
+// The supported ids are the bools, numbers and strings, and the arrays and
+// structs of comparable fields registered with gob.Register so gob gives them
+// back with their type once read from the datafile. Other ids, like slices,
+// maps and pointers, are rejected by checkID.
+//
+// With AutoIncrement, the ids are ints from a sequence stored in the
+// datafile: an id is never assigned twice, even once its item is deleted.
+// Items inserted with an explicit int id advance the sequence past it.
+
+// maxInt is the greatest int, math.MaxInt which requires go 1.17
+const maxInt = int(^uint(0) >> 1)
+
+// canonicalID returns id as an int if it's of another integer type and fits
+// in an int, id otherwise
+func canonicalID(id interface{}) interface{} {
+	switch v := id.(type) {
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		if int64(int(v)) == v {
+			return int(v)
+		}
+	case uint8:
+		return int(v)
+	case uint16:
+		return int(v)
+	case uint32:
+		if uint64(v) <= uint64(maxInt) {
+			return int(v)
+		}
+	case uint:
+		if v <= uint(maxInt) {
+			return int(v)
+		}
+	case uint64:
+		if v <= uint64(maxInt) {
+			return int(v)
+		}
+	}
+	return id
+}
+
+// canonicalizeIDs canonicalizes the ids of items, in their payload too, see
+// canonicalID
+func (self *FileStoreHandler) canonicalizeIDs(items ...*resource.Item) {
+	field := self.idField()
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		item.ID = canonicalID(item.ID)
+		if value, found := item.Payload[field]; found {
+			item.Payload[field] = canonicalID(value)
+		}
+	}
+}
+
+// checkID returns an error if id isn't of a supported type
+func checkID(id interface{}) error {
+	if id == nil {
+		return nil
+	}
+	t := reflect.TypeOf(id)
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return nil
+	case reflect.Array, reflect.Struct:
+		if !t.Comparable() {
+			break
+		}
+		// gob only encodes the interface values of registered types
+		if _, err := (GobCodec{}).Marshal(&struct{ ID interface{} }{id}); err != nil {
+			return &rest.Error{Code: 422, Message: fmt.Sprintf("Unsupported item ID type %T: %v", id, err)}
+		}
+		return nil
+	}
+	return &rest.Error{Code: 422, Message: fmt.Sprintf("Unsupported item ID type %T", id)}
+}
+
+// isZeroID tells if id is nil or the zero value of its type
+func isZeroID(id interface{}) bool {
+	if id == nil {
+		return true
+	}
+	return reflect.ValueOf(id).IsZero()
+}
+
+// DefaultIDField is the payload field holding the id of the items when
+// IDField isn't set
+const DefaultIDField = "id"
+
+// idField returns the payload field holding the id of the items
+func (self *FileStoreHandler) idField() string {
+	if self.IDField == "" {
+		return DefaultIDField
+	}
+	return self.IDField
+}
+
+// generateIDs assigns a generated id to the items lacking one
+func (self *FileStoreHandler) generateIDs(items []*resource.Item) error {
+	if self.IDGenerator == nil && !self.AutoIncrement {
+		return nil
+	}
+	for _, item := range items {
+		if !isZeroID(item.ID) {
+			continue
+		}
+		var id interface{}
+		if self.IDGenerator != nil {
+			var err error
+			if id, err = self.IDGenerator(); err != nil {
+				return err
+			}
+		} else {
+			self.sequence++
+			id = self.sequence
+		}
+		item.ID = canonicalID(id)
+		if item.Payload != nil {
+			item.Payload[self.idField()] = item.ID
+		}
+	}
+	return nil
+}
+
+// advanceSequence makes sure AutoIncrement won't assign id
+func (self *FileStoreHandler) advanceSequence(id interface{}) {
+	if n, ok := id.(int); ok && n > self.sequence {
+		self.sequence = n
+	}
+}
+
+// sortIDs sorts ids in a deterministic order, for the datafiles which don't
+// store the order of their items: numbers by value, strings lexically, and
+// other ids or ids of different kinds by their type name and then their
+// formatted value.
+func sortIDs(ids []interface{}) {
+	sort.SliceStable(ids, func(i, j int) bool {
+		return lessID(ids[i], ids[j])
+	})
+}
+
+func lessID(a, b interface{}) bool {
+	if c, ok := compareNumbers(a, b); ok && c != 0 {
+		return c < 0
+	}
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return sa < sb
+		}
+	}
+	ta, tb := fmt.Sprintf("%T", a), fmt.Sprintf("%T", b)
+	if ta != tb {
+		return ta < tb
+	}
+	return fmt.Sprintf("%v", a) < fmt.Sprintf("%v", b)
+}
@@ -0,0 +1,30 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestIDField(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithAutoIncrement(), WithIDField("_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := &resource.Item{Payload: map[string]interface{}{}}
+	if err := h.Insert(ctx, []*resource.Item{it}); err != nil {
+		t.Fatal(err)
+	}
+	if it.Payload["_id"] != 1 || it.Payload["id"] != nil {
+		t.Fatal(it.Payload)
+	}
+	if _, err := h.Merge(ctx, 1, map[string]interface{}{"_id": 2}, ""); err == nil {
+		t.Fatal("id change accepted")
+	}
+	if _, err := h.Merge(ctx, 1, map[string]interface{}{"id": 2}, ""); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+}
@@ -0,0 +1,39 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestLegacyOrderStable(t *testing.T) {
+	d := tmpdir(t)
+	ctx := context.Background()
+	h := newH(t, d, "c", nil)
+	for i := 20; i > 0; i-- {
+		h.Insert(ctx, []*resource.Item{mkitem(i, map[string]interface{}{"id": i})})
+	}
+	h.Close()
+	var first string
+	for k := 0; k < 4; k++ {
+		h = newH(t, d, "c", nil)
+		l, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+		s := fmt.Sprint(idsOf(l))
+		if k == 0 {
+			first = s
+		} else if s != first {
+			t.Fatal(s, first)
+		}
+	}
+	h.Close()
+}
+
+func idsOf(l *resource.ItemList) []interface{} {
+	ids := []interface{}{}
+	for _, it := range l.Items {
+		ids = append(ids, it.ID)
+	}
+	return ids
+}
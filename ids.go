@@ -0,0 +1,118 @@
+package filestore
+
+// The ids list keeps the order of the items. Removing an id from it doesn't
+// shift the list: the id is looked up in idPos and its slot replaced by a
+// hole. The holes are squeezed out once they fill half of the list, so a
+// removal costs O(1) amortized. Until then the list may hold holes: peek and
+// decodeItem find nothing for them, the loops over the list needing actual
+// ids use liveIDs or snapshotIDs.
+
+// holeID fills the slot of a removed id until the ids list is compacted
+type holeID struct{}
+
+// minCompactIDs is the number of holes under which the ids list is never
+// compacted, a short list is cheap to scan whatever its holes
+const minCompactIDs = 64
+
+// appendID adds id at the end of the ids list
+func (self *FileStoreHandler) appendID(id interface{}) {
+	if self.idPos == nil {
+		self.setIDs(self.ids)
+	}
+	self.idPos[id] = len(self.ids)
+	self.ids = append(self.ids, id)
+	self.logWAL(walAppend, id, nil)
+}
+
+// removeID removes id from the ids list
+func (self *FileStoreHandler) removeID(id interface{}) {
+	if self.idPos == nil {
+		self.setIDs(self.ids)
+	}
+	i, found := self.idPos[id]
+	if !found {
+		return
+	}
+	delete(self.idPos, id)
+	self.logWAL(walRemove, id, nil)
+	if i == len(self.ids)-1 {
+		// Shrink the list instead, along with the holes the id was
+		// following, releasing the references of the freed slots
+		self.ids[i] = nil
+		for i > 0 {
+			if _, hole := self.ids[i-1].(holeID); !hole {
+				break
+			}
+			i--
+			self.ids[i] = nil
+			self.idHoles--
+		}
+		self.ids = self.ids[:i]
+		return
+	}
+	self.ids[i] = holeID{}
+	self.idHoles++
+	if self.idHoles >= minCompactIDs && self.idHoles*2 >= len(self.ids) {
+		self.compactIDs()
+	}
+}
+
+// setIDs replaces the ids list
+func (self *FileStoreHandler) setIDs(ids []interface{}) {
+	self.ids = ids
+	self.idHoles = 0
+	self.idPos = make(map[interface{}]int, len(ids))
+	for i, id := range ids {
+		self.idPos[id] = i
+	}
+}
+
+// idCount returns the number of ids in the list
+func (self *FileStoreHandler) idCount() int {
+	return len(self.ids) - self.idHoles
+}
+
+// liveIDs returns the ids list without its holes. It is the list itself if it
+// has none, the caller must not modify it.
+func (self *FileStoreHandler) liveIDs() []interface{} {
+	if self.idHoles == 0 {
+		return self.ids
+	}
+	ids := make([]interface{}, 0, self.idCount())
+	for _, id := range self.ids {
+		if _, hole := id.(holeID); !hole {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// snapshotIDs returns a copy of the ids list without its holes, for the loops
+// removing ids from the list as they go
+func (self *FileStoreHandler) snapshotIDs() []interface{} {
+	ids := self.liveIDs()
+	if self.idHoles == 0 {
+		ids = append([]interface{}(nil), ids...)
+	}
+	return ids
+}
+
+// compactIDs squeezes the holes out of the ids list
+func (self *FileStoreHandler) compactIDs() {
+	if self.idHoles == 0 {
+		return
+	}
+	ids := self.ids[:0]
+	for _, id := range self.ids {
+		if _, hole := id.(holeID); !hole {
+			self.idPos[id] = len(ids)
+			ids = append(ids, id)
+		}
+	}
+	// Release the ids held by the tail
+	for i := len(ids); i < len(self.ids); i++ {
+		self.ids[i] = nil
+	}
+	self.ids = ids
+	self.idHoles = 0
+}
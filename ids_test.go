@@ -0,0 +1,57 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestIDHoles(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", []string{"u"})
+	ctx := context.Background()
+	for i := 0; i < 8; i++ {
+		h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"u": i})})
+	}
+	n, err := h.Clear(ctx, resource.NewLookup())
+	if err != nil || n != 8 || h.idCount() != 0 {
+		t.Fatal(n, err, h.ids)
+	}
+	for i := 0; i < 5; i++ {
+		h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"u": i})})
+	}
+	h.DeleteMany(ctx, []interface{}{"1", "3"})
+	h.Insert(ctx, []*resource.Item{mkitem("1", map[string]interface{}{"u": 1})})
+	h = newH(t, d, "c", []string{"u"})
+	got := fmt.Sprint(h.ids)
+	if got != "[0 2 4 1]" {
+		t.Fatal(got)
+	}
+	it, _, _ := h.fetch("2")
+	h.Delete(ctx, it)
+	if fmt.Sprint(h.liveIDs()) != "[0 4 1]" || h.idCount() != 3 || h.idPos["1"] != 3 {
+		t.Fatal(h.ids, h.idPos)
+	}
+}
+
+func BenchmarkDeleteAll(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		h := NewMemoryHandler(0)
+		items := make([]*resource.Item, 50000)
+		ids := make([]interface{}, 50000)
+		for i := range items {
+			items[i] = mkitem(i+1, map[string]interface{}{"x": i})
+			ids[i] = i + 1
+		}
+		h.Insert(context.Background(), items)
+		for _, id := range ids {
+			it, _, _ := h.fetch(id)
+			h.Delete(context.Background(), it)
+		}
+		if h.idCount() != 0 {
+			b.Fatal(h.idCount())
+		}
+	}
+}
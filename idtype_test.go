@@ -0,0 +1,61 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type unregisteredID struct{ A, B int }
+
+func TestIDTypes(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	ctx := context.Background()
+	for _, id := range []interface{}{[]int{1}, map[string]int{"a": 1}, &struct{}{}, unregisteredID{1, 2}} {
+		if err := h.Insert(ctx, []*resource.Item{{ID: id, ETag: "x", Payload: map[string]interface{}{"id": 1}}}); err == nil {
+			t.Fatalf("%T accepted", id)
+		}
+	}
+	errs, err := h.ValidateInsert(ctx, []*resource.Item{{ID: []int{1}, Payload: map[string]interface{}{}}})
+	if err != nil || errs[0] == nil {
+		t.Fatal(errs, err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem(42, map[string]interface{}{"x": 1})}); err != nil {
+		t.Fatal(err)
+	}
+	h = newH(t, d, "c", nil)
+	it, found, err := h.fetch(42)
+	if !found || err != nil {
+		t.Fatal("int id lost its type", found, err)
+	}
+	if err := h.Delete(ctx, it); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnhashableIDs(t *testing.T) {
+	ctx := context.Background()
+	h := newH(t, tmpdir(t), "c", nil)
+	id := []int{1}
+	if _, err := h.MultiGet(ctx, []interface{}{"a", id}); err == nil {
+		t.Fatal("MultiGet accepted", id)
+	}
+	if err := h.Delete(ctx, &resource.Item{ID: id}); err == nil {
+		t.Fatal("Delete accepted", id)
+	}
+	item := &resource.Item{ID: id, Payload: map[string]interface{}{}}
+	if err := h.Update(ctx, item, item); err == nil {
+		t.Fatal("Update accepted", id)
+	}
+	if _, err := h.Merge(ctx, id, map[string]interface{}{"x": 1}, ""); err == nil {
+		t.Fatal("Merge accepted", id)
+	}
+	if _, err := h.Patch(ctx, id, nil, ""); err == nil {
+		t.Fatal("Patch accepted", id)
+	}
+	if _, err := h.Increment(ctx, id, "n", 1); err == nil {
+		t.Fatal("Increment accepted", id)
+	}
+}
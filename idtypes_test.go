@@ -0,0 +1,60 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestIntIDSurvivesReload(t *testing.T) {
+	dir := tmpdir(t)
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(dir, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem(7, map[string]interface{}{"a": 1}), mkitem(8, map[string]interface{}{"a": 2})}); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	h, err = NewHandlerWithOptions(dir, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.Delete(ctx, &resource.Item{ID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := h.Count(ctx, resource.NewLookup()); n != 1 {
+		t.Fatal(n)
+	}
+	if got, _ := h.MultiGet(ctx, []interface{}{7}); got[0] != nil {
+		t.Fatal("still there")
+	}
+	if ids := h.IDs(); len(ids) != 1 || ids[0] != 8 {
+		t.Fatalf("%#v", ids)
+	}
+	h.Close()
+	h, _ = NewHandlerWithOptions(dir, "c")
+	if ids := h.IDs(); len(ids) != 1 || ids[0] != 8 {
+		t.Fatalf("%#v", ids)
+	}
+	// An int64 id is the same item
+	if got, _ := h.MultiGet(ctx, []interface{}{int64(8)}); got[0] == nil || got[0].ID != 8 {
+		t.Fatal("int64 id not found")
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem(int64(8), map[string]interface{}{})}); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem(uint32(9), map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := h.Get(ctx, 9); got == nil || got.Payload["id"] != 9 {
+		t.Fatal(got)
+	}
+	if err := h.Delete(ctx, &resource.Item{ID: int16(9)}); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+}
@@ -0,0 +1,30 @@
+package filestore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestIdxPersist(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", []string{"name"})
+	h.PersistIndexes = true
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"name": "x"}), mkitem("b", map[string]interface{}{"name": []interface{}{"q"}})}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(d + "/c.idx"); err != nil {
+		t.Fatal(err)
+	}
+	h.indexes = nil
+	h.readDatafile()
+	if ids := h.fieldIndex("name").lookup("x"); len(ids) != 1 || ids[0] != "a" {
+		t.Fatal(ids)
+	}
+	if ids := h.fieldIndex("name").lookup([]interface{}{"q"}); len(ids) != 1 {
+		t.Fatal(ids)
+	}
+}
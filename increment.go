@@ -0,0 +1,86 @@
+package filestore
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// Increment adds delta to the numeric top level field of the item with the
+// given id, storing it with a new ETag, and returns the new value. A missing
+// field counts as 0. An int field stays an int when delta is a whole number,
+// it becomes a float64 otherwise. resource.ErrNotFound is returned if there's
+// no such item and a 422 error if the field holds something else than a
+// number.
+func (self *FileStoreHandler) Increment(ctx context.Context, id interface{}, field string, delta float64) (value float64, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return 0, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		if err := checkID(id); err != nil {
+			return err
+		}
+		if field == self.idField() {
+			return &rest.Error{Code: 422, Message: "Invalid increment: the id can't be changed"}
+		}
+		o, found, err := self.fetch(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return resource.ErrNotFound
+		}
+		var sum interface{}
+		var ok bool
+		if sum, value, ok = addNumber(o.Payload[field], delta); !ok {
+			return &rest.Error{Code: 422, Message: fmt.Sprintf("Invalid increment: field '%s' isn't a number", field)}
+		}
+		payload := o.Payload
+		payload[field] = sum
+		etag, err := ETag(payload)
+		if err != nil {
+			return err
+		}
+		item := &resource.Item{ID: o.ID, ETag: etag, Updated: self.now(), Payload: payload}
+		invalid, err := self.checkUnique(ctx, item)
+		if err != nil {
+			return err
+		}
+		if invalid != nil {
+			return invalid
+		}
+		if err := self.store(item); err != nil {
+			return err
+		}
+		return self.persistData()
+	})
+	if err != nil {
+		return 0, err
+	}
+	count(&self.counters.updates, 1)
+	return value, nil
+}
+
+// addNumber adds delta to current, nil counting as 0, and returns the sum to
+// store and its value. It returns false if current isn't a number.
+func addNumber(current interface{}, delta float64) (interface{}, float64, bool) {
+	if current == nil {
+		current = 0
+	}
+	n, ok := toFloat(current)
+	if !ok {
+		return nil, 0, false
+	}
+	if i, isInt := current.(int); isInt && delta == math.Trunc(delta) && math.Abs(delta) < 1<<53 {
+		sum := i + int(delta)
+		return sum, float64(sum), true
+	}
+	return n + delta, n + delta, true
+}
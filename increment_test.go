@@ -0,0 +1,52 @@
+package filestore
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestIncrement(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{"n": 0, "s": "x"})})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := h.Increment(ctx, 1, "n", 2); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, err := h.Increment(ctx, 1, "n", 0); err != nil || v != 100 {
+		t.Fatal(v, err)
+	}
+	if v, _ := h.Increment(ctx, 1, "f", 0.5); v != 0.5 {
+		t.Fatal(v)
+	}
+	if _, err := h.Increment(ctx, 1, "s", 1); err == nil {
+		t.Fatal("string incremented")
+	}
+	if _, err := h.Increment(ctx, 2, "n", 1); err != resource.ErrNotFound {
+		t.Fatal(err)
+	}
+	h.Close()
+	h, _ = NewHandler(d, "c", nil)
+	defer h.Close()
+	it, _ := h.MultiGet(ctx, []interface{}{1})
+	if it[0].Payload["n"] != 100 {
+		t.Fatal(it[0].Payload)
+	}
+	if e, _ := ETag(it[0].Payload); e != it[0].ETag {
+		t.Fatal("etag")
+	}
+}
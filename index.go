@@ -0,0 +1,369 @@
+package filestore
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+)
+
+// compositeKey indexes values which can't be used as map keys, like maps or
+// slices, by their JSON representation
+type compositeKey struct {
+	JSON string
+}
+
+// indexKey returns the key of a field value in an index
+func indexKey(value interface{}) interface{} {
+	if value == nil || reflect.TypeOf(value).Comparable() {
+		return value
+	}
+	data, _ := json.Marshal(value)
+	return compositeKey{string(data)}
+}
+
+// fieldIndex indexes the ids of the items by the value of a field. Items
+// lacking the field aren't indexed. Ids sharing a value are kept in the order
+// they were indexed.
+type fieldIndex struct {
+	IDs  map[interface{}][]interface{}
+	Keys map[interface{}]interface{}
+	// Fold is set for the fields of CaseInsensitiveUniqueFields, their
+	// string values are indexed by foldValue
+	Fold bool
+}
+
+func newFieldIndex() *fieldIndex {
+	return &fieldIndex{
+		IDs:  map[interface{}][]interface{}{},
+		Keys: map[interface{}]interface{}{},
+	}
+}
+
+// key returns the key of value in the index
+func (idx *fieldIndex) key(value interface{}) interface{} {
+	if idx.Fold {
+		value = foldValue(value)
+	}
+	return indexKey(value)
+}
+
+// lookup returns the ids of the items holding value
+func (idx *fieldIndex) lookup(value interface{}) []interface{} {
+	return idx.IDs[idx.key(value)]
+}
+
+func (idx *fieldIndex) add(id interface{}, payload map[string]interface{}, field string) {
+	value, found := payload[field]
+	if !found {
+		idx.remove(id)
+		return
+	}
+	key := idx.key(value)
+	if current, found := idx.Keys[id]; found {
+		if current == key {
+			return
+		}
+		idx.remove(id)
+	}
+	idx.IDs[key] = append(idx.IDs[key], id)
+	idx.Keys[id] = key
+}
+
+func (idx *fieldIndex) remove(id interface{}) {
+	key, found := idx.Keys[id]
+	if !found {
+		return
+	}
+	delete(idx.Keys, id)
+	ids := idx.IDs[key]
+	for i, _id := range ids {
+		if _id == id {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(idx.IDs, key)
+	} else {
+		idx.IDs[key] = ids
+	}
+}
+
+// persistedIndexes is the content of the indexes sidecar file. Checksum is the
+// sha256 of the datafile the indexes were built from.
+type persistedIndexes struct {
+	Checksum [sha256.Size]byte
+	Fields   []string
+	Indexes  map[string]*fieldIndex
+}
+
+func (self *FileStoreHandler) indexFile() string {
+	return self.database_file + ".idx"
+}
+
+// newFieldIndex returns an empty index for field
+func (self *FileStoreHandler) newFieldIndex(field string) *fieldIndex {
+	idx := newFieldIndex()
+	idx.Fold = self.caseInsensitive(field)
+	return idx
+}
+
+// buildIndex returns an index of field built from all the stored items
+func (self *FileStoreHandler) buildIndex(field string) (*fieldIndex, error) {
+	idx := self.newFieldIndex(field)
+	for _, id := range self.ids {
+		item, found, err := self.decodeItem(id)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// Hole of an id removed by the current write
+			continue
+		}
+		idx.add(id, item.Payload, field)
+	}
+	return idx, nil
+}
+
+// fieldIndex returns the index of field or nil if the field isn't indexed
+func (self *FileStoreHandler) fieldIndex(field string) *fieldIndex {
+	return self.indexes[field]
+}
+
+// indexItem updates the indexes with the payload of item
+func (self *FileStoreHandler) indexItem(item *resource.Item) {
+	for field, idx := range self.indexes {
+		idx.add(item.ID, item.Payload, field)
+	}
+	for field, idx := range self.sortedIndexes {
+		idx.add(item.ID, item.Payload, field)
+	}
+}
+
+// unindexItem removes the item with id from the indexes
+func (self *FileStoreHandler) unindexItem(id interface{}) {
+	for _, idx := range self.indexes {
+		idx.remove(id)
+	}
+	for _, idx := range self.sortedIndexes {
+		idx.remove(id)
+	}
+}
+
+// indexedFields returns the fields with an index: the UniqueFields then the
+// IndexedFields which aren't unique
+func (self *FileStoreHandler) indexedFields() []string {
+	if len(self.IndexedFields) == 0 {
+		return self.UniqueFields
+	}
+	fields := append([]string(nil), self.UniqueFields...)
+	seen := map[string]bool{}
+	for _, field := range fields {
+		seen[field] = true
+	}
+	for _, field := range self.IndexedFields {
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// rebuildIndexes builds the indexes of the indexed fields from the stored
+// items
+func (self *FileStoreHandler) rebuildIndexes() error {
+	self.recountBlobs()
+	indexes := map[string]*fieldIndex{}
+	for _, field := range self.indexedFields() {
+		idx, err := self.buildIndex(field)
+		if err != nil {
+			return err
+		}
+		indexes[field] = idx
+	}
+	self.indexes = indexes
+	return self.rebuildSortedIndexes()
+}
+
+// loadIndexes loads the indexes of the datafile content data. If
+// PersistIndexes is set, the indexes are read from the sidecar file when it
+// matches data, they are rebuilt from the items otherwise.
+func (self *FileStoreHandler) loadIndexes(data []byte) error {
+	if self.PersistIndexes && self.FileSystem == nil {
+		if content, err := ioutil.ReadFile(self.indexFile()); err == nil {
+			var persisted persistedIndexes
+			content, err := self.decrypt(content)
+			if err == nil {
+				err = gobDecode(content, &persisted)
+			}
+			if err == nil && persisted.Checksum == sha256.Sum256(data) && self.matchIndexes(persisted) {
+				self.indexes = persisted.Indexes
+				self.recountBlobs()
+				return self.rebuildSortedIndexes()
+			}
+		}
+	}
+	return self.rebuildIndexes()
+}
+
+// matchIndexes tells if persisted indexes are built for the current indexed
+// fields
+func (self *FileStoreHandler) matchIndexes(persisted persistedIndexes) bool {
+	if !reflect.DeepEqual(persisted.Fields, self.indexedFields()) {
+		return false
+	}
+	for field, idx := range persisted.Indexes {
+		if idx.Fold != self.caseInsensitive(field) {
+			return false
+		}
+	}
+	return true
+}
+
+// saveIndexes writes the indexes to the sidecar file along with the checksum
+// of the datafile content data they match. The sidecar only speeds up the
+// loading so a failure to write it is logged and otherwise ignored.
+func (self *FileStoreHandler) saveIndexes(data []byte) {
+	if !self.PersistIndexes || self.FileSystem != nil {
+		return
+	}
+	persisted := persistedIndexes{
+		Checksum: sha256.Sum256(data),
+		Fields:   self.indexedFields(),
+		Indexes:  self.indexes,
+	}
+	content, err := self.serialize(&persisted)
+	if err == nil {
+		content, err = self.encrypt(content)
+	}
+	if err == nil {
+		err = writeFileAtomic(self.indexFile(), content, self.fileMode(), self.Durable)
+	}
+	if err != nil {
+		self.logf("Error saving indexes of database %s: %v", self.database_file, err)
+		os.Remove(self.indexFile())
+	}
+}
+
+// indexCandidates returns, in the handler's order, the ids of the items
+// matching a lookup made of a single equality, inclusion or existence check
+// on an indexed field, optionally sorted on that same field for an equality.
+// It returns false when the lookup can't be resolved from an index.
+func (self *FileStoreHandler) indexCandidates(lookup *resource.Lookup) ([]interface{}, bool) {
+	filter := lookup.Filter()
+	if len(filter) != 1 || self.MatchFunc != nil {
+		return nil, false
+	}
+	var field string
+	var values []interface{}
+	switch exp := filter[0].(type) {
+	case schema.Equal:
+		field, values = exp.Field, []interface{}{exp.Value}
+	case schema.In:
+		field = exp.Field
+		for _, value := range exp.Values {
+			values = append(values, value)
+		}
+	case schema.Exist:
+		return self.presenceCandidates(lookup, exp.Field, true)
+	case schema.NotExist:
+		return self.presenceCandidates(lookup, exp.Field, false)
+	default:
+		return nil, false
+	}
+	for _, value := range values {
+		if value == nil || !reflect.TypeOf(value).Comparable() {
+			return nil, false
+		}
+	}
+	if strings.Contains(field, ".") {
+		// Indexes only cover top level fields
+		return nil, false
+	}
+	idx := self.fieldIndex(field)
+	if idx == nil || idx.Fold {
+		// A folded index matches more than the exact value
+		return nil, false
+	}
+	for _, exp := range lookup.Sort() {
+		// All the matching items of an equality hold the same value, sorting
+		// on the field keeps them in the default order
+		if len(values) != 1 || (exp != field && exp != "-"+field) {
+			return nil, false
+		}
+	}
+
+	var ids []interface{}
+	for _, value := range values {
+		ids = append(ids, idx.lookup(value)...)
+	}
+	// Return the ids in the handler's order like a full scan would
+	return self.inIDsOrder(self.visible(ids)), true
+}
+
+// presenceCandidates returns, in the handler's order, the ids of the items
+// holding field if exists is set, lacking it otherwise. The index of a field
+// tracks which items hold it, whatever their value.
+func (self *FileStoreHandler) presenceCandidates(lookup *resource.Lookup, field string, exists bool) ([]interface{}, bool) {
+	if len(lookup.Sort()) > 0 || strings.Contains(field, ".") {
+		return nil, false
+	}
+	idx := self.fieldIndex(field)
+	if idx == nil {
+		return nil, false
+	}
+	var ids []interface{}
+	if exists {
+		ids = make([]interface{}, 0, len(idx.Keys))
+		for id := range idx.Keys {
+			ids = append(ids, id)
+		}
+		return self.inIDsOrder(self.visible(ids)), true
+	}
+	for _, id := range self.ids {
+		if _, hole := id.(holeID); hole {
+			continue
+		}
+		if _, found := idx.Keys[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+	return self.visible(ids), true
+}
+
+// findFromIndex serves a lookup resolved by indexCandidates straight from the
+// index: only the items of the requested window are decoded. It returns a nil
+// list when the lookup can't be served this way.
+func (self *FileStoreHandler) findFromIndex(lookup *resource.Lookup, w window) (*resource.ItemList, error) {
+	ids, ok := self.indexCandidates(lookup)
+	if !ok {
+		return nil, nil
+	}
+	total := len(ids)
+	start, end := w.bounds(total)
+	if err := self.checkResultSize(end - start); err != nil {
+		return nil, err
+	}
+	items := make([]*resource.Item, 0, end-start)
+	for _, id := range ids[start:end] {
+		item, found, err := self.peekValid(id)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// Skipped, see SkipCorrupt
+			total--
+			continue
+		}
+		self.touch(id)
+		items = append(items, w.copy(item))
+	}
+	return &resource.ItemList{Total: total, Page: w.page, Items: items}, nil
+}
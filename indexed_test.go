@@ -0,0 +1,64 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestIndexedFields(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithIndexedFields("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"k": 1}),
+		mkitem("b", map[string]interface{}{"k": 2}),
+		mkitem("c", map[string]interface{}{"k": 1}),
+		mkitem("d", map[string]interface{}{"k": 3}),
+	})
+	if h.fieldIndex("k") == nil {
+		t.Fatal("no index")
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.In{Field: "k", Values: []schema.Value{3, 1}}})
+	if _, ok := h.indexCandidates(l); !ok {
+		t.Fatal("not indexed")
+	}
+	list, err := h.Find(ctx, l, 1, -1)
+	if err != nil || list.Total != 3 || list.Items[0].ID != "a" || list.Items[1].ID != "c" || list.Items[2].ID != "d" {
+		t.Fatal(err, list)
+	}
+	l = resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "k", Value: 1}})
+	if n, _ := h.Count(ctx, l); n != 2 {
+		t.Fatal(n)
+	}
+	h.Update(ctx, mkitem("a", map[string]interface{}{"k": 2}), list.Items[0])
+	if n, _ := h.Count(ctx, l); n != 1 {
+		t.Fatal(n)
+	}
+}
+
+func benchEqual(b *testing.B, opts ...Option) {
+	h, _ := NewHandlerWithOptions(b.TempDir(), "c", opts...)
+	items := make([]*resource.Item, 10000)
+	for i := range items {
+		items[i] = mkitem(fmt.Sprint(i), map[string]interface{}{"k": i % 100})
+	}
+	h.Insert(context.Background(), items)
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "k", Value: 42}})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Find(context.Background(), l, 1, 10)
+	}
+}
+
+func BenchmarkEqualIndexed(b *testing.B)  { benchEqual(b, WithIndexedFields("k")) }
+func BenchmarkEqualFullScan(b *testing.B) { benchEqual(b) }
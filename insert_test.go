@@ -0,0 +1,45 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestInsertAtomic(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", []string{"name"})
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("z", map[string]interface{}{"id": "z", "name": "z"})})
+	v := h.Version()
+	deep := map[string]interface{}{}
+	cur := deep
+	for i := 0; i < 200; i++ {
+		n := map[string]interface{}{}
+		cur["x"] = n
+		cur = n
+	}
+	err := h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"id": "a", "name": "a"}),
+		mkitem("b", map[string]interface{}{"id": "b", "deep": deep}),
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if n, _ := h.Count(ctx, resource.NewLookup()); n != 1 || h.idCount() != 1 || h.Version() != v {
+		t.Fatal(n, h.ids)
+	}
+	h2 := newH(t, d, "c", nil)
+	if n, _ := h2.Count(ctx, resource.NewLookup()); n != 1 {
+		t.Fatal(n)
+	}
+	// retry succeeds without duplicate ids
+	h = h2
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"id": "a", "name": "a"})}); err != nil {
+		t.Fatal(err)
+	}
+	if h.idCount() != 2 {
+		t.Fatal(h.ids)
+	}
+}
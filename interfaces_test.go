@@ -0,0 +1,50 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+)
+
+func TestRestLayerResource(t *testing.T) {
+	h, err := NewHandler(tmpdir(t), "items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	index := resource.NewIndex()
+	index.Bind("items", schema.Schema{
+		"id":   schema.IDField,
+		"name": schema.Field{Filterable: true, Validator: &schema.String{}},
+	}, h, resource.DefaultConf)
+	api, err := rest.NewHandler(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(api)
+	defer s.Close()
+
+	res, err := http.Post(s.URL+"/items", "application/json", strings.NewReader(`{"name": "x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated || h.Len() != 1 {
+		t.Fatal(res.Status, h.IDs())
+	}
+	res, err = http.Get(s.URL + "/items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || !strings.Contains(string(body), `"x"`) {
+		t.Fatal(res.Status, string(body))
+	}
+}
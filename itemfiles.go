@@ -0,0 +1,185 @@
+package filestore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// With PerItemFiles set, the collection is stored as a directory named after
+// the datafile holding one file per item, named after its id by itemFileName.
+// A save only writes the files of the items changed since the previous one
+// and removes the files of the deleted items, instead of rewriting the whole
+// collection. The files hold the records, encrypted if the handler is, and are
+// never compressed. The order of the ids isn't stored, the items are loaded
+// in the order of sortIDs, and neither are the AutoIncrement sequence, which
+// restarts after the greatest stored id, nor the indexes, which are rebuilt.
+// MergeOnSave has no effect in this mode.
+
+// itemFileName returns the name of the file of the item with id: the type of
+// id, then '_' and its value. The bytes other than lowercase letters, digits
+// and '-' are escaped as %xx so the name is valid on every platform and two
+// ids differing only by their case don't collide on case insensitive file
+// systems. The type prefix gets the string "1" and the int 1 distinct files,
+// whatever their values look like, and keeps the names reserved by Windows
+// (con, nul, aux...) from being used, as no type is named after them.
+func itemFileName(id interface{}) string {
+	var b strings.Builder
+	escapeFileName(&b, fmt.Sprintf("%T", id))
+	b.WriteByte('_')
+	escapeFileName(&b, fmt.Sprint(id))
+	return b.String()
+}
+
+// escapeFileName writes name to b with its bytes escaped, see itemFileName
+func escapeFileName(b *strings.Builder, name string) {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(b, "%%%02x", c)
+		}
+	}
+}
+
+// markChanged records that the record of id changed and its file must be
+// written or removed by the next save
+func (self *FileStoreHandler) markChanged(id interface{}) {
+	if !self.PerItemFiles {
+		return
+	}
+	if self.changedItems == nil {
+		self.changedItems = map[interface{}]bool{}
+	}
+	if self.OrderedWrites && !self.changedItems[id] {
+		self.changedOrder = append(self.changedOrder, id)
+	}
+	self.changedItems[id] = true
+}
+
+// readItemFiles loads the items from their files
+func (self *FileStoreHandler) readItemFiles() error {
+	start, size := time.Now(), 0
+	if !self.ReadOnly {
+		if err := os.MkdirAll(self.database_file, self.dirMode()); err != nil {
+			return err
+		}
+	}
+	files, err := ioutil.ReadDir(self.database_file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		// Skip the temporary files of interrupted writes, the item files
+		// never have a dot in their name
+		if file.IsDir() || strings.Contains(file.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(self.database_file, file.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		size += len(data)
+		record, err := self.decrypt(data)
+		if err != nil {
+			return fmt.Errorf("filestore: can't read item file %s: %v", path, err)
+		}
+		var item resource.Item
+		if err := decodeRecord(record, &item); err != nil {
+			return fmt.Errorf("filestore: can't read item file %s: %v", path, err)
+		}
+		id := canonicalID(item.ID)
+		if name := itemFileName(id); name != file.Name() && !self.ReadOnly {
+			// Named by an older version
+			if err := os.Rename(path, filepath.Join(self.database_file, name)); err != nil {
+				return err
+			}
+		}
+		if _, found := self.items[id]; !found {
+			self.ids = append(self.ids, id)
+		}
+		self.setRecord(id, record)
+	}
+	sortIDs(self.ids)
+	self.setIDs(self.ids)
+	for _, id := range self.ids {
+		self.advanceSequence(id)
+	}
+	self.changedItems = nil
+	self.changedOrder = nil
+	self.debugFields("Read database", map[string]interface{}{
+		"items":    self.idCount(),
+		"bytes":    size,
+		"duration": time.Since(start),
+	})
+	if err := self.rebuildIndexes(); err != nil {
+		return err
+	}
+	for _, id := range self.ids {
+		self.offload(id)
+	}
+	return nil
+}
+
+// saveItemFiles writes the files of the items changed since the last save and
+// removes the ones of the deleted items, in the order of their changes with
+// OrderedWrites
+func (self *FileStoreHandler) saveItemFiles() error {
+	if !self.OrderedWrites {
+		for id := range self.changedItems {
+			if err := self.saveItemFile(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i, id := range self.changedOrder {
+		if !self.changedItems[id] {
+			continue
+		}
+		if err := self.saveItemFile(id); err != nil {
+			self.changedOrder = self.changedOrder[i:]
+			return err
+		}
+	}
+	self.changedOrder = nil
+	return nil
+}
+
+// saveItemFile writes or removes the file of the changed item id
+func (self *FileStoreHandler) saveItemFile(id interface{}) error {
+	path := filepath.Join(self.database_file, itemFileName(id))
+	record, found := self.items[id]
+	if !found {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(self.changedItems, id)
+		return nil
+	}
+	if record == nil {
+		// Offloaded, its file holds it already
+		delete(self.changedItems, id)
+		return nil
+	}
+	data, err := self.encrypt(record)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data, self.fileMode(), self.Durable); err != nil {
+		return err
+	}
+	delete(self.changedItems, id)
+	self.offload(id)
+	return nil
+}
@@ -0,0 +1,99 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestPerItemFiles(t *testing.T) {
+	d := tmpdir(t)
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(d, "c", WithPerItemFiles(), WithUniqueFields("u"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("B", map[string]interface{}{"u": 1}), mkitem("b", map[string]interface{}{"u": 2}), mkitem(3, map[string]interface{}{"u": 3})}); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := ioutil.ReadDir(filepath.Join(d, "c"))
+	if len(files) != 3 {
+		t.Fatal(len(files))
+	}
+	l, _ := h.Find(ctx, &resource.Lookup{}, 1, 10)
+	var b *resource.Item
+	for _, it := range l.Items {
+		if it.ID == "b" {
+			b = it
+		}
+	}
+	if err := h.Delete(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	h, err = NewHandlerWithOptions(d, "c", WithPerItemFiles(), WithUniqueFields("u"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	l, _ = h.Find(ctx, &resource.Lookup{}, 1, 10)
+	if l.Total != 2 || l.Items[0].ID != 3 || l.Items[1].ID != "B" {
+		t.Fatal(l.Total, l.Items)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("z", map[string]interface{}{"u": 1})}); err == nil {
+		t.Fatal("unique index not rebuilt")
+	}
+	if n := itemFileName("a.B/c"); n != "string_a%2e%42%2fc" {
+		t.Fatal(n)
+	}
+}
+
+func TestItemFileNames(t *testing.T) {
+	names := map[string]bool{}
+	for _, id := range []interface{}{"int:1", 1, "1", int64(1), "con", "NUL", "a_b", "a%5fb"} {
+		name := itemFileName(id)
+		if names[name] {
+			t.Fatalf("%T %v collides on %s", id, id, name)
+		}
+		names[name] = true
+	}
+	if n := itemFileName("con"); n != "string_con" {
+		t.Fatal(n)
+	}
+
+	// The files named by the former scheme are renamed on load
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithPerItemFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	dir := filepath.Join(d, "c")
+	if err := os.Rename(filepath.Join(dir, "string_a"), filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	h, err = NewHandlerWithOptions(d, "c", WithPerItemFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := h.Get(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Update(ctx, mkitem("a", map[string]interface{}{"x": 1}), o); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 || files[0].Name() != "string_a" {
+		t.Fatal(files)
+	}
+}
@@ -0,0 +1,155 @@
+package filestore
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// ConflictStrategy tells ImportJSONL what to do with an item whose id is
+// already stored
+type ConflictStrategy int
+
+const (
+	// ConflictFail fails the import with resource.ErrConflict
+	ConflictFail ConflictStrategy = iota
+	// ConflictSkip keeps the stored item and skips the imported one
+	ConflictSkip
+	// ConflictOverwrite replaces the stored item with the imported one
+	ConflictOverwrite
+)
+
+// ImportJSONL stores the items of a stream of JSON objects, usually one per
+// line, and returns how many were imported and skipped. An object with a
+// payload member is an item in the Export format, any other object is the
+// payload of an item whose id is the one of its IDField. Like for Import,
+// numbers are read as float64 and missing ETags computed from the payload.
+// Items without an id get one from IDGenerator or AutoIncrement.
+//
+// The items are decoded and stored one at a time, the stream is never held in
+// memory, and the collection is persisted once at the end. The import is all
+// or nothing: if an object can't be decoded or stored, ctx is canceled or the
+// collection can't be persisted, the items already imported are removed or
+// restored and the error returned. The handler is locked while r is read.
+func (self *FileStoreHandler) ImportJSONL(ctx context.Context, r io.Reader, onConflict ConflictStrategy) (imported, skipped int, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return 0, 0, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		undo := self.beginUndo()
+		err := func() error {
+			dec := json.NewDecoder(r)
+			for i := 0; ; i++ {
+				if err := checkCanceled(ctx, i); err != nil {
+					return err
+				}
+				item, err := self.decodeJSONLItem(dec)
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					return err
+				}
+				self.canonicalizeIDs(item)
+				if err := self.generateIDs([]*resource.Item{item}); err != nil {
+					return err
+				}
+				if err := checkID(item.ID); err != nil {
+					return err
+				}
+				if isZeroID(item.ID) {
+					return ErrMissingID
+				}
+				if item.ETag == "" {
+					if item.ETag, err = ETag(item.Payload); err != nil {
+						return err
+					}
+				}
+				if _, found, _ := self.peek(item.ID); found {
+					switch onConflict {
+					case ConflictSkip:
+						skipped++
+						continue
+					case ConflictOverwrite:
+					default:
+						return resource.ErrConflict
+					}
+				}
+				invalid, err := self.checkUnique(ctx, item)
+				if err != nil {
+					return err
+				}
+				if invalid != nil {
+					return invalid
+				}
+				record, stored, err := self.record(item.ID)
+				if err != nil {
+					return err
+				}
+				staged, encoded, err := self.encode(item)
+				if err != nil {
+					return err
+				}
+				added, grow := 1, recordSize(encoded)
+				if stored {
+					added, grow = 0, grow-recordSize(record)
+				}
+				if err := self.makeRoom(added, grow, map[interface{}]bool{item.ID: true}); err != nil {
+					return err
+				}
+				self.storeRecord(staged, encoded)
+				undo.change(item.ID, record)
+				if !stored {
+					// A stored item keeps its position, even an expired
+					// or soft deleted one
+					self.appendID(item.ID)
+				}
+				imported++
+			}
+			if imported == 0 {
+				return nil
+			}
+			self.sealUndo(undo)
+			return self.persistData()
+		}()
+		if err != nil {
+			self.undo(undo)
+			imported, skipped = 0, 0
+		}
+		return err
+	})
+	count(&self.counters.inserts, imported)
+	return imported, skipped, err
+}
+
+// decodeJSONLItem decodes the next item of an ImportJSONL stream
+func (self *FileStoreHandler) decodeJSONLItem(dec *json.Decoder) (*resource.Item, error) {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, err
+	}
+	var e exportedItem
+	if _, found := members["payload"]; found {
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(raw, &e.Payload); err != nil {
+		return nil, err
+	}
+	if e.Payload == nil {
+		e.Payload = map[string]interface{}{}
+	}
+	if e.ID == nil {
+		e.ID = e.Payload[self.idField()]
+	}
+	return &resource.Item{ID: e.ID, ETag: e.ETag, Updated: e.Updated, Payload: e.Payload}, nil
+}
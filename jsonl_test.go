@@ -0,0 +1,62 @@
+package filestore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestImportJSONL(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithUniqueFields("name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"name": "A"})})
+	in := `{"id": "a", "name": "A2"}
+{"id": "b", "name": "B"}
+{"id": "c", "etag": "", "payload": {"id": "c", "name": "C"}}
+`
+	if _, _, err := h.ImportJSONL(ctx, strings.NewReader(in), ConflictFail); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+	if h.Len() != 1 {
+		t.Fatal(h.Len())
+	}
+	imported, skipped, err := h.ImportJSONL(ctx, strings.NewReader(in), ConflictSkip)
+	if err != nil || imported != 2 || skipped != 1 {
+		t.Fatal(imported, skipped, err)
+	}
+	// A unique violation restores the overwritten item
+	bad := `{"id": "a", "name": "Z"}
+{"id": "d", "name": "B"}`
+	if _, _, err := h.ImportJSONL(ctx, strings.NewReader(bad), ConflictOverwrite); err == nil {
+		t.Fatal("unique violation accepted")
+	}
+	l, _ := h.MultiGet(ctx, []interface{}{"a"})
+	if l[0].Payload["name"] != "A" {
+		t.Fatal(l[0].Payload)
+	}
+	if _, _, err := h.ImportJSONL(ctx, strings.NewReader(`{"id": "a", "name": "A3"}`), ConflictOverwrite); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := h.ImportJSONL(ctx, strings.NewReader(`{"id": "e"} {`), ConflictOverwrite); err == nil {
+		t.Fatal("torn stream accepted")
+	}
+	h.Close()
+	h, _ = NewHandler(d, "c", nil)
+	defer h.Close()
+	if ids := h.IDs(); len(ids) != 3 || ids[0] != "a" || ids[2] != "c" {
+		t.Fatal(ids)
+	}
+	l, _ = h.MultiGet(ctx, []interface{}{"a", "c"})
+	if l[0].Payload["name"] != "A3" || l[1].Payload["name"] != "C" {
+		t.Fatal(l[0].Payload, l[1].Payload)
+	}
+	if e, _ := ETag(l[1].Payload); e != l[1].ETag {
+		t.Fatal("etag")
+	}
+}
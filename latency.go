@@ -10,18 +10,78 @@ import (
 // The method first wait for the given latency while monitoring ctx.Done. If context is canceled
 // during the wait, the context error is returned.
 // If latency passed, the handler is executed and it's error output is returned.
+// Without latency, the handler is only executed if ctx isn't done already, as
+// it may have expired while the operation waited for the handler's lock.
 func handleWithLatency(latency time.Duration, ctx context.Context, handler func() error) error {
 	if latency == 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		return handler()
 	}
 
+	// A timer rather than time.After so it is released right away when the
+	// context is canceled first
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
 	select {
 	case <-ctx.Done():
 		// Monitor context cancellation. cancellation may happend if the client closed the connection
 		// or if the configured request timeout has been reached.
 		return ctx.Err()
-	case <-time.After(latency):
+	case <-timer.C:
 		// Wait for the given latency before the execute the provided handler.
 		return handler()
 	}
 }
+
+// waitItems introduces the artificial latency of the n items an operation
+// processes, PerItemLatency each, and returns the context error if ctx is
+// canceled during the wait
+func (self *FileStoreHandler) waitItems(ctx context.Context, n int) error {
+	if self.PerItemLatency <= 0 || n <= 0 {
+		return nil
+	}
+	return handleWithLatency(self.PerItemLatency*time.Duration(n), ctx, func() error { return nil })
+}
+
+// opContext returns the context of an operation: ctx with a deadline OpTimeout
+// from now if it is set
+func (self *FileStoreHandler) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if self.OpTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, self.OpTimeout)
+}
+
+// observe reports an operation op started at start to OnOperation, if set. n
+// is the number of items the operation handled, reported as 0 if it failed.
+func (self *FileStoreHandler) observe(ctx context.Context, op string, start time.Time, n int, err error) {
+	if self.OnOperation == nil {
+		return
+	}
+	if err != nil {
+		n = 0
+	}
+	self.OnOperation(ctx, op, time.Since(start), n)
+}
+
+// cancelCheckInterval is the number of items scanned in between two checks
+// of the context cancellation
+const cancelCheckInterval = 256
+
+// checkCanceled returns the context error if the context is done and i, the
+// number of items already scanned, is a multiple of cancelCheckInterval. It
+// lets long scans abort once the client went away without paying a select
+// on every item.
+func checkCanceled(ctx context.Context, i int) error {
+	if i%cancelCheckInterval != 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,20 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestLatencyCanceled(t *testing.T) {
+	h := NewMemoryHandler(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := h.Find(ctx, resource.NewLookup(), 1, -1)
+	if err != context.DeadlineExceeded || time.Since(start) > 500*time.Millisecond {
+		t.Fatal(err, time.Since(start))
+	}
+}
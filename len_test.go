@@ -0,0 +1,29 @@
+package filestore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestLenIDs(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Len() != 0 || len(h.IDs()) != 0 {
+		t.Fatal("not empty")
+	}
+	h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"id": "a"}), mkitem("b", map[string]interface{}{"id": "b"})})
+	ids := h.IDs()
+	if h.Len() != 2 || !reflect.DeepEqual(ids, []interface{}{"a", "b"}) {
+		t.Fatal(ids)
+	}
+	ids[0] = "z"
+	if h.IDs()[0] != "a" {
+		t.Fatal("shared")
+	}
+}
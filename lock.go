@@ -0,0 +1,40 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrLocked is returned by NewHandler when the datafile is already opened by
+// another handler, in this process or another one
+var ErrLocked = errors.New("datafile locked by another handler")
+
+// lockDatafile takes an exclusive advisory lock on the lock file next to the
+// datafile so two handlers can't overwrite each other's writes. The returned
+// function releases the lock. The lock file itself is left in place: removing
+// it would let another handler lock a new file while the old one is still
+// held.
+func (self *FileStoreHandler) lockDatafile() (release func() error, err error) {
+	return lockPath(self.database_file, self.fileMode())
+}
+
+// lockPath is lockDatafile for the file at path, the lock file being created
+// with perm
+func lockPath(path string, perm os.FileMode) (release func() error, err error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if err == errWouldBlock {
+			err = ErrLocked
+		}
+		return nil, fmt.Errorf("filestore: %s: %w", path, err)
+	}
+	return func() error {
+		unlockFile(f)
+		return f.Close()
+	}, nil
+}
@@ -0,0 +1,20 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package filestore
+
+import (
+	"errors"
+	"os"
+)
+
+// Advisory locks aren't available, datafiles are left unlocked
+var errWouldBlock = errors.New("unreachable")
+
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
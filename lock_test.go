@@ -0,0 +1,55 @@
+package filestore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestLock(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandler(d, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHandler(d, "c", nil); !errors.Is(err, ErrLocked) {
+		t.Fatal(err)
+	}
+	h.Close()
+	h2, err := NewHandler(d, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2.Close()
+	s1, err := NewSharedHandler(d, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, _ := NewSharedHandler(d, "c", nil)
+	ctx := context.Background()
+	s1.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"id": "a"})})
+	s2.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"id": "b"})})
+	if n, _ := s2.Count(ctx, resource.NewLookup()); n != 2 {
+		t.Fatal(n)
+	}
+}
+
+func TestLockFileMode(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithFileMode(0600, 0700))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	info, err := os.Stat(filepath.Join(d, "c.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatal(info.Mode())
+	}
+}
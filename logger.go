@@ -0,0 +1,58 @@
+package filestore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Logger receives the messages of a handler, *log.Logger implements it
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// FieldLogger is a Logger also receiving the messages about the loads and the
+// saves with their fields, for structured logging. These messages are sent to
+// LogFields rather than Printf. The fields always hold the path of the
+// datafile under "database".
+type FieldLogger interface {
+	Logger
+	LogFields(msg string, fields map[string]interface{})
+}
+
+// logf sends a message to the Logger, it is dropped if no Logger is set
+func (self *FileStoreHandler) logf(format string, v ...interface{}) {
+	if self.Logger != nil {
+		self.Logger.Printf(format, v...)
+	}
+}
+
+// debugf sends a message about the routine work of the handler, like the
+// saves, to the Logger when DebugLog is set
+func (self *FileStoreHandler) debugf(format string, v ...interface{}) {
+	if self.DebugLog {
+		self.logf(format, v...)
+	}
+}
+
+// debugFields is debugf for a message msg with fields. A Logger which isn't a
+// FieldLogger gets msg followed by the path of the datafile and the fields as
+// key=value pairs sorted by key.
+func (self *FileStoreHandler) debugFields(msg string, fields map[string]interface{}) {
+	if !self.DebugLog || self.Logger == nil {
+		return
+	}
+	fields["database"] = self.database_file
+	if l, ok := self.Logger.(FieldLogger); ok {
+		l.LogFields(msg, fields)
+		return
+	}
+	pairs := make([]string, 0, len(fields))
+	for key, value := range fields {
+		if key != "database" {
+			pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+	sort.Strings(pairs)
+	self.logf(msg+" %s: %s", self.database_file, strings.Join(pairs, " "))
+}
@@ -0,0 +1,35 @@
+package filestore
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestQuietHandler(t *testing.T) {
+	var std bytes.Buffer
+	log.SetOutput(&std)
+	defer log.SetOutput(os.Stderr)
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"x": 1})})
+	h.Close()
+	if std.Len() != 0 {
+		t.Fatal(std.String())
+	}
+	var own bytes.Buffer
+	h, err := NewHandlerWithOptions(d, "c", WithDebugLog(log.New(&own, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(context.Background(), []*resource.Item{mkitem("b", map[string]interface{}{"x": 1})})
+	h.Close()
+	if std.Len() != 0 || !strings.Contains(own.String(), "Saved database") {
+		t.Fatal(std.String(), own.String())
+	}
+}
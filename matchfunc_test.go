@@ -0,0 +1,63 @@
+package filestore
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+// near is a custom operator matching the points within Radius of X, Y
+type near struct {
+	X, Y, Radius float64
+}
+
+func (near) Match(payload map[string]interface{}) bool { return true }
+
+func nearMatch(lookup *resource.Lookup, payload map[string]interface{}) bool {
+	for _, exp := range lookup.Filter() {
+		if n, ok := exp.(near); ok {
+			x, _ := payload["x"].(float64)
+			y, _ := payload["y"].(float64)
+			if math.Hypot(x-n.X, y-n.Y) > n.Radius {
+				return false
+			}
+		} else if !(schema.Query{exp}).Match(payload) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMatchFunc(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithMatchFunc(nearMatch), WithIndexedFields("kind"), WithStrictQueries())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"x": 0.0, "y": 0.0, "kind": "shop"}),
+		mkitem("b", map[string]interface{}{"x": 1.0, "y": 1.0, "kind": "shop"}),
+		mkitem("c", map[string]interface{}{"x": 5.0, "y": 5.0, "kind": "shop"}),
+		mkitem("d", map[string]interface{}{"x": 0.5, "y": 0.0, "kind": "bar"}),
+	})
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{near{0, 0, 2}, schema.Equal{Field: "kind", Value: "shop"}})
+	l.SetSorts([]string{"-x"})
+	list, err := h.Find(ctx, l, 1, -1)
+	if err != nil || list.Total != 2 || list.Items[0].ID != "b" || list.Items[1].ID != "a" {
+		t.Fatal(list, err)
+	}
+	if n, err := h.Clear(ctx, l); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+	// Only d is left nearby
+	l = resource.NewLookup()
+	l.AddQuery(schema.Query{near{0, 0, 2}})
+	if n, _ := h.Count(ctx, l); n != 1 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,50 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestMaxResultItems(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithMaxResultItems(5), WithIndexedFields("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	var items []*resource.Item
+	for i := 0; i < 10; i++ {
+		items = append(items, mkitem(i+1, map[string]interface{}{"k": i % 2, "n": i}))
+	}
+	h.Insert(ctx, items)
+	if _, err := h.Find(ctx, resource.NewLookup(), 1, -1); err != ErrTooManyResults {
+		t.Fatal(err)
+	}
+	list, err := h.Find(ctx, resource.NewLookup(), 2, 5)
+	if err != nil || len(list.Items) != 5 || list.Total != 10 {
+		t.Fatal(list, err)
+	}
+	if list, err := h.Find(ctx, resource.NewLookup(), 1, 0); err != nil || list.Total != 10 {
+		t.Fatal(list, err)
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "k", Value: 1}})
+	if list, err := h.Find(ctx, l, 1, -1); err != nil || len(list.Items) != 5 {
+		t.Fatal(list, err)
+	}
+	l = resource.NewLookup()
+	l.AddQuery(schema.Query{schema.GreaterOrEqual{Field: "n", Value: 4.0}})
+	if _, err := h.Find(ctx, l, 1, -1); err != ErrTooManyResults {
+		t.Fatal(err)
+	}
+	if _, err := h.FindWithOffset(ctx, resource.NewLookup(), 4, 0); err != ErrTooManyResults {
+		t.Fatal(err)
+	}
+	h.MaxResultItems = -1
+	if list, err := h.Find(ctx, resource.NewLookup(), 1, -1); err != nil || len(list.Items) != 10 {
+		t.Fatal(list, err)
+	}
+}
@@ -0,0 +1,63 @@
+package filestore
+
+// recordOverhead approximates the memory used to keep track of a record on top
+// of its encoded bytes: the map entry and its slot in the ids slice
+const recordOverhead = 64
+
+func recordSize(data []byte) int64 {
+	return int64(len(data)) + recordOverhead
+}
+
+// MemoryBytes returns an estimation of the memory used by the stored records
+func (self *FileStoreHandler) MemoryBytes() int64 {
+	self.RLock()
+	defer self.RUnlock()
+	return self.memoryBytes
+}
+
+// checkMemory tells if storing data for id would exceed MaxMemoryBytes
+func (self *FileStoreHandler) checkMemory(id interface{}, data []byte) error {
+	size := self.memoryBytes + recordSize(data)
+	if old, found := self.items[id]; found {
+		size -= recordSize(old)
+	}
+	return self.checkMemorySize(size)
+}
+
+// checkMemorySize tells if size bytes of records would exceed MaxMemoryBytes
+func (self *FileStoreHandler) checkMemorySize(size int64) error {
+	if self.MaxMemoryBytes > 0 && size > self.MaxMemoryBytes {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// setRecord stores the encoded record of an item, keeping the memory
+// accounting up to date
+func (self *FileStoreHandler) setRecord(id interface{}, data []byte) {
+	if old, found := self.items[id]; found {
+		self.memoryBytes -= recordSize(old)
+	}
+	self.items[id] = data
+	self.memoryBytes += recordSize(data)
+	self.cache.invalidate(id)
+	self.refBlobs(id)
+	self.deriveFields(id)
+	self.markChanged(id)
+	self.logWAL(walPut, id, data)
+}
+
+// removeRecord removes the encoded record of an item, keeping the memory
+// accounting up to date
+func (self *FileStoreHandler) removeRecord(id interface{}) {
+	if old, found := self.items[id]; found {
+		self.memoryBytes -= recordSize(old)
+		delete(self.items, id)
+		self.cache.invalidate(id)
+		self.unrefBlobs(id)
+		delete(self.derived, id)
+		self.forget(id)
+		self.markChanged(id)
+		self.logWAL(walDelete, id, nil)
+	}
+}
@@ -0,0 +1,120 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestMemoryHandlerNoDisk(t *testing.T) {
+	d := tmpdir(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(d); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	ctx := context.Background()
+	h := NewMemoryHandler(0)
+	if err := h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"name": "x"}),
+		mkitem("b", map[string]interface{}{"name": "y"}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "name", Value: "y"}})
+	list, err := h.Find(ctx, l, 1, 10)
+	if err != nil || list.Total != 1 || list.Items[0].ID != "b" {
+		t.Fatal(list, err)
+	}
+	if err := h.Update(ctx, mkitem("b", map[string]interface{}{"name": "z"}), list.Items[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Delete(ctx, &resource.Item{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(d)
+	if err != nil || len(files) != 0 {
+		t.Fatal(files, err)
+	}
+}
+
+func TestMaxMemoryBytes(t *testing.T) {
+	ctx := context.Background()
+	h := newH(t, tmpdir(t), "c", nil)
+	item := func(id string) *resource.Item { return mkitem(id, map[string]interface{}{"s": "abcdef"}) }
+	if err := h.Insert(ctx, []*resource.Item{item("a")}); err != nil {
+		t.Fatal(err)
+	}
+	// Room for two items
+	one := h.MemoryBytes()
+	h.MaxMemoryBytes = one*2 + one/2
+	if err := h.Insert(ctx, []*resource.Item{item("b")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{item("c")}); err != ErrMemoryLimit {
+		t.Fatal(err)
+	}
+	original, _ := h.Get(ctx, "a")
+	large := mkitem("a", map[string]interface{}{"s": strings.Repeat("x", int(one))})
+	if err := h.Update(ctx, large, original); err != ErrMemoryLimit {
+		t.Fatal(err)
+	}
+	if h.MemoryBytes() != one*2 || h.Len() != 2 {
+		t.Fatal(h.MemoryBytes(), h.Len())
+	}
+	if err := h.Delete(ctx, original); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{item("c")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxMemoryBytesEviction(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithMaxItems(0, EvictOldest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	item := func(id string) *resource.Item { return mkitem(id, map[string]interface{}{"s": "abcdef"}) }
+	if err := h.Insert(ctx, []*resource.Item{item("a")}); err != nil {
+		t.Fatal(err)
+	}
+	one := h.MemoryBytes()
+	h.MaxMemoryBytes = one*2 + one/2
+	for _, id := range []string{"b", "c"} {
+		if err := h.Insert(ctx, []*resource.Item{item(id)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := h.Get(ctx, "a"); err != resource.ErrNotFound {
+		t.Fatal(err)
+	}
+	if _, err := h.Upsert(ctx, item("d"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Get(ctx, "b"); err != resource.ErrNotFound {
+		t.Fatal(err)
+	}
+	// Nothing is evicted for an item which can't fit anyway
+	large := mkitem("e", map[string]interface{}{"s": strings.Repeat("x", int(one)*3)})
+	if err := h.Insert(ctx, []*resource.Item{large}); err != ErrMemoryLimit {
+		t.Fatal(err)
+	}
+	if h.Len() != 2 || h.MemoryBytes() != one*2 {
+		t.Fatal(h.Len(), h.MemoryBytes())
+	}
+}
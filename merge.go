@@ -0,0 +1,103 @@
+package filestore
+
+import (
+	"os"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// fileStamp identifies a version of the datafile on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stampDatafile records the version of the datafile currently on disk
+func (self *FileStoreHandler) stampDatafile() {
+	info, err := self.fileSystem().Stat(self.database_file)
+	if err != nil {
+		self.fileStamp = fileStamp{}
+		return
+	}
+	self.fileStamp = fileStamp{info.ModTime(), info.Size()}
+}
+
+// mergeDatafile merges the datafile into memory when another process modified
+// it since it was last read or written by this handler. The merge is last
+// writer wins per item: an item only on disk is added, an item present on both
+// sides keeps the version with the most recent Updated time.
+//
+// This is a pragmatic multi-writer mode for low contention cases where each
+// process edits different items, it is not a correct distributed merge:
+//   - deletions aren't tracked, so an item deleted by one process comes back
+//     for as long as the other one still has it;
+//   - the outcome of concurrent edits of the same item depends on the clocks of
+//     the processes agreeing;
+//   - unique constraints aren't checked on merged items;
+//   - a write by the other process between the merge and the save is lost.
+func (self *FileStoreHandler) mergeDatafile() error {
+	info, err := self.fileSystem().Stat(self.database_file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if (fileStamp{info.ModTime(), info.Size()}) == self.fileStamp {
+		return nil
+	}
+
+	data, err := self.fileSystem().ReadFile(self.database_file)
+	if err != nil {
+		return err
+	}
+	if data, err = self.openDatafile(data); err != nil {
+		return err
+	}
+	content, err := parseDatafile(data)
+	if err != nil {
+		return err
+	}
+	disk, diskIDs := content.items, content.ids
+	if content.sequence > self.sequence {
+		// Don't assign the ids assigned by the other process again
+		self.sequence = content.sequence
+	}
+	if diskIDs == nil {
+		for id := range disk {
+			diskIDs = append(diskIDs, id)
+		}
+		sortIDs(diskIDs)
+	}
+
+	merged := 0
+	for _, id := range diskIDs {
+		record := disk[id]
+		if _, found := self.items[id]; !found {
+			self.setRecord(id, record)
+			self.appendID(id)
+			merged++
+			continue
+		}
+		local, _, err := self.decodeItem(id)
+		if err != nil {
+			return err
+		}
+		var external resource.Item
+		if err := decodeRecord(record, &external); err != nil {
+			return err
+		}
+		if external.Updated.After(local.Updated) {
+			self.setRecord(id, record)
+			merged++
+		}
+	}
+	if merged > 0 {
+		self.resetChanges()
+		if err := self.rebuildIndexes(); err != nil {
+			return err
+		}
+	}
+	self.debugf("Merged %d external changes into database %s", merged, self.database_file)
+	return nil
+}
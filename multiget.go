@@ -0,0 +1,50 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// MultiGet implements resource.MultiGetter. It looks the ids up directly in
+// the items map and returns the items in the order of the requested ids, with
+// a nil entry for each id not found.
+func (self *FileStoreHandler) MultiGet(ctx context.Context, ids []interface{}) (items []*resource.Item, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		items = make([]*resource.Item, len(ids))
+		for i, id := range ids {
+			// Checked first as the maps can't hold any id
+			if err := checkID(id); err != nil {
+				return err
+			}
+			item, _, err := self.read(id)
+			if err != nil {
+				return err
+			}
+			if item != nil {
+				items[i] = self.present(item)
+			}
+		}
+		return nil
+	})
+	return items, err
+}
+
+// read returns the item of id for a read handing it out: the shared item with
+// ReadOnlyResults, a copy otherwise, see fetch
+func (self *FileStoreHandler) read(id interface{}) (*resource.Item, bool, error) {
+	if !self.ReadOnlyResults {
+		return self.fetch(id)
+	}
+	item, found, err := self.peek(id)
+	if item != nil {
+		self.touch(id)
+	}
+	return item, found, err
+}
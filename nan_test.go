@@ -0,0 +1,32 @@
+package filestore
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+func TestValidatePayload(t *testing.T) {
+	d := tmpdir(t)
+	h, _ := NewHandlerWithOptions(d, "c", WithValidatePayload())
+	ctx := context.Background()
+	err := h.Insert(ctx, []*resource.Item{&resource.Item{ID: "a", ETag: "e", Payload: map[string]interface{}{"id": "a", "x": map[string]interface{}{"f": math.NaN()}}}})
+	if e, ok := err.(*rest.Error); !ok || e.Code != 422 || !strings.Contains(e.Message, "'x.f'") || !strings.Contains(e.Message, "NaN") {
+		t.Fatal(err)
+	}
+	err = h.Insert(ctx, []*resource.Item{&resource.Item{ID: "a", ETag: "e", Payload: map[string]interface{}{"id": "a", "l": []float64{1, math.Inf(1)}}}})
+	if e, ok := err.(*rest.Error); !ok || !strings.Contains(e.Message, "'l.1'") {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{&resource.Item{ID: "a", ETag: "e", Payload: map[string]interface{}{"id": "a", "f": 1.5, "b": []byte("x")}}}); err != nil {
+		t.Fatal(err)
+	}
+	h2, _ := NewHandlerWithOptions(tmpdir(t), "c")
+	if err := h2.Insert(ctx, []*resource.Item{&resource.Item{ID: "a", ETag: "e", Payload: map[string]interface{}{"id": "a", "f": math.NaN()}}}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package filestore
+
+import (
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// DefaultNormalizedSuffix is the suffix of the shadow fields used when
+// NormalizedSuffix isn't set
+const DefaultNormalizedSuffix = "_lc"
+
+// normalizedField returns the name of the shadow field of field
+func (self *FileStoreHandler) normalizedField(field string) string {
+	suffix := self.NormalizedSuffix
+	if suffix == "" {
+		suffix = DefaultNormalizedSuffix
+	}
+	return field + suffix
+}
+
+// normalize returns item with the shadow fields of its NormalizedFields
+// computed. Each shadow field holds the lowercased value of its field when
+// the field is a string and is removed otherwise, so it is kept in sync on
+// every store whatever the client sent. The payload of item itself isn't
+// modified.
+func (self *FileStoreHandler) normalize(item *resource.Item) *resource.Item {
+	if len(self.NormalizedFields) == 0 {
+		return item
+	}
+	payload := make(map[string]interface{}, len(item.Payload)+len(self.NormalizedFields))
+	for k, v := range item.Payload {
+		payload[k] = v
+	}
+	for _, field := range self.NormalizedFields {
+		if value, ok := payload[field].(string); ok {
+			payload[self.normalizedField(field)] = strings.ToLower(value)
+		} else {
+			delete(payload, self.normalizedField(field))
+		}
+	}
+	normalized := *item
+	normalized.Payload = payload
+	return &normalized
+}
+
+// present returns item as it should be handed out: without its derived
+// fields, and without its shadow fields if HideNormalizedFields is set
+func (self *FileStoreHandler) present(item *resource.Item) *resource.Item {
+	item = self.withoutDerivedFields(item)
+	if !self.HideNormalizedFields || len(self.NormalizedFields) == 0 || item == nil {
+		return item
+	}
+	payload := make(map[string]interface{}, len(item.Payload))
+	for k, v := range item.Payload {
+		payload[k] = v
+	}
+	for _, field := range self.NormalizedFields {
+		delete(payload, self.normalizedField(field))
+	}
+	presented := *item
+	presented.Payload = payload
+	return &presented
+}
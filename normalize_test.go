@@ -0,0 +1,84 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestNormalizedFields(t *testing.T) {
+	ctx := context.Background()
+	for _, hide := range []bool{false, true} {
+		h, err := NewHandlerWithOptions(tmpdir(t), "c", WithNormalizedFields("name"), WithIndexedFields("name_lc"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.HideNormalizedFields = hide
+		if err := h.Insert(ctx, []*resource.Item{
+			mkitem("a", map[string]interface{}{"name": "Alice"}),
+			mkitem("b", map[string]interface{}{"name": "ALICE"}),
+			mkitem("c", map[string]interface{}{"name": "Bob", "name_lc": "forged"}),
+			mkitem("d", map[string]interface{}{"name": 1}),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		find := func(value string) []interface{} {
+			t.Helper()
+			l := resource.NewLookup()
+			l.AddQuery(schema.Query{schema.Equal{Field: "name_lc", Value: value}})
+			list, err := h.Find(ctx, l, 1, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []interface{}
+			for _, item := range list.Items {
+				if _, found := item.Payload["name_lc"]; found == hide {
+					t.Errorf("hide %v: %v", hide, item.Payload)
+				}
+				ids = append(ids, item.ID)
+			}
+			return ids
+		}
+		if ids := find("alice"); len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+			t.Fatal(ids)
+		}
+		// The shadow fields can't be set by the client
+		if ids := find("bob"); len(ids) != 1 || ids[0] != "c" {
+			t.Fatal(ids)
+		}
+		if ids := find("forged"); len(ids) != 0 {
+			t.Fatal(ids)
+		}
+		item, err := h.Get(ctx, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lc, found := item.Payload["name_lc"]; found == hide || !hide && lc != "alice" {
+			t.Fatal(item.Payload)
+		}
+		items, err := h.MultiGet(ctx, []interface{}{"b", "d"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, found := items[0].Payload["name_lc"]; found == hide {
+			t.Fatal(items[0].Payload)
+		}
+		// Non string values have no shadow field
+		if _, found := items[1].Payload["name_lc"]; found {
+			t.Fatal(items[1].Payload)
+		}
+		// Kept in sync by the updates
+		if err := h.Update(ctx, mkitem("a", map[string]interface{}{"name": "Carol"}), item); err != nil {
+			t.Fatal(err)
+		}
+		if ids := find("alice"); len(ids) != 1 || ids[0] != "b" {
+			t.Fatal(ids)
+		}
+		if ids := find("carol"); len(ids) != 1 || ids[0] != "a" {
+			t.Fatal(ids)
+		}
+		h.Close()
+	}
+}
@@ -0,0 +1,74 @@
+package filestore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestNullOrdering(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name       string
+		values     map[string]interface{}
+		sort       string
+		nullsFirst bool
+		want       []string
+	}{
+		{"string asc", map[string]interface{}{"a": "x", "b": nil, "c": "a", "d": "m"}, "v", false, []string{"c", "d", "a", "b"}},
+		{"string desc", map[string]interface{}{"a": "x", "b": nil, "c": "a", "d": "m"}, "-v", false, []string{"a", "d", "c", "b"}},
+		{"string desc nulls first", map[string]interface{}{"a": "x", "b": nil, "c": "a", "d": "m"}, "-v", true, []string{"b", "a", "d", "c"}},
+		{"number asc mixed types", map[string]interface{}{"a": 2.5, "b": nil, "c": 1, "d": int64(3)}, "v", false, []string{"c", "a", "d", "b"}},
+		{"number desc", map[string]interface{}{"a": 2.5, "b": nil, "c": 1, "d": int64(3)}, "-v", false, []string{"d", "a", "c", "b"}},
+		{"number asc nulls first", map[string]interface{}{"a": 2.5, "b": nil, "c": 1, "d": int64(3)}, "v", true, []string{"b", "c", "a", "d"}},
+		{"time desc", map[string]interface{}{"a": t0, "b": nil, "c": t0.Add(time.Hour)}, "-v", false, []string{"c", "a", "b"}},
+		{"time asc", map[string]interface{}{"a": t0, "b": nil, "c": t0.Add(time.Hour)}, "v", false, []string{"a", "c", "b"}},
+		{"bool desc", map[string]interface{}{"a": false, "b": nil, "c": true}, "-v", false, []string{"c", "a", "b"}},
+		{"bool asc nulls first", map[string]interface{}{"a": false, "b": nil, "c": true}, "v", true, []string{"b", "a", "c"}},
+		{"types", map[string]interface{}{"a": "s", "b": 1, "c": true, "d": nil}, "v", false, []string{"c", "b", "a", "d"}},
+		{"types desc", map[string]interface{}{"a": "s", "b": 1, "c": true, "d": nil}, "-v", false, []string{"a", "b", "c", "d"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			opts := []Option{}
+			if c.nullsFirst {
+				opts = append(opts, WithNullsFirst())
+			}
+			h, err := NewHandlerWithOptions(tmpdir(t), "c", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+			ctx := context.Background()
+			// A missing field and a nil one are both null
+			for _, id := range []string{"d", "c", "b", "a"} {
+				v, found := c.values[id]
+				if !found {
+					continue
+				}
+				p := map[string]interface{}{}
+				if v != nil || c.nullsFirst {
+					p["v"] = v
+				}
+				h.Insert(ctx, []*resource.Item{mkitem(id, p)})
+			}
+			l := resource.NewLookup()
+			l.SetSorts([]string{c.sort})
+			list, err := h.Find(ctx, l, 1, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range list.Items {
+				got = append(got, item.ID.(string))
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatal(got, c.want)
+			}
+		})
+	}
+}
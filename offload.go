@@ -0,0 +1,62 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"path/filepath"
+)
+
+// With OffloadRecords set, only the ids and the indexes stay in memory: the
+// record of an item is dropped from the items map, leaving a nil record, once
+// its item file is written, and read back from the file whenever it's needed.
+// The decoded items aren't cached either. Every read of an item then costs a
+// file read and a decode, and a scan reads the file of every item it visits,
+// but the memory used no longer grows with the size of the items: only the
+// records changed since the last save are held, until the save writes them.
+// It requires PerItemFiles, the records of a datafile move whenever it's
+// rewritten.
+//
+// The code needing the bytes of a record calls record rather than reading
+// the items map, the nil records can only be copied and restored as long as
+// nothing is saved in between, like Begin does.
+
+// offloading tells if the records are dropped from memory once saved
+func (self *FileStoreHandler) offloading() bool {
+	return self.OffloadRecords && self.PerItemFiles && !self.inMemory()
+}
+
+// record returns the record of id, reading it from its item file if it was
+// offloaded
+func (self *FileStoreHandler) record(id interface{}) ([]byte, bool, error) {
+	data, found := self.items[id]
+	if !found || data != nil {
+		return data, found, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(self.database_file, itemFileName(id)))
+	if err != nil {
+		return nil, true, err
+	}
+	data, err = self.decrypt(data)
+	return data, true, err
+}
+
+// eventRecord returns the record of id for the event of its change. An
+// offloaded record is only read if there are subscribers, and is nil if it
+// can't be.
+func (self *FileStoreHandler) eventRecord(id interface{}) ([]byte, bool) {
+	data, found := self.items[id]
+	if found && data == nil && len(self.subscribers) > 0 {
+		data, _, _ = self.record(id)
+	}
+	return data, found
+}
+
+// offload drops the record of id from memory once its item file is written
+func (self *FileStoreHandler) offload(id interface{}) {
+	if !self.offloading() {
+		return
+	}
+	if data := self.items[id]; data != nil {
+		self.memoryBytes -= recordSize(data) - recordSize(nil)
+		self.items[id] = nil
+	}
+}
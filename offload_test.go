@@ -0,0 +1,112 @@
+package filestore
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestOffloadRecords(t *testing.T) {
+	if _, err := NewHandlerWithOptions(tmpdir(t), "c", WithOffloadRecords()); err == nil {
+		t.Fatal("accepted without PerItemFiles")
+	}
+	dir := tmpdir(t)
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(dir, "c", WithPerItemFiles(), WithOffloadRecords(), WithUniqueFields("name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []*resource.Item
+	for i := 0; i < 50; i++ {
+		items = append(items, mkitem(i+1, map[string]interface{}{"name": fmt.Sprintf("n%d", i), "blob": strings.Repeat("x", 1000), "k": i % 5}))
+	}
+	if err := h.Insert(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	if mb := h.MemoryBytes(); mb > 50*recordOverhead {
+		t.Fatalf("records kept in memory: %d", mb)
+	}
+	check := func(h *FileStoreHandler) {
+		got, err := h.MultiGet(ctx, []interface{}{7})
+		if err != nil || got[0] == nil || got[0].Payload["name"] != "n6" {
+			t.Fatalf("%v %v", got, err)
+		}
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.Equal{Field: "k", Value: 3}})
+		list, err := h.Find(ctx, l, 1, -1)
+		if err != nil || len(list.Items) != 10 {
+			t.Fatalf("%v %v", list, err)
+		}
+	}
+	check(h)
+	// Updates are held until saved, then offloaded again
+	o, _ := h.MultiGet(ctx, []interface{}{7})
+	n := mkitem(7, map[string]interface{}{"name": "n6", "blob": "y", "k": 3})
+	if err := h.Update(ctx, n, o[0]); err != nil {
+		t.Fatal(err)
+	}
+	if h.items[7] != nil {
+		t.Fatal("updated record kept")
+	}
+	got, _ := h.MultiGet(ctx, []interface{}{7})
+	if got[0].Payload["blob"] != "y" {
+		t.Fatal(got[0].Payload)
+	}
+	// A unique violation is still caught from the index
+	if err := h.Insert(ctx, []*resource.Item{mkitem(100, map[string]interface{}{"name": "n1"})}); err == nil {
+		t.Fatal("unique not checked")
+	}
+	if _, err := h.UpdateEach(ctx, func(item *resource.Item) (*resource.Item, bool, error) {
+		item.Payload["k"] = 9
+		return item, true, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := h.Verify(ctx); err != nil || len(p) != 0 {
+		t.Fatal(p, err)
+	}
+	h.Close()
+	h2, err := NewHandlerWithOptions(dir, "c", WithPerItemFiles(), WithOffloadRecords(), WithUniqueFields("name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h2.Close()
+	if mb := h2.MemoryBytes(); mb > 50*recordOverhead {
+		t.Fatalf("records kept in memory after load: %d", mb)
+	}
+	if n, _ := h2.Count(ctx, resource.NewLookup()); n != 50 {
+		t.Fatal(n)
+	}
+	if n, err := h2.Truncate(ctx); err != nil || n != 50 {
+		t.Fatal(n, err)
+	}
+}
+
+func benchOffload(b *testing.B, offload bool) {
+	opts := []Option{WithPerItemFiles()}
+	if offload {
+		opts = append(opts, WithOffloadRecords())
+	}
+	h, err := NewHandlerWithOptions(b.TempDir(), "c", opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer h.Close()
+	var items []*resource.Item
+	for i := 0; i < 1000; i++ {
+		items = append(items, mkitem(i+1, map[string]interface{}{"blob": strings.Repeat("x", 1000), "k": i % 10}))
+	}
+	h.Insert(context.Background(), items)
+	b.Logf("memory %d bytes", h.MemoryBytes())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.MultiGet(context.Background(), []interface{}{i%1000 + 1})
+	}
+}
+
+func BenchmarkGetInMemory(b *testing.B)  { benchOffload(b, false) }
+func BenchmarkGetOffloaded(b *testing.B) { benchOffload(b, true) }
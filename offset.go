@@ -0,0 +1,57 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// FindWithOffset is like Find with the items selected by position instead of
+// page: it skips the first offset matching items and returns up to limit of
+// the following ones, all of them if limit <= 0. An offset past the matching
+// items returns an empty list. The total of the list is the number of
+// matching items and its page the one the offset falls in.
+func (self *FileStoreHandler) FindWithOffset(ctx context.Context, lookup *resource.Lookup, offset, limit int) (list *resource.ItemList, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	list, err = self.findWindow(ctx, lookup, offsetWindow(offset, limit))
+	count(&self.counters.finds, 1)
+	if list != nil {
+		for i, item := range list.Items {
+			list.Items[i] = self.present(item)
+		}
+	}
+	return list, err
+}
+
+// offsetWindow returns the window of limit items from offset
+func offsetWindow(offset, limit int) window {
+	if offset < 0 {
+		offset = 0
+	}
+	page := 1
+	if limit > 0 {
+		page = offset/limit + 1
+	}
+	w := window{
+		page: page,
+		bounds: func(total int) (start, end int) {
+			if offset >= total {
+				return total, total
+			}
+			end = total
+			if limit > 0 && offset+limit < total {
+				end = offset + limit
+			}
+			return offset, end
+		},
+	}
+	if limit > 0 {
+		w.need = offset + limit
+	}
+	return w
+}
@@ -0,0 +1,32 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestFindWithOffset(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", []string{"u"})
+	for i := 0; i < 5; i++ {
+		if err := h.Insert(context.Background(), []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"u": i})}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct {
+		off, lim, n int
+		first       string
+	}{
+		{0, 2, 2, "0"}, {3, 0, 2, "3"}, {4, 10, 1, "4"}, {5, 1, 0, ""}, {9, -1, 0, ""}, {1, -1, 4, "1"},
+	} {
+		l, err := h.FindWithOffset(context.Background(), &resource.Lookup{}, c.off, c.lim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.Total != 5 || len(l.Items) != c.n || (c.n > 0 && l.Items[0].ID != c.first) {
+			t.Fatal(c, l.Total, len(l.Items))
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestOnOperation(t *testing.T) {
+	var got []string
+	h, _ := NewHandlerWithOptions(tmpdir(t), "c", WithOnOperation(func(ctx context.Context, op string, dur time.Duration, n int) {
+		got = append(got, fmt.Sprintf("%s:%d:%v", op, n, ctx.Value("trace")))
+	}))
+	ctx := context.WithValue(context.Background(), "trace", "t1")
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{}), mkitem("b", map[string]interface{}{})})
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})})
+	h.Find(ctx, resource.NewLookup(), 1, 1)
+	h.Clear(ctx, resource.NewLookup())
+	want := "[insert:2:t1 insert:0:t1 find:1:t1 clear:2:t1]"
+	if fmt.Sprint(got) != want {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,60 @@
+package filestore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OpenError holds the errors of the collections OpenAll failed to open, by
+// collection
+type OpenError map[string]error
+
+func (e OpenError) Error() string {
+	collections := make([]string, 0, len(e))
+	for collection := range e {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	msgs := make([]string, len(collections))
+	for i, collection := range collections {
+		msgs[i] = fmt.Sprintf("%s: %v", collection, e[collection])
+	}
+	return "filestore: can't open " + strings.Join(msgs, "; ")
+}
+
+// OpenAll opens the handlers of several collections of directory with the
+// same options, loading their datafiles concurrently to cut the startup time
+// of a server with many collections. The handlers are returned by collection.
+// If any collection fails to open, the others are closed again and an
+// OpenError listing every failure is returned.
+func OpenAll(directory string, collections []string, opts ...Option) (map[string]*FileStoreHandler, error) {
+	handlers := make(map[string]*FileStoreHandler, len(collections))
+	errs := OpenError{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, collection := range collections {
+		wg.Add(1)
+		go func(collection string) {
+			defer wg.Done()
+			h, err := NewHandlerWithOptions(directory, collection, opts...)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[collection] = err
+			} else {
+				handlers[collection] = h
+			}
+		}(collection)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		for _, h := range handlers {
+			h.Close()
+		}
+		return nil, errs
+	}
+	return handlers, nil
+}
@@ -0,0 +1,21 @@
+package filestore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpenAll(t *testing.T) {
+	d := tmpdir(t)
+	hs, err := OpenAll(d, []string{"a", "b", "c"})
+	if err != nil || len(hs) != 3 {
+		t.Fatal(hs, err)
+	}
+	for _, h := range hs {
+		h.Close()
+	}
+	_, err = OpenAll(d, []string{"a", "../x/y/..", "ok"}, WithUniqueFields(""))
+	if _, ok := err.(OpenError); !ok || !strings.Contains(err.Error(), "a:") || !strings.Contains(err.Error(), "ok:") {
+		t.Fatal(err)
+	}
+}
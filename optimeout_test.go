@@ -0,0 +1,57 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestOpTimeout(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithLatency(200*time.Millisecond), WithOpTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err = h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"id": "a"})})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("got %v", err)
+	}
+	if time.Since(start) > 150*time.Millisecond {
+		t.Fatal("timeout not honored")
+	}
+	h.OpTimeout = 0
+	h.Latency = 0
+	if err := h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"id": "a"})}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpTimeoutWaitingForLock(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithOpTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	// Expires while another operation holds the lock
+	h.Lock()
+	errs := make(chan error, 2)
+	go func() { errs <- h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})}) }()
+	go func() {
+		_, err := h.Find(ctx, resource.NewLookup(), 1, -1)
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	h.Unlock()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != context.DeadlineExceeded {
+			t.Fatal(err)
+		}
+	}
+	if h.Len() != 0 {
+		t.Fatal(h.IDs())
+	}
+}
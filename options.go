@@ -0,0 +1,464 @@
+package filestore
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// Option configures a handler created by NewHandlerWithOptions
+type Option func(*FileStoreHandler)
+
+// NewHandlerWithOptions creates a handler storing the collection in a datafile
+// of directory like NewHandler. The options are applied before the datafile is
+// loaded, so the settings affecting the loading, like the encryption key or
+// the persisted indexes, are taken into account.
+func NewHandlerWithOptions(directory string, collection string, opts ...Option) (*FileStoreHandler, error) {
+	f := newHandler(directory, collection)
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err := f.validateUniqueFields(); err != nil {
+		return nil, err
+	}
+	if f.encryptionKey != nil && len(f.encryptionKey) != 32 {
+		return nil, fmt.Errorf("filestore: encryption key must be 32 bytes long, got %d", len(f.encryptionKey))
+	}
+	if f.OffloadRecords && !f.PerItemFiles {
+		return nil, fmt.Errorf("filestore: OffloadRecords requires PerItemFiles")
+	}
+	if f.SkipInitialLoad && f.PerItemFiles {
+		// The files of the existing items would be left behind
+		return nil, fmt.Errorf("filestore: SkipInitialLoad can't be used with PerItemFiles")
+	}
+	if f.ReadOnly {
+		// Nothing is ever written, the directory may not be writable
+		if err := f.open(); err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	if err := f.fileSystem().MkdirAll(directory, f.dirMode()); err != nil {
+		return nil, err
+	}
+	if f.shared || f.FileSystem != nil {
+		// The lock file is only taken on the OS filesystem
+		if err := f.open(); err != nil {
+			return nil, err
+		}
+	} else if err := f.openLocked(); err != nil {
+		return nil, err
+	}
+	if f.TTL > 0 {
+		f.startSweeper()
+	}
+	return f, nil
+}
+
+// WithUniqueFields sets the UniqueFields
+func WithUniqueFields(fields ...string) Option {
+	return func(f *FileStoreHandler) {
+		f.UniqueFields = fields
+	}
+}
+
+// WithCaseInsensitiveUniqueFields sets the CaseInsensitiveUniqueFields
+func WithCaseInsensitiveUniqueFields(fields ...string) Option {
+	return func(f *FileStoreHandler) {
+		f.CaseInsensitiveUniqueFields = fields
+	}
+}
+
+// WithIndexedFields sets the IndexedFields
+func WithIndexedFields(fields ...string) Option {
+	return func(f *FileStoreHandler) {
+		f.IndexedFields = fields
+	}
+}
+
+// WithSortedIndexes sets the SortedIndexes
+func WithSortedIndexes(fields ...string) Option {
+	return func(f *FileStoreHandler) {
+		f.SortedIndexes = fields
+	}
+}
+
+// WithUniqueCompositeFields sets the UniqueCompositeFields
+func WithUniqueCompositeFields(groups ...[]string) Option {
+	return func(f *FileStoreHandler) {
+		f.UniqueCompositeFields = groups
+	}
+}
+
+// WithLatency sets the Latency
+func WithLatency(latency time.Duration) Option {
+	return func(f *FileStoreHandler) {
+		f.Latency = latency
+	}
+}
+
+// WithPerItemLatency sets the PerItemLatency
+func WithPerItemLatency(latency time.Duration) Option {
+	return func(f *FileStoreHandler) {
+		f.PerItemLatency = latency
+	}
+}
+
+// WithCodec sets the Codec
+func WithCodec(c Codec) Option {
+	return func(f *FileStoreHandler) {
+		f.Codec = c
+	}
+}
+
+// WithCompressor sets the Compressor
+func WithCompressor(c Compressor) Option {
+	return func(f *FileStoreHandler) {
+		f.Compressor = c
+	}
+}
+
+// WithCompression enables the gzip compression of the datafile
+func WithCompression() Option {
+	return func(f *FileStoreHandler) {
+		f.Compress = true
+	}
+}
+
+// WithEncryptionKey encrypts the datafile with key, see NewEncryptedHandler
+func WithEncryptionKey(key []byte) Option {
+	return func(f *FileStoreHandler) {
+		f.encryptionKey = append([]byte(nil), key...)
+	}
+}
+
+// WithShared opens a datafile shared with other handlers, see
+// NewSharedHandler
+func WithShared() Option {
+	return func(f *FileStoreHandler) {
+		f.shared = true
+		f.MergeOnSave = true
+	}
+}
+
+// WithTTL sets the TTL, the expired items are removed from the start
+func WithTTL(ttl time.Duration) Option {
+	return func(f *FileStoreHandler) {
+		f.TTL = ttl
+	}
+}
+
+// WithSoftDelete sets SoftDelete
+func WithSoftDelete() Option {
+	return func(f *FileStoreHandler) {
+		f.SoftDelete = true
+	}
+}
+
+// WithFlushInterval sets the FlushInterval
+func WithFlushInterval(interval time.Duration) Option {
+	return func(f *FileStoreHandler) {
+		f.FlushInterval = interval
+	}
+}
+
+// WithPersistIndexes sets PersistIndexes, the indexes are then loaded from
+// their sidecar file when it is up to date
+func WithPersistIndexes() Option {
+	return func(f *FileStoreHandler) {
+		f.PersistIndexes = true
+	}
+}
+
+// WithRecoverFromCorruption sets RecoverFromCorruption
+func WithRecoverFromCorruption() Option {
+	return func(f *FileStoreHandler) {
+		f.RecoverFromCorruption = true
+	}
+}
+
+// WithLogger sets the Logger
+func WithLogger(l Logger) Option {
+	return func(f *FileStoreHandler) {
+		f.Logger = l
+	}
+}
+
+// WithDebugLog sets the Logger and DebugLog
+func WithDebugLog(l Logger) Option {
+	return func(f *FileStoreHandler) {
+		f.Logger = l
+		f.DebugLog = true
+	}
+}
+
+// WithPerItemFiles sets PerItemFiles
+func WithPerItemFiles() Option {
+	return func(f *FileStoreHandler) {
+		f.PerItemFiles = true
+	}
+}
+
+// WithReadOnly sets ReadOnly
+func WithReadOnly() Option {
+	return func(f *FileStoreHandler) {
+		f.ReadOnly = true
+	}
+}
+
+// WithClock sets the Clock
+func WithClock(c Clock) Option {
+	return func(f *FileStoreHandler) {
+		f.Clock = c
+	}
+}
+
+// WithWAL sets WAL
+func WithWAL() Option {
+	return func(f *FileStoreHandler) {
+		f.WAL = true
+	}
+}
+
+// WithMaxItems sets MaxItems and the Eviction policy
+func WithMaxItems(max int, eviction EvictionPolicy) Option {
+	return func(f *FileStoreHandler) {
+		f.MaxItems = max
+		f.Eviction = eviction
+	}
+}
+
+// WithOpTimeout sets the OpTimeout
+func WithOpTimeout(timeout time.Duration) Option {
+	return func(f *FileStoreHandler) {
+		f.OpTimeout = timeout
+	}
+}
+
+// WithIORetries sets IORetries and IORetryDelay
+func WithIORetries(retries int, delay time.Duration) Option {
+	return func(f *FileStoreHandler) {
+		f.IORetries = retries
+		f.IORetryDelay = delay
+	}
+}
+
+// WithFileSystem sets the FileSystem
+func WithFileSystem(fs FileSystem) Option {
+	return func(f *FileStoreHandler) {
+		f.FileSystem = fs
+	}
+}
+
+// WithValidatePayload sets ValidatePayload
+func WithValidatePayload() Option {
+	return func(f *FileStoreHandler) {
+		f.ValidatePayload = true
+	}
+}
+
+// WithTimestamps sets Timestamps
+func WithTimestamps() Option {
+	return func(f *FileStoreHandler) {
+		f.Timestamps = true
+	}
+}
+
+// WithDurable sets Durable
+func WithDurable(durable bool) Option {
+	return func(f *FileStoreHandler) {
+		f.Durable = durable
+	}
+}
+
+// WithFileMode sets FileMode and DirMode
+func WithFileMode(file, dir os.FileMode) Option {
+	return func(f *FileStoreHandler) {
+		f.FileMode = file
+		f.DirMode = dir
+	}
+}
+
+// WithOnOperation sets OnOperation
+func WithOnOperation(fn func(ctx context.Context, op string, dur time.Duration, n int)) Option {
+	return func(f *FileStoreHandler) {
+		f.OnOperation = fn
+	}
+}
+
+// WithBackups sets Backups
+func WithBackups(n int) Option {
+	return func(h *FileStoreHandler) {
+		h.Backups = n
+	}
+}
+
+// WithCoalesceWrites sets CoalesceWrites
+func WithCoalesceWrites() Option {
+	return func(h *FileStoreHandler) {
+		h.CoalesceWrites = true
+	}
+}
+
+// WithRegisteredTypes registers the concrete types of values with
+// RegisterType before the datafile is loaded
+func WithRegisteredTypes(values ...interface{}) Option {
+	return func(h *FileStoreHandler) {
+		for _, value := range values {
+			RegisterType(value)
+		}
+	}
+}
+
+// WithMaxResultItems sets MaxResultItems
+func WithMaxResultItems(n int) Option {
+	return func(h *FileStoreHandler) {
+		h.MaxResultItems = n
+	}
+}
+
+// WithBlobThreshold sets BlobThreshold
+func WithBlobThreshold(size int) Option {
+	return func(h *FileStoreHandler) {
+		h.BlobThreshold = size
+	}
+}
+
+// WithGenerateETags sets GenerateETags
+func WithGenerateETags() Option {
+	return func(f *FileStoreHandler) {
+		f.GenerateETags = true
+	}
+}
+
+// WithNullsFirst sets NullsFirst
+func WithNullsFirst() Option {
+	return func(f *FileStoreHandler) {
+		f.NullsFirst = true
+	}
+}
+
+// WithMatchFunc sets MatchFunc
+func WithMatchFunc(fn func(lookup *resource.Lookup, payload map[string]interface{}) bool) Option {
+	return func(f *FileStoreHandler) {
+		f.MatchFunc = fn
+	}
+}
+
+// WithDerivedField adds a field computed by derive to DerivedFields
+func WithDerivedField(field string, derive func(payload map[string]interface{}) interface{}) Option {
+	return func(f *FileStoreHandler) {
+		if f.DerivedFields == nil {
+			f.DerivedFields = map[string]func(payload map[string]interface{}) interface{}{}
+		}
+		f.DerivedFields[field] = derive
+	}
+}
+
+// WithSyncBatchWindow sets SyncBatchWindow
+func WithSyncBatchWindow(window time.Duration) Option {
+	return func(h *FileStoreHandler) {
+		h.SyncBatchWindow = window
+	}
+}
+
+// WithReadOnlyResults sets ReadOnlyResults
+func WithReadOnlyResults() Option {
+	return func(h *FileStoreHandler) {
+		h.ReadOnlyResults = true
+	}
+}
+
+// WithSkipFindTotals sets SkipFindTotals
+func WithSkipFindTotals() Option {
+	return func(h *FileStoreHandler) {
+		h.SkipFindTotals = true
+	}
+}
+
+// WithOffloadRecords sets OffloadRecords
+func WithOffloadRecords() Option {
+	return func(h *FileStoreHandler) {
+		h.OffloadRecords = true
+	}
+}
+
+// WithRestartWorkers sets RestartWorkers
+func WithRestartWorkers() Option {
+	return func(h *FileStoreHandler) {
+		h.RestartWorkers = true
+	}
+}
+
+// WithSkipInitialLoad sets SkipInitialLoad
+func WithSkipInitialLoad() Option {
+	return func(h *FileStoreHandler) {
+		h.SkipInitialLoad = true
+	}
+}
+
+// WithSkipCorrupt sets SkipCorrupt
+func WithSkipCorrupt() Option {
+	return func(h *FileStoreHandler) {
+		h.SkipCorrupt = true
+	}
+}
+
+// WithIDField sets IDField
+func WithIDField(field string) Option {
+	return func(h *FileStoreHandler) {
+		h.IDField = field
+	}
+}
+
+// WithOrderedWrites sets OrderedWrites
+func WithOrderedWrites() Option {
+	return func(f *FileStoreHandler) {
+		f.OrderedWrites = true
+	}
+}
+
+// WithStrictQueries sets StrictQueries
+func WithStrictQueries() Option {
+	return func(f *FileStoreHandler) {
+		f.StrictQueries = true
+	}
+}
+
+// WithIDGenerator sets the IDGenerator
+func WithIDGenerator(gen func() (interface{}, error)) Option {
+	return func(f *FileStoreHandler) {
+		f.IDGenerator = gen
+	}
+}
+
+// WithAutoIncrement sets AutoIncrement
+func WithAutoIncrement() Option {
+	return func(f *FileStoreHandler) {
+		f.AutoIncrement = true
+	}
+}
+
+// WithMaxMemoryBytes sets MaxMemoryBytes
+func WithMaxMemoryBytes(max int64) Option {
+	return func(f *FileStoreHandler) {
+		f.MaxMemoryBytes = max
+	}
+}
+
+// WithNormalizedFields sets the NormalizedFields
+func WithNormalizedFields(fields ...string) Option {
+	return func(f *FileStoreHandler) {
+		f.NormalizedFields = fields
+	}
+}
+
+// WithChangeLogSize sets the ChangeLogSize
+func WithChangeLogSize(size int) Option {
+	return func(f *FileStoreHandler) {
+		f.ChangeLogSize = size
+	}
+}
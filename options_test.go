@@ -0,0 +1,25 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestOptions(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithUniqueFields("name"), WithCodec(JSONCodec{}), WithTTL(time.Hour), WithPersistIndexes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.sweeping || h.UniqueFields[0] != "name" {
+		t.Fatal(h)
+	}
+	h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"name": "x"})})
+	h.Close()
+	if _, err := NewHandlerWithOptions(d, "c", WithEncryptionKey([]byte("short"))); err == nil {
+		t.Fatal("expected error")
+	}
+}
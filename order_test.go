@@ -0,0 +1,60 @@
+package filestore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestOrdered(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	ctx := context.Background()
+	want := []interface{}{}
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("i%d", 20-i)
+		want = append(want, id)
+		if err := h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{})}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h2 := newH(t, d, "c", nil)
+	if !reflect.DeepEqual(h2.ids, want) {
+		t.Fatal(h2.ids)
+	}
+}
+
+func TestOrderedWritesItemFiles(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithPerItemFiles(), WithOrderedWrites())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{"f", "b", "e", "a", "d", "c"}
+	// The file of the 4th item can't be replaced, the save stops there
+	blocker := filepath.Join(d, "c", itemFileName(ids[3]))
+	if err := os.MkdirAll(filepath.Join(blocker, "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var items []*resource.Item
+	for _, id := range ids {
+		items = append(items, mkitem(id, map[string]interface{}{}))
+	}
+	if err := h.Insert(context.Background(), items); err == nil {
+		t.Fatal("no error")
+	}
+	for i, id := range ids {
+		if i == 3 {
+			continue
+		}
+		_, err := os.Stat(filepath.Join(d, "c", itemFileName(id)))
+		if written := err == nil; written != (i < 3) {
+			t.Errorf("%s: written %v", id, written)
+		}
+	}
+}
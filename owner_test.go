@@ -0,0 +1,27 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestReturnedItemsAreOwned(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", nil)
+	ctx := context.Background()
+	in := mkitem("a", map[string]interface{}{"x": 1, "n": map[string]interface{}{"y": 2}})
+	if err := h.Insert(ctx, []*resource.Item{in}); err != nil {
+		t.Fatal(err)
+	}
+	in.Payload["x"] = 99
+	l, _ := h.Find(ctx, &resource.Lookup{}, 1, 10)
+	l.Items[0].Payload["x"] = 42
+	l.Items[0].Payload["n"].(map[string]interface{})["y"] = 42
+	m, _ := h.MultiGet(ctx, []interface{}{"a"})
+	m[0].Payload["x"] = 43
+	l, _ = h.Find(ctx, &resource.Lookup{}, 1, 10)
+	if l.Items[0].Payload["x"] != 1 || l.Items[0].Payload["n"].(map[string]interface{})["y"] != 2 {
+		t.Fatal(l.Items[0].Payload)
+	}
+}
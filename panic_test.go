@@ -0,0 +1,90 @@
+package filestore
+
+import (
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type panicFS struct {
+	OSFileSystem
+	armed int32
+}
+
+func (f *panicFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if atomic.CompareAndSwapInt32(&f.armed, 1, 0) {
+		panic("boom")
+	}
+	return f.OSFileSystem.WriteFile(name, data, perm)
+}
+
+func TestFlusherPanicDegrades(t *testing.T) {
+	dir := tmpdir(t)
+	fs := &panicFS{}
+	h, err := NewHandlerWithOptions(dir, "c", WithFileSystem(fs), WithFlushInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&fs.armed, 1)
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{"a": 1})}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !h.Stats().Degraded {
+		if time.Now().After(deadline) {
+			t.Fatal("not degraded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := h.Stats(); s.WorkerPanics != 1 {
+		t.Fatalf("panics %d", s.WorkerPanics)
+	}
+	// Saved synchronously now
+	if err := h.Insert(ctx, []*resource.Item{mkitem(2, map[string]interface{}{"a": 2})}); err != nil {
+		t.Fatal(err)
+	}
+	if h.dirty {
+		t.Fatal("not saved")
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFlusherPanicRestarts(t *testing.T) {
+	old := workerRestartDelay
+	workerRestartDelay = time.Millisecond
+	defer func() { workerRestartDelay = old }()
+	dir := tmpdir(t)
+	fs := &panicFS{}
+	h, err := NewHandlerWithOptions(dir, "c", WithFileSystem(fs), WithFlushInterval(5*time.Millisecond), WithRestartWorkers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&fs.armed, 1)
+	if err := h.Insert(context.Background(), []*resource.Item{mkitem(1, map[string]interface{}{"a": 1})}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.RLock()
+		dirty := h.dirty
+		h.RUnlock()
+		if !dirty {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("never flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := h.Stats(); s.Degraded || s.WorkerPanics != 1 {
+		t.Fatalf("%+v", s)
+	}
+	h.Close()
+}
@@ -0,0 +1,87 @@
+package filestore
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// DefaultParallelScanThreshold is the number of items from which scans are
+// parallelized when ParallelScanThreshold isn't set
+const DefaultParallelScanThreshold = 10000
+
+// filter returns, in order, the items of ids found by get and matching the
+// lookup. Above the parallel scan threshold, ids are split in as many chunks
+// as there are CPUs and decoded and matched concurrently.
+func (self *FileStoreHandler) filter(ctx context.Context, lookup *resource.Lookup, ids []interface{}, get func(id interface{}) (*resource.Item, bool, error)) ([]*resource.Item, error) {
+	threshold := self.ParallelScanThreshold
+	if threshold == 0 {
+		threshold = DefaultParallelScanThreshold
+	}
+	workers := runtime.NumCPU()
+	if threshold < 0 || len(ids) < threshold || workers < 2 {
+		return self.filterChunk(ctx, lookup, ids, get, 0)
+	}
+
+	size := (len(ids) + workers - 1) / workers
+	results := make([][]*resource.Item, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start, end := w*size, (w+1)*size
+		if start >= len(ids) {
+			break
+		}
+		if end > len(ids) {
+			end = len(ids)
+		}
+		wg.Add(1)
+		go func(w int, chunk []interface{}) {
+			defer wg.Done()
+			results[w], errs[w] = self.filterChunk(ctx, lookup, chunk, get, 0)
+		}(w, ids[start:end])
+	}
+	wg.Wait()
+
+	total := 0
+	for w := range results {
+		if errs[w] != nil {
+			return nil, errs[w]
+		}
+		total += len(results[w])
+	}
+	items := make([]*resource.Item, 0, total)
+	for _, chunk := range results {
+		items = append(items, chunk...)
+	}
+	return items, nil
+}
+
+// filterChunk returns, in order, the items of ids found by get and matching the
+// lookup, stopping at the first limit ones if limit > 0
+func (self *FileStoreHandler) filterChunk(ctx context.Context, lookup *resource.Lookup, ids []interface{}, get func(id interface{}) (*resource.Item, bool, error), limit int) ([]*resource.Item, error) {
+	items := []*resource.Item{}
+	for i, id := range ids {
+		if limit > 0 && len(items) == limit {
+			break
+		}
+		if err := checkCanceled(ctx, i); err != nil {
+			return nil, err
+		}
+		item, found, err := get(id)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// Not visible
+			continue
+		}
+		if !self.match(lookup, item.Payload) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
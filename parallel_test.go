@@ -0,0 +1,56 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestParallelScan(t *testing.T) {
+	h := NewMemoryHandler(0)
+	h.ParallelScanThreshold = 10
+	ctx := context.Background()
+	items := []*resource.Item{}
+	for i := 0; i < 1000; i++ {
+		items = append(items, mkitem(fmt.Sprint(i), map[string]interface{}{"n": i % 3}))
+	}
+	h.Insert(ctx, items)
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "n", Value: 1}})
+	r, err := h.Find(ctx, l, 1, -1)
+	if err != nil || r.Total != 333 {
+		t.Fatal(err, r.Total)
+	}
+	for i, it := range r.Items {
+		if it.ID != fmt.Sprint(i*3+1) {
+			t.Fatal(i, it.ID)
+		}
+	}
+}
+
+func benchScan(b *testing.B, threshold int) {
+	ctx := context.Background()
+	h := NewMemoryHandler(0)
+	h.ParallelScanThreshold = threshold
+	items := make([]*resource.Item, 100000)
+	for i := range items {
+		items[i] = mkitem(fmt.Sprint(i), map[string]interface{}{"n": i % 1000})
+	}
+	if err := h.Insert(ctx, items); err != nil {
+		b.Fatal(err)
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "n", Value: 7}})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if list, err := h.Find(ctx, l, 1, -1); err != nil || list.Total != 100 {
+			b.Fatal(list, err)
+		}
+	}
+}
+
+func BenchmarkScanSerial(b *testing.B)   { benchScan(b, -1) }
+func BenchmarkScanParallel(b *testing.B) { benchScan(b, 1) }
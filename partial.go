@@ -0,0 +1,67 @@
+package filestore
+
+import (
+	"reflect"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// Merge is a partial update: it sets the top level fields of changes in the
+// payload of the item with the given id and stores the result with a new
+// ETag, leaving the other fields untouched. If expectedETag is not empty, it
+// must match the stored item's ETag or resource.ErrConflict is returned. The
+// unique fields are checked again and the id of the item can't be changed.
+func (self *FileStoreHandler) Merge(ctx context.Context, id interface{}, changes map[string]interface{}, expectedETag string) (item *resource.Item, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		if err := checkID(id); err != nil {
+			return err
+		}
+		o, found, err := self.fetch(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return resource.ErrNotFound
+		}
+		if expectedETag != "" && expectedETag != o.ETag {
+			return resource.ErrConflict
+		}
+		if value, found := changes[self.idField()]; found && !reflect.DeepEqual(value, o.Payload[self.idField()]) {
+			return &rest.Error{Code: 422, Message: "Invalid changes: the id can't be changed"}
+		}
+		payload := o.Payload
+		for field, value := range changes {
+			payload[field] = copyValue(value)
+		}
+		etag, err := ETag(payload)
+		if err != nil {
+			return err
+		}
+		item = &resource.Item{ID: o.ID, ETag: etag, Updated: self.now(), Payload: payload}
+		invalid, err := self.checkUnique(ctx, item)
+		if err != nil {
+			return err
+		}
+		if invalid != nil {
+			return invalid
+		}
+		if err := self.store(item); err != nil {
+			return err
+		}
+		return self.persistData()
+	})
+	if err != nil {
+		return nil, err
+	}
+	count(&self.counters.updates, 1)
+	return self.present(item), nil
+}
@@ -0,0 +1,37 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestMerge(t *testing.T) {
+	h := NewMemoryHandler(0)
+	h.UniqueFields = []string{"email"}
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"email": "a", "n": 1}), mkitem("b", map[string]interface{}{"email": "b"})}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Merge(ctx, "x", map[string]interface{}{"n": 2}, ""); err != resource.ErrNotFound {
+		t.Fatal(err)
+	}
+	if _, err := h.Merge(ctx, "a", map[string]interface{}{"n": 2}, "bad"); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+	if _, err := h.Merge(ctx, "a", map[string]interface{}{"email": "b"}, ""); err == nil {
+		t.Fatal("unique")
+	}
+	if _, err := h.Merge(ctx, "a", map[string]interface{}{"id": "z"}, ""); err == nil {
+		t.Fatal("id")
+	}
+	item, err := h.Merge(ctx, "a", map[string]interface{}{"n": 2}, "")
+	if err != nil || item.Payload["n"] != 2 || item.Payload["email"] != "a" {
+		t.Fatal(item, err)
+	}
+	got, _ := h.Get(ctx, "a")
+	if got.ETag != item.ETag || got.Payload["n"] != 2 {
+		t.Fatal(got)
+	}
+}
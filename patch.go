@@ -0,0 +1,375 @@
+package filestore
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// PatchOp is a RFC 6902 JSON Patch operation. Supported operations are add,
+// remove, replace, move, copy and test. Path and From are JSON Pointers
+// (RFC 6901) into the item's payload. Value is always encoded, a null value
+// being a valid one to add, replace or test.
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// Patch applies a JSON Patch to the payload of the item with the given id and
+// stores the result with a new ETag. If expectedETag is not empty, it must
+// match the stored item's ETag or resource.ErrConflict is returned. The
+// operations are applied in order and the item is left untouched if any of
+// them fails. The id of the item can't be patched.
+func (self *FileStoreHandler) Patch(ctx context.Context, id interface{}, patch []PatchOp, expectedETag string) (item *resource.Item, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		if err := checkID(id); err != nil {
+			return err
+		}
+		o, found, err := self.fetch(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return resource.ErrNotFound
+		}
+		if expectedETag != "" && expectedETag != o.ETag {
+			return resource.ErrConflict
+		}
+		originalID := o.Payload[self.idField()]
+		var doc interface{} = o.Payload
+		for _, op := range patch {
+			if doc, err = applyPatchOp(doc, op); err != nil {
+				return err
+			}
+		}
+		payload := doc.(map[string]interface{})
+		if !reflect.DeepEqual(payload[self.idField()], originalID) {
+			return &rest.Error{Code: 422, Message: "Invalid patch: the id can't be changed"}
+		}
+		etag, err := ETag(payload)
+		if err != nil {
+			return err
+		}
+		item = &resource.Item{ID: o.ID, ETag: etag, Updated: self.now(), Payload: payload}
+		invalid, err := self.checkUnique(ctx, item)
+		if err != nil {
+			return err
+		}
+		if invalid != nil {
+			return invalid
+		}
+		if err := self.store(item); err != nil {
+			return err
+		}
+		return self.persistData()
+	})
+	if err != nil {
+		return nil, err
+	}
+	count(&self.counters.updates, 1)
+	return self.present(item), nil
+}
+
+func patchError(op PatchOp, msg string) error {
+	return &rest.Error{Code: 422, Message: fmt.Sprintf("Invalid patch operation %s on '%s': %s", op.Op, op.Path, msg)}
+}
+
+// applyPatchOp applies a single operation on doc and returns the new document
+func applyPatchOp(doc interface{}, op PatchOp) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, patchError(op, err.Error())
+	}
+	if len(path) == 0 {
+		return nil, patchError(op, "the whole payload can't be replaced")
+	}
+	switch op.Op {
+	case "add":
+		doc, err = pointerAdd(doc, path, copyValue(op.Value))
+	case "remove":
+		doc, _, err = pointerRemove(doc, path)
+	case "replace":
+		if doc, _, err = pointerRemove(doc, path); err == nil {
+			doc, err = pointerAdd(doc, path, copyValue(op.Value))
+		}
+	case "move", "copy":
+		from, e := parsePointer(op.From)
+		if e != nil {
+			return nil, patchError(op, e.Error())
+		}
+		if op.Op == "move" && isPointerPrefix(from, path) {
+			if len(from) < len(path) {
+				return nil, patchError(op, "can't move a value into itself")
+			}
+			// Moving a value to its own location leaves the document as is
+			_, err = pointerGet(doc, from)
+			break
+		}
+		var value interface{}
+		if op.Op == "move" {
+			doc, value, err = pointerRemove(doc, from)
+		} else if value, err = pointerGet(doc, from); err == nil {
+			value = copyValue(value)
+		}
+		if err == nil {
+			doc, err = pointerAdd(doc, path, value)
+		}
+	case "test":
+		var value interface{}
+		if value, err = pointerGet(doc, path); err == nil && !patchEqual(value, op.Value) {
+			err = fmt.Errorf("value differs")
+		}
+	default:
+		err = fmt.Errorf("unknown operation")
+	}
+	if err != nil {
+		if _, ok := err.(*rest.Error); !ok {
+			err = patchError(op, err.Error())
+		}
+		return nil, err
+	}
+	return doc, nil
+}
+
+// patchEqual tells if a and b are equal JSON values, comparing the numbers by
+// value as a decoded patch holds float64 where the payload may hold an int,
+// see compareNumbers
+func patchEqual(a, b interface{}) bool {
+	if _, ok := toFloat(a); ok {
+		c, ok := compareNumbers(a, b)
+		return ok && c == 0
+	}
+	switch t := a.(type) {
+	case map[string]interface{}:
+		u, ok := b.(map[string]interface{})
+		if !ok || len(t) != len(u) {
+			return false
+		}
+		for k, v := range t {
+			w, found := u[k]
+			if !found || !patchEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		u, ok := b.([]interface{})
+		if !ok || len(t) != len(u) {
+			return false
+		}
+		for i := range t {
+			if !patchEqual(t[i], u[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// parsePointer splits a JSON Pointer into its unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("pointer must start with /")
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func isPointerPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses token as an index of array, accepting len(array) if end
+// is set
+func arrayIndex(array []interface{}, token string, end bool) (int, error) {
+	if token == "-" && end {
+		return len(array), nil
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || idx > len(array) || (idx == len(array) && !end) {
+		return 0, fmt.Errorf("invalid array index %s", token)
+	}
+	return idx, nil
+}
+
+// pointerGet returns the value of doc at path
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, found := node[token]
+			if !found {
+				return nil, fmt.Errorf("field %s not found", token)
+			}
+			doc = value
+		case []interface{}:
+			idx, err := arrayIndex(node, token, false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[idx]
+		default:
+			return nil, fmt.Errorf("%s is not a container", token)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds value at path in doc and returns the new document
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if node == nil {
+			// Items stored without a payload
+			node = map[string]interface{}{}
+		}
+		if len(path) == 1 {
+			node[token] = value
+			return node, nil
+		}
+		child, found := node[token]
+		if !found {
+			return nil, fmt.Errorf("field %s not found", token)
+		}
+		child, err := pointerAdd(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []interface{}:
+		if len(path) == 1 {
+			idx, err := arrayIndex(node, token, true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[idx+1:], node[idx:])
+			node[idx] = value
+			return node, nil
+		}
+		idx, err := arrayIndex(node, token, false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := pointerAdd(node[idx], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[idx] = child
+		return node, nil
+	}
+	return nil, fmt.Errorf("%s is not a container", token)
+}
+
+// pointerRemove removes the value at path from doc and returns the new
+// document along with the removed value
+func pointerRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, found := node[token]
+		if !found {
+			return nil, nil, fmt.Errorf("field %s not found", token)
+		}
+		if len(path) == 1 {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := pointerRemove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+	case []interface{}:
+		idx, err := arrayIndex(node, token, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := node[idx]
+			return append(node[:idx], node[idx+1:]...), removed, nil
+		}
+		child, removed, err := pointerRemove(node[idx], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[idx] = child
+		return node, removed, nil
+	}
+	return nil, nil, fmt.Errorf("%s is not a container", token)
+}
+
+// copyValue deep copies the maps and slices of v, including the typed ones
+// the payloads may hold, see gobTypes
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			c[k] = copyValue(v)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(t))
+		for i, v := range t {
+			c[i] = copyValue(v)
+		}
+		return c
+	case []map[string]interface{}:
+		c := make([]map[string]interface{}, len(t))
+		for i, v := range t {
+			c[i], _ = copyValue(v).(map[string]interface{})
+		}
+		return c
+	case map[string]string:
+		c := make(map[string]string, len(t))
+		for k, v := range t {
+			c[k] = v
+		}
+		return c
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && !rv.IsNil() && isScalarKind(rv.Type().Elem().Kind()) {
+		// Typed slices like []byte or []string
+		c := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		reflect.Copy(c, rv)
+		return c.Interface()
+	}
+	return v
+}
+
+// isScalarKind tells if the values of kind hold no references
+func isScalarKind(kind reflect.Kind) bool {
+	return kind >= reflect.Bool && kind <= reflect.Complex128 || kind == reflect.String
+}
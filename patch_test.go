@@ -0,0 +1,72 @@
+package filestore
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestPatch(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", nil)
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"n": map[string]interface{}{"x": 1}, "arr": []interface{}{"a", "b"}})})
+	it, err := h.Patch(ctx, "a", []PatchOp{{Op: "add", Path: "/arr/1", Value: "z"}, {Op: "remove", Path: "/n/x"}, {Op: "move", From: "/arr/0", Path: "/first"}, {Op: "test", Path: "/first", Value: "a"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(it.Payload["arr"], []interface{}{"z", "b"}) || it.Payload["first"] != "a" {
+		t.Fatal(it.Payload)
+	}
+	if _, err := h.Patch(ctx, "a", []PatchOp{{Op: "replace", Path: "/nope", Value: 1}}, ""); err == nil {
+		t.Fatal("expected err")
+	}
+	if _, err := h.Patch(ctx, "a", []PatchOp{{Op: "replace", Path: "/id", Value: 1}}, ""); err == nil {
+		t.Fatal("expected err")
+	}
+	if _, err := h.Patch(ctx, "a", nil, "bad"); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+}
+
+func TestPatchRFC6902(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", nil)
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"n": 5, "l": []interface{}{1, "x"}}), {ID: "b"}})
+	// The numbers of a decoded patch are float64
+	var patch []PatchOp
+	if err := json.Unmarshal([]byte(`[{"op":"test","path":"/n","value":5},{"op":"test","path":"/l","value":[1,"x"]},{"op":"move","from":"/n","path":"/n"}]`), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if it, err := h.Patch(ctx, "a", patch, ""); err != nil || it.Payload["n"] != 5 {
+		t.Fatal(it, err)
+	}
+	if _, err := h.Patch(ctx, "a", []PatchOp{{Op: "test", Path: "/n", Value: "5"}}, ""); err == nil {
+		t.Fatal("expected err")
+	}
+	if _, err := h.Patch(ctx, "a", []PatchOp{{Op: "move", From: "/l", Path: "/l/0"}}, ""); err == nil {
+		t.Fatal("expected err")
+	}
+	if it, err := h.Patch(ctx, "b", []PatchOp{{Op: "add", Path: "/x", Value: 1}}, ""); err != nil || it.Payload["x"] != 1 {
+		t.Fatal(it, err)
+	}
+	// A null value is kept when encoded
+	b, err := json.Marshal(PatchOp{Op: "add", Path: "/x"})
+	if err != nil || string(b) != `{"op":"add","path":"/x","value":null}` {
+		t.Fatal(string(b), err)
+	}
+}
+
+func TestPatchTestLargeIntegers(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", nil)
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"n": int64(1<<60 + 1)})})
+	if _, err := h.Patch(ctx, "a", []PatchOp{{Op: "test", Path: "/n", Value: int64(1 << 60)}}, ""); err == nil {
+		t.Fatal("expected err")
+	}
+	if _, err := h.Patch(ctx, "a", []PatchOp{{Op: "test", Path: "/n", Value: int64(1<<60 + 1)}}, ""); err != nil {
+		t.Fatal(err)
+	}
+}
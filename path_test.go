@@ -0,0 +1,14 @@
+package filestore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPathJoin(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d+string(filepath.Separator), "c", nil)
+	if h.database_file != filepath.Join(d, "c") {
+		t.Fatal(h.database_file)
+	}
+}
@@ -0,0 +1,106 @@
+package filestore
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/rs/rest-layer/rest"
+)
+
+// DefaultMaxPayloadDepth is the nesting depth limit of payloads used when
+// MaxPayloadDepth isn't set
+const DefaultMaxPayloadDepth = 100
+
+// checkPayload makes sure a payload can be safely encoded: it must not nest
+// maps and slices deeper than the configured limit nor contain a reference
+// cycle, which would make the encoder loop or overflow the stack. With
+// ValidatePayload, its values are also checked by checkScalar.
+func (self *FileStoreHandler) checkPayload(payload map[string]interface{}) error {
+	maxDepth := self.MaxPayloadDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxPayloadDepth
+	}
+	return checkValue(payload, "", 0, maxDepth, self.ValidatePayload, map[uintptr]bool{})
+}
+
+// checkValue walks value, path being the dotted path of the value in the
+// payload and parents the addresses of the containers it is nested in. The
+// values which aren't containers are checked by checkScalar if validate is
+// set.
+func checkValue(value interface{}, path string, depth, maxDepth int, validate bool, parents map[uintptr]bool) error {
+	var children map[string]interface{}
+	switch t := value.(type) {
+	case map[string]interface{}:
+		children = t
+	case []interface{}:
+		if len(t) == 0 {
+			return nil
+		}
+		children = make(map[string]interface{}, len(t))
+		for i, child := range t {
+			children[fmt.Sprintf("%d", i)] = child
+		}
+	default:
+		if validate {
+			return checkScalar(reflect.ValueOf(value), path)
+		}
+		return nil
+	}
+	if depth >= maxDepth {
+		return &rest.Error{Code: 422, Message: fmt.Sprintf("Payload field '%s' is nested deeper than %d levels", path, maxDepth)}
+	}
+	ptr := reflect.ValueOf(value).Pointer()
+	if parents[ptr] {
+		return &rest.Error{Code: 422, Message: fmt.Sprintf("Payload field '%s' references itself", path)}
+	}
+	parents[ptr] = true
+	defer delete(parents, ptr)
+	for name, child := range children {
+		childPath := name
+		if path != "" {
+			childPath = path + "." + name
+		}
+		if err := checkValue(child, childPath, depth+1, maxDepth, validate, parents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkScalar rejects the values which are stored but break the responses or
+// the comparisons later on: NaN and infinite floats, which JSON can't
+// represent, and the complex numbers, functions and channels the codecs can't
+// encode. The elements of the slices and maps of such values are checked too.
+func checkScalar(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return &rest.Error{Code: 422, Message: fmt.Sprintf("Payload field '%s' holds %v, which can't be stored", path, f)}
+		}
+	case reflect.Complex64, reflect.Complex128, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return &rest.Error{Code: 422, Message: fmt.Sprintf("Payload field '%s' holds a %s, which can't be stored", path, v.Kind())}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// Binary data
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := checkScalar(v.Index(i), fmt.Sprintf("%s.%d", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := checkScalar(iter.Value(), fmt.Sprintf("%s.%v", path, iter.Key())); err != nil {
+				return err
+			}
+		}
+	case reflect.Interface, reflect.Ptr:
+		if !v.IsNil() {
+			return checkScalar(v.Elem(), path)
+		}
+	}
+	return nil
+}
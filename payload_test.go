@@ -0,0 +1,32 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestPayloadCycle(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", nil)
+	ctx := context.Background()
+	m := map[string]interface{}{}
+	m["self"] = m
+	if err := h.Insert(ctx, []*resource.Item{{ID: "a", Payload: m}}); err == nil {
+		t.Fatal("expected err")
+	}
+	deep := map[string]interface{}{}
+	cur := deep
+	for i := 0; i < 200; i++ {
+		n := map[string]interface{}{}
+		cur["x"] = n
+		cur = n
+	}
+	if err := h.Insert(ctx, []*resource.Item{{ID: "b", Payload: deep}}); err == nil {
+		t.Fatal("expected err")
+	}
+	shared := map[string]interface{}{"v": 1}
+	if err := h.Insert(ctx, []*resource.Item{{ID: "c", Payload: map[string]interface{}{"a": shared, "b": shared}}}); err != nil {
+		t.Fatal(err)
+	}
+}
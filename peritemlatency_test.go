@@ -0,0 +1,60 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestPerItemLatency(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	var items []*resource.Item
+	for i := 0; i < 100; i++ {
+		items = append(items, mkitem(i+1, map[string]interface{}{}))
+	}
+	if err := h.Insert(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	h.PerItemLatency = time.Millisecond
+	start := time.Now()
+	list, err := h.Find(ctx, resource.NewLookup(), 1, -1)
+	if err != nil || len(list.Items) != 100 {
+		t.Fatal(list, err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond || d > time.Second {
+		t.Fatal(d)
+	}
+	// 10 items
+	start = time.Now()
+	if _, err := h.Find(ctx, resource.NewLookup(), 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 10*time.Millisecond || d > 90*time.Millisecond {
+		t.Fatal(d)
+	}
+	cctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if list, err := h.Find(cctx, resource.NewLookup(), 1, -1); err != context.DeadlineExceeded || list != nil {
+		t.Fatal(list, err)
+	}
+	if d := time.Since(start); d > 90*time.Millisecond {
+		t.Fatal(d)
+	}
+	cctx, cancel = context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if n, err := h.Clear(cctx, resource.NewLookup()); err != context.DeadlineExceeded || n == 0 || n == 100 {
+		t.Fatal(n, err)
+	}
+	h.PerItemLatency = 0
+	if n, _ := h.Count(ctx, resource.NewLookup()); n == 0 || n == 100 {
+		t.Fatal(n)
+	}
+}
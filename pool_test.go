@@ -0,0 +1,47 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// scanHandler returns a memory handler holding n items
+func scanHandler(b *testing.B, n int) *FileStoreHandler {
+	h := NewMemoryHandler(0)
+	items := make([]*resource.Item, n)
+	for i := range items {
+		items[i] = mkitem(fmt.Sprint(i), map[string]interface{}{"name": fmt.Sprint("item ", i), "n": i})
+	}
+	if err := h.Insert(context.Background(), items); err != nil {
+		b.Fatal(err)
+	}
+	return h
+}
+
+func BenchmarkFullScanDecode(b *testing.B) {
+	ctx := context.Background()
+	h := scanHandler(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Decode every record again
+		h.cache = itemCache{}
+		if _, err := h.Find(ctx, resource.NewLookup(), 1, -1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeDatafile(b *testing.B) {
+	h := scanHandler(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := h.encodeDatafile(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// ClearPreview returns the ids of the items Clear would remove for the lookup,
+// in the handler's order, without removing them. Nothing is changed or
+// written, so a filter can be checked before running the clear. The items
+// may change in between the preview and the clear.
+func (self *FileStoreHandler) ClearPreview(ctx context.Context, lookup *resource.Lookup) (ids []interface{}, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := self.checkLookup(lookup); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		ids = []interface{}{}
+		for i, id := range self.ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			item, found, err := self.peekValid(id)
+			if err != nil {
+				return err
+			}
+			if found && self.match(lookup, item.Payload) {
+				ids = append(ids, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// DeleteManyPreview returns the ids DeleteMany would delete among ids, in
+// their order and once each, without deleting them. Like ClearPreview,
+// nothing is changed or written.
+func (self *FileStoreHandler) DeleteManyPreview(ctx context.Context, ids []interface{}) (found []interface{}, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		found = []interface{}{}
+		seen := map[interface{}]bool{}
+		for i, id := range ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			if err := checkID(id); err != nil {
+				return err
+			}
+			id = canonicalID(id)
+			if seen[id] {
+				continue
+			}
+			_, ok, err := self.peek(id)
+			if err != nil {
+				return err
+			}
+			if ok {
+				seen[id] = true
+				found = append(found, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
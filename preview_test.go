@@ -0,0 +1,47 @@
+package filestore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestClearPreview(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	var items []*resource.Item
+	for i := 0; i < 10; i++ {
+		items = append(items, mkitem(i+1, map[string]interface{}{"odd": i%2 == 0}))
+	}
+	h.Insert(ctx, items)
+	before, _ := ioutil.ReadFile(h.database_file)
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "odd", Value: true}})
+	ids, err := h.ClearPreview(ctx, l)
+	if err != nil || !reflect.DeepEqual(ids, []interface{}{1, 3, 5, 7, 9}) {
+		t.Fatal(ids, err)
+	}
+	found, err := h.DeleteManyPreview(ctx, []interface{}{2, int64(2), 42, 4})
+	if err != nil || !reflect.DeepEqual(found, []interface{}{2, 4}) {
+		t.Fatal(found, err)
+	}
+	after, _ := ioutil.ReadFile(h.database_file)
+	if !bytes.Equal(before, after) || h.Len() != 10 {
+		t.Fatal("changed")
+	}
+	if n, err := h.Clear(ctx, l); err != nil || n != len(ids) {
+		t.Fatal(n, err)
+	}
+	if n, err := h.DeleteMany(ctx, []interface{}{2, 42, 4}); err != nil || n != len(found) {
+		t.Fatal(n, err)
+	}
+}
@@ -0,0 +1,70 @@
+package filestore
+
+import (
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// FindFields is like Find but the payloads of the returned items only hold
+// the given fields, which may be dotted paths to the fields of sub documents.
+// Only these fields are copied out of the decoded items so the large fields
+// which aren't requested are never duplicated. The ETags of the items are the
+// ones of their whole payload.
+func (self *FileStoreHandler) FindFields(ctx context.Context, lookup *resource.Lookup, fields []string, page, perPage int) (list *resource.ItemList, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	w := pageWindow(page, perPage)
+	w.fields = fields
+	if w.fields == nil {
+		w.fields = []string{}
+	}
+	list, err = self.findWindow(ctx, lookup, w)
+	count(&self.counters.finds, 1)
+	if list != nil {
+		for i, item := range list.Items {
+			list.Items[i] = self.present(item)
+		}
+	}
+	return list, err
+}
+
+// projectItem returns a copy of item with only the given fields in its
+// payload
+func projectItem(item *resource.Item, fields []string) *resource.Item {
+	payload := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		projectField(payload, item.Payload, strings.Split(field, "."))
+	}
+	projected := *item
+	projected.Payload = payload
+	return &projected
+}
+
+// projectField copies the field at path of src to dst
+func projectField(dst, src map[string]interface{}, path []string) {
+	value, found := src[path[0]]
+	if !found {
+		return
+	}
+	if len(path) == 1 {
+		dst[path[0]] = copyValue(value)
+		return
+	}
+	sub, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	child, ok := dst[path[0]].(map[string]interface{})
+	if !ok {
+		child = map[string]interface{}{}
+		dst[path[0]] = child
+	}
+	projectField(child, sub, path[1:])
+}
@@ -0,0 +1,45 @@
+package filestore
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestFindFields(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"x": 1, "big": "zz", "sub": map[string]interface{}{"a": 1, "b": 2}})})
+	l, err := h.FindFields(ctx, resource.NewLookup(), []string{"id", "x", "sub.a", "nope", "x.y"}, 1, -1)
+	if err != nil || fmt.Sprint(l.Items[0].Payload) != "map[id:a sub:map[a:1] x:1]" {
+		t.Fatal(l, err)
+	}
+	l.Items[0].Payload["sub"].(map[string]interface{})["a"] = 5
+	g, _ := h.Get(ctx, "a")
+	if g.Payload["sub"].(map[string]interface{})["a"] != 1 {
+		t.Fatal(g)
+	}
+}
+
+func BenchmarkFindFields(b *testing.B) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	for i := 0; i < 1000; i++ {
+		h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"x": i, "blob": strings.Repeat("x", 1000), "tags": []interface{}{"a", "b", "c"}, "meta": map[string]interface{}{"a": 1, "b": 2}})})
+	}
+	b.Run("Find", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h.Find(ctx, resource.NewLookup(), 1, -1)
+		}
+	})
+	b.Run("FindFields", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			h.FindFields(ctx, resource.NewLookup(), []string{"x"}, 1, -1)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// PurgeWhere removes for good the items matching the lookup, including the
+// expired and soft deleted ones not removed yet, and returns how many were
+// removed. Unlike Clear, it ignores SoftDelete and ClearBatchSize: the items
+// are all removed from memory first, the collection is persisted once, and
+// the items are restored if it can't be or ctx is canceled during the scan.
+func (self *FileStoreHandler) PurgeWhere(ctx context.Context, lookup *resource.Lookup) (total int, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return 0, err
+	}
+	if err := self.checkLookup(lookup); err != nil {
+		return 0, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		undo := self.beginUndo()
+		undo.ids = self.snapshotIDs()
+		err := func() error {
+			for i, id := range undo.ids {
+				if err := checkCanceled(ctx, i); err != nil {
+					return err
+				}
+				item, found, err := self.decodeValid(id)
+				if err != nil {
+					return err
+				}
+				if !found || !self.match(lookup, item.Payload) {
+					continue
+				}
+				record, _, err := self.record(id)
+				if err != nil {
+					return err
+				}
+				undo.change(id, record)
+				self.delete(id)
+			}
+			if !undo.changed() {
+				return nil
+			}
+			self.sealUndo(undo)
+			return self.persistData()
+		}()
+		if err != nil && undo.changed() {
+			self.undo(undo)
+			return err
+		}
+		total = len(undo.old)
+		return err
+	})
+	count(&self.counters.deletes, total)
+	return total, err
+}
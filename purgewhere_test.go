@@ -0,0 +1,52 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestPurgeWhere(t *testing.T) {
+	dir := tmpdir(t)
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(dir, "c", WithSoftDelete(), WithIndexedFields("status"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []*resource.Item
+	for i := 0; i < 10; i++ {
+		status := "open"
+		if i%3 == 0 {
+			status = "cancelled"
+		}
+		items = append(items, mkitem(i+1, map[string]interface{}{"status": status}))
+	}
+	h.Insert(ctx, items)
+	// A soft deleted cancelled item is purged too
+	if err := h.Delete(ctx, &resource.Item{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "status", Value: "cancelled"}})
+	n, err := h.PurgeWhere(ctx, l)
+	if err != nil || n != 4 {
+		t.Fatal(n, err)
+	}
+	if ids := h.IDs(); len(ids) != 6 {
+		t.Fatal(ids)
+	}
+	if c, _ := h.Count(ctx, l); c != 0 {
+		t.Fatal(c)
+	}
+	h.Close()
+	h, _ = NewHandlerWithOptions(dir, "c", WithSoftDelete())
+	defer h.Close()
+	if len(h.items) != 6 {
+		t.Fatal(len(h.items))
+	}
+	if n, err := h.PurgeWhere(ctx, l); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+}
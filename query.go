@@ -0,0 +1,128 @@
+package filestore
+
+import (
+	"fmt"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"github.com/rs/rest-layer/schema"
+)
+
+// match tells if payload matches the filter of lookup, with MatchFunc if it is
+// set
+func (self *FileStoreHandler) match(lookup *resource.Lookup, payload map[string]interface{}) bool {
+	if self.MatchFunc != nil {
+		return self.MatchFunc(lookup, payload)
+	}
+	return lookup.Filter().Match(payload)
+}
+
+// checkLookup returns a descriptive error when StrictQueries is set and the
+// lookup contains a construct the handler doesn't know how to evaluate. The
+// lookups aren't checked with MatchFunc, which decides what it evaluates.
+func (self *FileStoreHandler) checkLookup(lookup *resource.Lookup) error {
+	if !self.StrictQueries || lookup == nil || self.MatchFunc != nil {
+		return nil
+	}
+	for _, exp := range lookup.Filter() {
+		if err := checkExpression(exp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFullScan returns a descriptive error when StrictQueries is set and the
+// lookup, about to be served by a scan of all the items, filters on a field
+// with an index: the index was expected to serve it, see indexCandidates and
+// rangeCandidates for the lookups it can serve.
+func (self *FileStoreHandler) checkFullScan(lookup *resource.Lookup) error {
+	if !self.StrictQueries || lookup == nil || self.MatchFunc != nil {
+		return nil
+	}
+	for _, field := range expressionsFields(lookup.Filter(), nil) {
+		if self.fieldIndex(field) != nil || self.sortedIndexes[field] != nil {
+			return &rest.Error{Code: 422, Message: fmt.Sprintf("Query on indexed field '%s' can't be served by its index and would scan all the items", field)}
+		}
+	}
+	return nil
+}
+
+// expressionsFields appends the fields of the filter expressions exps to
+// fields
+func expressionsFields(exps []schema.Expression, fields []string) []string {
+	for _, exp := range exps {
+		switch t := exp.(type) {
+		case schema.Query:
+			fields = expressionsFields(t, fields)
+		case schema.And:
+			fields = expressionsFields(t, fields)
+		case schema.Or:
+			fields = expressionsFields(t, fields)
+		default:
+			if field, _ := expressionField(exp); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// checkExpression recursively validates a filter expression
+func checkExpression(exp schema.Expression) error {
+	switch t := exp.(type) {
+	case schema.Query:
+		return checkExpressions(t)
+	case schema.And:
+		return checkExpressions(t)
+	case schema.Or:
+		return checkExpressions(t)
+	}
+	field, ok := expressionField(exp)
+	if !ok {
+		return &rest.Error{Code: 422, Message: fmt.Sprintf("Unsupported query operator %T", exp)}
+	}
+	if field == "" {
+		return &rest.Error{Code: 422, Message: fmt.Sprintf("Query operator %T has no field", exp)}
+	}
+	return nil
+}
+
+// expressionField returns the field of a comparison filter expression, ok is
+// false for an unknown operator
+func expressionField(exp schema.Expression) (field string, ok bool) {
+	switch t := exp.(type) {
+	case schema.Equal:
+		field = t.Field
+	case schema.NotEqual:
+		field = t.Field
+	case schema.In:
+		field = t.Field
+	case schema.NotIn:
+		field = t.Field
+	case schema.Exist:
+		field = t.Field
+	case schema.NotExist:
+		field = t.Field
+	case schema.GreaterThan:
+		field = t.Field
+	case schema.GreaterOrEqual:
+		field = t.Field
+	case schema.LowerThan:
+		field = t.Field
+	case schema.LowerOrEqual:
+		field = t.Field
+	default:
+		return "", false
+	}
+	return field, true
+}
+
+func checkExpressions(exps []schema.Expression) error {
+	for _, exp := range exps {
+		if err := checkExpression(exp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
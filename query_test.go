@@ -0,0 +1,84 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+type unknownExpression struct{}
+
+func (unknownExpression) Match(payload map[string]interface{}) bool { return true }
+
+func TestStrictQueries(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithIndexedFields("kind"), WithSortedIndexes("n"), WithStrictQueries())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"kind": "x", "n": 1, "name": "a"}),
+		mkitem("b", map[string]interface{}{"kind": "y", "n": 2, "name": "b"}),
+	})
+	for _, c := range []struct {
+		query schema.Query
+		sort  []string
+		ok    bool
+	}{
+		// Served by the indexes
+		{schema.Query{schema.Equal{Field: "kind", Value: "x"}}, nil, true},
+		{schema.Query{schema.In{Field: "kind", Values: []schema.Value{"x", "y"}}}, nil, true},
+		{schema.Query{schema.GreaterThan{Field: "n", Value: 1}}, nil, true},
+		// No index expected
+		{nil, nil, true},
+		{schema.Query{schema.Equal{Field: "name", Value: "a"}}, []string{"n"}, true},
+		// Scans despite the index
+		{schema.Query{schema.NotEqual{Field: "kind", Value: "x"}}, nil, false},
+		{schema.Query{schema.Equal{Field: "kind", Value: "x"}}, []string{"name"}, false},
+		{schema.Query{schema.Or{schema.Equal{Field: "name", Value: "a"}, schema.Equal{Field: "kind", Value: "y"}}}, nil, false},
+		{schema.Query{schema.Equal{Field: "n", Value: 1}}, nil, false},
+		// Unsupported
+		{schema.Query{unknownExpression{}}, nil, false},
+		{schema.Query{schema.Equal{Value: 1}}, nil, false},
+	} {
+		l := resource.NewLookup()
+		if c.query != nil {
+			l.AddQuery(c.query)
+		}
+		l.SetSorts(c.sort)
+		_, err := h.Find(ctx, l, 1, -1)
+		if (err == nil) != c.ok {
+			t.Errorf("find %v sorted by %v: %v", c.query, c.sort, err)
+		}
+		_, err = h.Count(ctx, l)
+		if (err == nil) != c.ok && len(c.sort) == 0 {
+			t.Errorf("count %v: %v", c.query, err)
+		}
+	}
+}
+
+func TestStrictQueriesUniqueComposite(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithStrictQueries(), WithIndexedFields("tenant"), WithUniqueCompositeFields([]string{"tenant", "email"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"tenant": "t", "email": "a@x"})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"tenant": "t", "email": "b@x"})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{"tenant": "t", "email": "a@x"})}); err == nil {
+		t.Fatal("expected err")
+	} else if _, ok := UniqueViolation(err); !ok {
+		t.Fatal(err)
+	}
+	if n := h.Len(); n != 2 {
+		t.Fatal(n)
+	}
+}
@@ -0,0 +1,85 @@
+package filestore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestRace(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", []string{"name"})
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				if err := h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprintf("%d-%d", g, i), map[string]interface{}{"name": fmt.Sprintf("%d-%d", g, i)})}); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				l, err := h.Find(ctx, resource.NewLookup(), 1, 5)
+				if err != nil || l.Total < len(l.Items) {
+					t.Error(err)
+				}
+				if len(l.Items) > 0 {
+					l.Items[0].Payload["name"] = "mut"
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	l, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+	for _, i := range l.Items {
+		if i.Payload["name"] == "mut" {
+			t.Fatal("mutated cache")
+		}
+	}
+}
+
+func TestRaceHammer(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", []string{"name"})
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 30; i++ {
+				id := fmt.Sprintf("%d-%d", g, i)
+				if err := h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{"name": id})}); err != nil {
+					t.Error(err)
+				}
+				if i%3 == 0 {
+					h.Merge(ctx, id, map[string]interface{}{"n": i}, "")
+				}
+				if i%5 == 0 {
+					h.DeleteMany(ctx, []interface{}{id})
+				}
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 30; i++ {
+				l := resource.NewLookup()
+				l.SetSorts([]string{"-name"})
+				if _, err := h.Find(ctx, l, 1, 5); err != nil {
+					t.Error(err)
+				}
+				h.Get(ctx, "0-1")
+				h.FindEach(ctx, resource.NewLookup(), func(*resource.Item) error { return nil })
+				h.FindDuplicates(ctx, "name")
+				h.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+}
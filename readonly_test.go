@@ -0,0 +1,93 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestReadOnlyResults(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithReadOnlyResults(), WithDerivedField("twice", func(p map[string]interface{}) interface{} {
+		n, _ := p["n"].(int)
+		return 2 * n
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"n": 1, "tags": []interface{}{"x"}}),
+		mkitem("b", map[string]interface{}{"n": 2}),
+	})
+	l1, err := h.Find(ctx, resource.NewLookup(), 1, -1)
+	if err != nil || len(l1.Items) != 2 {
+		t.Fatal(l1, err)
+	}
+	if _, found := l1.Items[0].Payload["twice"]; found {
+		t.Fatal("derived field handed out")
+	}
+	items, _ := h.MultiGet(ctx, []interface{}{"a", "zz"})
+	if items[0] == nil || items[0].Payload["n"] != 1 || items[1] != nil {
+		t.Fatal(items)
+	}
+	all, _ := h.All(ctx)
+	if len(all) != 2 || all[0].ID != "a" {
+		t.Fatal(all)
+	}
+	// Without derived fields the items are shared
+	p, err := NewHandlerWithOptions(tmpdir(t), "c", WithReadOnlyResults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"n": 1})})
+	x, _ := p.MultiGet(ctx, []interface{}{"a"})
+	y, _ := p.MultiGet(ctx, []interface{}{"a"})
+	f, _ := p.Find(ctx, resource.NewLookup(), 1, -1)
+	if x[0] != y[0] || f.Items[0] != x[0] {
+		t.Fatal("not shared")
+	}
+	// A write replaces the item, the returned one stays a snapshot
+	u := mkitem("a", map[string]interface{}{"n": 2})
+	if err := p.Update(ctx, u, x[0]); err != nil {
+		t.Fatal(err)
+	}
+	if x[0].Payload["n"] != 1 {
+		t.Fatal(x[0])
+	}
+	z, _ := p.MultiGet(ctx, []interface{}{"a"})
+	if z[0].Payload["n"] != 2 || z[0] == x[0] {
+		t.Fatal(z[0])
+	}
+}
+
+func benchReadOnly(b *testing.B, shared bool) {
+	ctx := context.Background()
+	opts := []Option{}
+	if shared {
+		opts = append(opts, WithReadOnlyResults())
+	}
+	h, err := NewHandlerWithOptions(tmpdirTB(b), "c", opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer h.Close()
+	items := make([]*resource.Item, 100)
+	for i := range items {
+		items[i] = mkitem(fmt.Sprint(i), map[string]interface{}{"n": i, "tags": []interface{}{"a", "b"}, "meta": map[string]interface{}{"k": "v"}})
+	}
+	h.Insert(ctx, items)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := h.Find(ctx, resource.NewLookup(), 1, -1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindCopies(b *testing.B)          { benchReadOnly(b, false) }
+func BenchmarkFindReadOnlyResults(b *testing.B) { benchReadOnly(b, true) }
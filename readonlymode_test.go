@@ -0,0 +1,63 @@
+package filestore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandler(d, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"n": 1})})
+	h.Close()
+	before, _ := ioutil.ReadFile(filepath.Join(d, "c"))
+	// No write permission is needed
+	os.Chmod(d, 0555)
+	defer os.Chmod(d, 0755)
+	r, err := NewHandlerWithOptions(d, "c", WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := r.Get(ctx, "a")
+	if err != nil || a.Payload["n"] != 1 {
+		t.Fatal(a, err)
+	}
+	if err := r.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{})}); err != ErrReadOnly {
+		t.Fatal(err)
+	}
+	if err := r.Update(ctx, mkitem("a", map[string]interface{}{"n": 2}), a); err != ErrReadOnly {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, a); err != ErrReadOnly {
+		t.Fatal(err)
+	}
+	if _, err := r.Clear(ctx, resource.NewLookup()); err != ErrReadOnly {
+		t.Fatal(err)
+	}
+	if list, err := r.Find(ctx, resource.NewLookup(), 1, -1); err != nil || list.Total != 1 {
+		t.Fatal(list, err)
+	}
+	if n, err := r.Count(ctx, resource.NewLookup()); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	if items, err := r.MultiGet(ctx, []interface{}{"a"}); err != nil || items[0] == nil {
+		t.Fatal(items, err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := ioutil.ReadFile(filepath.Join(d, "c"))
+	if !bytes.Equal(before, after) {
+		t.Fatal("datafile changed")
+	}
+}
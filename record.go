@@ -0,0 +1,49 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+)
+
+// encodeRecord serializes an item with the handler's codec and prefixes it
+// with the codec's tag so decodeRecord can dispatch to the codec the record
+// was written with
+func (self *FileStoreHandler) encodeRecord(item *resource.Item) ([]byte, error) {
+	item, err := self.externalize(self.withoutDerivedFields(item))
+	if err != nil {
+		return nil, err
+	}
+	return encodeRecordWith(self.codec(), item)
+}
+
+// previewRecord returns the record encodeRecord would make for item, without
+// writing its blobs
+func (self *FileStoreHandler) previewRecord(item *resource.Item) ([]byte, error) {
+	item, err := self.externalizeWith(self.withoutDerivedFields(item), func(string, []byte) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+	return encodeRecordWith(self.codec(), item)
+}
+
+func encodeRecordWith(c Codec, item *resource.Item) ([]byte, error) {
+	tag, err := codecTag(c)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := c.Marshal(&item)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tag}, encoded...), nil
+}
+
+// decodeRecord decodes a stored record using the codec matching its tag
+func decodeRecord(data []byte, v interface{}) error {
+	if len(data) > 0 {
+		if c, found := codecs[data[0]]; found {
+			return c.Unmarshal(data[1:], v)
+		}
+	}
+	// Legacy record without a format tag
+	return gobDecode(data, v)
+}
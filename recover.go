@@ -0,0 +1,13 @@
+package filestore
+
+// quarantineDatafile moves aside a datafile which can't be decoded so the
+// handler can start empty with RecoverFromCorruption. The file is kept next
+// to the datafile for inspection, named after the time it was moved.
+func (self *FileStoreHandler) quarantineDatafile(cause error) error {
+	path := self.database_file + ".corrupt." + self.now().UTC().Format("20060102T150405.000000000Z")
+	if err := self.fileSystem().Rename(self.database_file, path); err != nil {
+		return err
+	}
+	self.logf("Warning: database %s is corrupted (%v), moved to %s and starting empty", self.database_file, cause, path)
+	return nil
+}
@@ -0,0 +1,39 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestRecoverFromCorruption(t *testing.T) {
+	d := tmpdir(t)
+	if err := ioutil.WriteFile(filepath.Join(d, "c"), []byte("garbage\x00\x01"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHandler(d, "c", nil); err == nil {
+		t.Fatal("expected error")
+	}
+	h, err := NewHandlerWithOptions(d, "c", WithRecoverFromCorruption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	files, _ := ioutil.ReadDir(d)
+	found := false
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), "c.corrupt.") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("not quarantined")
+	}
+	if err := h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"x": 1})}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,48 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type testColor int
+
+type testPoint struct {
+	X, Y  int
+	Color testColor
+}
+
+func TestRegisteredTypes(t *testing.T) {
+	dir := tmpdir(t)
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(dir, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type unregistered struct{ A int }
+	if err := h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{"p": unregistered{1}})}); err == nil {
+		t.Fatal("unregistered type accepted")
+	}
+	h.Close()
+	RegisterType(testColor(0))
+	h, err = NewHandlerWithOptions(dir, "c", WithRegisteredTypes(testPoint{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := testPoint{1, 2, 3}
+	if err := h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{"p": p, "c": testColor(5)})}); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	h, _ = NewHandlerWithOptions(dir, "c")
+	defer h.Close()
+	got, err := h.Get(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Payload["p"] != p || got.Payload["c"] != testColor(5) {
+		t.Fatalf("%#v", got.Payload)
+	}
+}
@@ -0,0 +1,86 @@
+package filestore
+
+import (
+	"fmt"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// Rekey changes the id of every item to the one returned by newID, updating
+// the id field of its payload and its ETag, and persists the collection once.
+// The order of the items is preserved. Nothing is changed if newID fails, if
+// it returns a zero value id, if two items get the same id or if the
+// collection can't be persisted.
+func (self *FileStoreHandler) Rekey(ctx context.Context, newID func(item *resource.Item) (interface{}, error)) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		records := make(map[interface{}][]byte, len(self.ids))
+		ids := make([]interface{}, 0, len(self.ids))
+		for _, id := range self.liveIDs() {
+			item, _, err := self.decodeItem(id)
+			if err != nil {
+				return err
+			}
+			item = cloneItem(item)
+			self.dropDerivedFields(item.Payload)
+			key, err := newID(item)
+			if err != nil {
+				return err
+			}
+			key = canonicalID(key)
+			if err := checkID(key); err != nil {
+				return err
+			}
+			if isZeroID(key) {
+				return ErrMissingID
+			}
+			if _, found := records[key]; found {
+				return &rest.Error{Code: 409, Message: fmt.Sprintf("Rekey collision: item %v gets the id %v of another item", id, key)}
+			}
+			item.ID = key
+			item.Payload[self.idField()] = key
+			if item.ETag, err = ETag(item.Payload); err != nil {
+				return err
+			}
+			record, err := self.encodeRecord(item)
+			if err != nil {
+				return err
+			}
+			records[key] = record
+			ids = append(ids, key)
+		}
+
+		undo := self.beginUndo()
+		undo.ids = self.snapshotIDs()
+		for _, id := range undo.ids {
+			undo.change(id, self.items[id])
+			self.removeRecord(id)
+		}
+		for _, id := range ids {
+			undo.change(id, nil)
+			self.setRecord(id, records[id])
+			self.advanceSequence(id)
+		}
+		self.setIDs(ids)
+		self.resetChanges()
+		if err := self.rebuildIndexes(); err != nil {
+			self.undo(undo)
+			return err
+		}
+		self.sealUndo(undo)
+		if err := self.persistData(); err != nil {
+			// Back to the old ids
+			self.undo(undo)
+			return err
+		}
+		return nil
+	})
+}
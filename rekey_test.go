@@ -0,0 +1,97 @@
+package filestore
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func prefixID(item *resource.Item) (interface{}, error) {
+	return fmt.Sprintf("k-%v", item.ID), nil
+}
+
+func TestRekey(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithIndexedFields("kind"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{
+		mkitem("b", map[string]interface{}{"kind": "x"}),
+		mkitem("a", map[string]interface{}{"kind": "y"}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Rekey(ctx, prefixID); err != nil {
+		t.Fatal(err)
+	}
+	check := func(h *FileStoreHandler) {
+		t.Helper()
+		if got := sortedIDs(t, h); !reflect.DeepEqual(got, []interface{}{"k-b", "k-a"}) {
+			t.Fatal(got)
+		}
+		item, err := h.Get(ctx, "k-a")
+		if err != nil || item.Payload["id"] != "k-a" {
+			t.Fatal(item, err)
+		}
+		if _, err := h.Get(ctx, "a"); err != resource.ErrNotFound {
+			t.Fatal(err)
+		}
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.Equal{Field: "kind", Value: "x"}})
+		if list, err := h.Find(ctx, l, 1, -1); err != nil || len(list.Items) != 1 || list.Items[0].ID != "k-b" {
+			t.Fatal(list, err)
+		}
+	}
+	check(h)
+	if ids := h.fieldIndex("kind").lookup("y"); !reflect.DeepEqual(ids, []interface{}{"k-a"}) {
+		t.Fatal(ids)
+	}
+	h.Close()
+	if h, err = NewHandlerWithOptions(d, "c", WithIndexedFields("kind")); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	check(h)
+}
+
+func TestRekeyFailedSave(t *testing.T) {
+	ctx := context.Background()
+	fs := &fullFS{memFS: &memFS{files: map[string][]byte{}}}
+	h, err := NewHandlerWithOptions("/data", "c", WithFileSystem(fs), WithIndexedFields("kind"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{
+		mkitem("b", map[string]interface{}{"kind": "x"}),
+		mkitem("a", map[string]interface{}{"kind": "y"}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fs.full = true
+	if err := h.Rekey(ctx, prefixID); err == nil {
+		t.Fatal("expected err")
+	}
+	fs.full = false
+	if got := sortedIDs(t, h); !reflect.DeepEqual(got, []interface{}{"b", "a"}) {
+		t.Fatal(got)
+	}
+	if _, err := h.Get(ctx, "k-a"); err != resource.ErrNotFound {
+		t.Fatal(err)
+	}
+	if ids := h.fieldIndex("kind").lookup("y"); !reflect.DeepEqual(ids, []interface{}{"a"}) {
+		t.Fatal(ids)
+	}
+	// Rekeyed once it can be saved
+	if err := h.Rekey(ctx, prefixID); err != nil {
+		t.Fatal(err)
+	}
+	if got := sortedIDs(t, h); !reflect.DeepEqual(got, []interface{}{"k-b", "k-a"}) {
+		t.Fatal(got)
+	}
+}
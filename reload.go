@@ -0,0 +1,96 @@
+package filestore
+
+import (
+	"os"
+
+	"golang.org/x/net/context"
+)
+
+// Reload replaces the items with the content of the datafile, for a replica
+// polling a datafile written by another process. If the datafile can't be
+// read or decoded, the error is returned and the items are left untouched,
+// RecoverFromCorruption doesn't apply. The changes not saved yet, see
+// FlushInterval, are lost. Like the other bulk changes, the subscribers
+// don't get an event per item and ChangesSince requires a resync.
+func (self *FileStoreHandler) Reload(ctx context.Context) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkOpen(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if self.inMemory() {
+			return nil
+		}
+		if err := self.replaceItems(self.reload); err != nil {
+			return err
+		}
+		self.dirty = false
+		self.debugf("Reloaded database %s", self.database_file)
+		return nil
+	})
+}
+
+// replaceItems replaces the items with the ones load sets, restoring the
+// current items if load fails
+func (self *FileStoreHandler) replaceItems(load func() error) error {
+	items := make(map[interface{}][]byte, len(self.items))
+	for id, record := range self.items {
+		items[id] = record
+	}
+	ids, sequence := self.snapshotIDs(), self.sequence
+	if err := load(); err != nil {
+		for id := range self.items {
+			self.removeRecord(id)
+		}
+		for _, id := range ids {
+			self.setRecord(id, items[id])
+		}
+		self.setIDs(ids)
+		self.sequence = sequence
+		if err := self.rebuildIndexes(); err != nil {
+			self.logf("Error rebuilding indexes of database %s: %v", self.database_file, err)
+		}
+		return err
+	}
+	self.resetChanges()
+	self.changedItems = nil
+	self.changedOrder = nil
+	return nil
+}
+
+// reload replaces the items with the content of the datafile, an absent
+// datafile holding no items
+func (self *FileStoreHandler) reload() error {
+	if self.PerItemFiles {
+		for id := range self.items {
+			self.removeRecord(id)
+		}
+		self.setIDs([]interface{}{})
+		return self.readItemFiles()
+	}
+	var data []byte
+	err := self.retryIO(func() (err error) {
+		data, err = self.fileSystem().ReadFile(self.database_file)
+		return err
+	})
+	if os.IsNotExist(err) {
+		for id := range self.items {
+			self.removeRecord(id)
+		}
+		self.setIDs([]interface{}{})
+		data = nil
+		err = self.rebuildIndexes()
+	} else if err == nil {
+		err = self.decodeDatafile(data)
+	}
+	if err != nil {
+		return err
+	}
+	self.stampDatafile()
+	self.setFileSize(data)
+	_, err = self.replayWAL(data)
+	return err
+}
@@ -0,0 +1,32 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestReload(t *testing.T) {
+	d := tmpdir(t)
+	ctx := context.Background()
+	w, _ := NewHandlerWithOptions(d, "c", WithUniqueFields("n"))
+	w.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"n": 1})})
+	r, err := NewHandlerWithOptions(d, "c", WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"n": 2})})
+	if r.Len() != 1 {
+		t.Fatal(r.Len())
+	}
+	if err := r.Reload(ctx); err != nil || r.Len() != 2 {
+		t.Fatal(err, r.Len())
+	}
+	w.Close()
+	ioutil.WriteFile(w.database_file, []byte("garbage"), 0644)
+	if err := r.Reload(ctx); err == nil || r.Len() != 2 {
+		t.Fatal(err, r.Len())
+	}
+}
@@ -0,0 +1,44 @@
+package filestore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRemoveIDPositions(t *testing.T) {
+	for _, c := range []struct {
+		remove []interface{}
+		want   []interface{}
+		holes  int
+	}{
+		{[]interface{}{"a"}, []interface{}{"b", "c", "d"}, 1},
+		{[]interface{}{"b"}, []interface{}{"a", "c", "d"}, 1},
+		{[]interface{}{"d"}, []interface{}{"a", "b", "c"}, 0},
+		{[]interface{}{"b", "c", "d"}, []interface{}{"a"}, 0},
+		{[]interface{}{"a", "b", "c", "d"}, []interface{}{}, 0},
+	} {
+		h := NewMemoryHandler(0)
+		h.setIDs([]interface{}{"a", "b", "c", "d"})
+		for _, id := range c.remove {
+			h.removeID(id)
+		}
+		live := h.liveIDs()
+		if len(live) == 0 {
+			live = []interface{}{}
+		}
+		if !reflect.DeepEqual(live, c.want) || h.idHoles != c.holes || h.idCount() != len(c.want) {
+			t.Fatal(c.remove, live, h.idHoles, h.ids)
+		}
+		if len(h.ids) != len(c.want)+c.holes {
+			t.Fatal(h.ids)
+		}
+		if full := h.ids[:cap(h.ids)]; full[len(full)-1] != nil && len(h.ids) < cap(h.ids) {
+			t.Fatal("tail retained", full)
+		}
+		for _, id := range c.want {
+			if h.ids[h.idPos[id]] != id {
+				t.Fatal("pos", id)
+			}
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestReloadKeepsOrder(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	for _, id := range []string{"C", "A", "B"} {
+		if err := h.Insert(context.Background(), []*resource.Item{mkitem(id, map[string]interface{}{"n": id})}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h = newH(t, d, "c", nil)
+	l, err := h.Find(context.Background(), &resource.Lookup{}, 1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ""
+	for _, it := range l.Items {
+		got += it.ID.(string)
+	}
+	if got != "CAB" {
+		t.Fatal(got)
+	}
+}
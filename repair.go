@@ -0,0 +1,115 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// RepairReport lists the changes made by Repair
+type RepairReport struct {
+	// DroppedIDs are the ids removed from the ids list, because they had no
+	// record or were listed more than once
+	DroppedIDs []interface{}
+	// ListedIDs are the ids of the records added at the end of the ids list
+	ListedIDs []interface{}
+	// RemovedRecords are the ids of the undecodable records removed
+	RemovedRecords []interface{}
+}
+
+// Changed tells if Repair changed anything
+func (r RepairReport) Changed() bool {
+	return len(r.DroppedIDs) > 0 || len(r.ListedIDs) > 0 || len(r.RemovedRecords) > 0
+}
+
+// Repair fixes the inconsistencies of the ids list and the records reported
+// by Verify: the ids without a record and the repeated ones are dropped from
+// the list, the records which can't be decoded are removed and the unlisted
+// ones appended to the list, in the sortIDs order. The indexes are rebuilt
+// and the datafile saved if anything changed, everything is restored if it
+// can't be. Missing ETags and duplicated unique values are left for the
+// caller to fix, they need a decision Repair can't make.
+func (self *FileStoreHandler) Repair(ctx context.Context) (report RepairReport, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return report, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		ids := make([]interface{}, 0, len(self.ids))
+		listed := make(map[interface{}]bool, len(self.ids))
+		var removed []interface{}
+		for i, id := range self.liveIDs() {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			data, found, err := self.record(id)
+			if listed[id] || !found {
+				report.DroppedIDs = append(report.DroppedIDs, id)
+				continue
+			}
+			listed[id] = true
+			var item resource.Item
+			if err == nil {
+				err = decodeRecord(data, &item)
+			}
+			if err != nil {
+				removed = append(removed, id)
+				continue
+			}
+			ids = append(ids, id)
+		}
+		var unlisted []interface{}
+		for id := range self.items {
+			if listed[id] {
+				continue
+			}
+			data, _, err := self.record(id)
+			var item resource.Item
+			if err == nil {
+				err = decodeRecord(data, &item)
+			}
+			if err != nil {
+				removed = append(removed, id)
+				continue
+			}
+			unlisted = append(unlisted, id)
+		}
+		sortIDs(unlisted)
+		ids = append(ids, unlisted...)
+		report.ListedIDs = unlisted
+		report.RemovedRecords = removed
+		if !report.Changed() {
+			return nil
+		}
+
+		previousIDs := self.snapshotIDs()
+		records := make(map[interface{}][]byte, len(removed))
+		for _, id := range removed {
+			records[id] = self.items[id]
+			self.removeRecord(id)
+		}
+		self.setIDs(ids)
+		self.resetChanges()
+		err := self.rebuildIndexes()
+		if err == nil && !self.persistSuspended() {
+			// Saved by EndBulkLoad or Commit otherwise
+			err = self.saveDatafile()
+		}
+		if err != nil {
+			for id, record := range records {
+				self.setRecord(id, record)
+			}
+			self.setIDs(previousIDs)
+			report = RepairReport{}
+			if err := self.rebuildIndexes(); err != nil {
+				self.logf("Error rebuilding indexes of database %s: %v", self.database_file, err)
+			}
+			return err
+		}
+		self.debugf("Repaired database %s: %d ids dropped, %d listed, %d records removed", self.database_file, len(report.DroppedIDs), len(report.ListedIDs), len(report.RemovedRecords))
+		return nil
+	})
+	return report, err
+}
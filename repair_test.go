@@ -0,0 +1,42 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestRepair(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{}), mkitem(2, map[string]interface{}{}), mkitem(3, map[string]interface{}{})})
+	if r, err := h.Repair(ctx); err != nil || r.Changed() {
+		t.Fatal(r, err)
+	}
+	h.ids = append(h.ids, 9, 1)
+	h.items[7] = h.items[1]
+	h.items[2] = []byte{0xff, 0x01}
+	h.items[8] = []byte{0xff, 0x02}
+	h.cache.invalidate(2)
+	r, err := h.Repair(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.DroppedIDs) != 2 || len(r.ListedIDs) != 1 || r.ListedIDs[0] != 7 || len(r.RemovedRecords) != 2 {
+		t.Fatal(r)
+	}
+	if p, err := h.Verify(ctx); err != nil || len(p) != 0 {
+		t.Fatal(p, err)
+	}
+	h.Close()
+	h, _ = NewHandlerWithOptions(d, "c")
+	defer h.Close()
+	if ids := h.IDs(); len(ids) != 3 || ids[0] != 1 || ids[1] != 3 || ids[2] != 7 {
+		t.Fatal(ids)
+	}
+}
@@ -0,0 +1,42 @@
+package filestore
+
+import (
+	"errors"
+	"syscall"
+	"time"
+)
+
+// DefaultIORetryDelay is the delay before the first retry of a failed read or
+// write of the datafile when IORetryDelay isn't set
+const DefaultIORetryDelay = 10 * time.Millisecond
+
+// transientError tells if err is a failure of a file operation which may
+// succeed when tried again, like an interrupted call or a resource
+// temporarily unavailable on a network filesystem
+func transientError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EINTR, syscall.EAGAIN, syscall.EBUSY} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// retryIO runs op, the read or write of the datafile, and runs it again up to
+// IORetries times while it fails with a transient error. The delay between
+// two tries starts at IORetryDelay and doubles after each try.
+func (self *FileStoreHandler) retryIO(op func() error) error {
+	delay := self.IORetryDelay
+	if delay <= 0 {
+		delay = DefaultIORetryDelay
+	}
+	err := op()
+	for retry := 1; retry <= self.IORetries && err != nil && transientError(err); retry++ {
+		self.logf("Error accessing database %s, retrying in %v (%d/%d): %v", self.database_file, delay, retry, self.IORetries, err)
+		time.Sleep(delay)
+		delay *= 2
+		err = op()
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package filestore
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type failingFS struct {
+	OSFileSystem
+	fail func() error
+}
+
+func (f *failingFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	return f.OSFileSystem.WriteFile(name, data, perm)
+}
+
+func TestIORetries(t *testing.T) {
+	d := tmpdir(t)
+	fs := &failingFS{fail: func() error { return nil }}
+	h, err := NewHandlerWithOptions(d, "c", WithIORetries(3, time.Millisecond), WithFileSystem(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fails := 0
+	fs.fail = func() error {
+		if fails < 2 {
+			fails++
+			return &os.PathError{Op: "write", Path: "x", Err: syscall.EAGAIN}
+		}
+		return nil
+	}
+	if err := h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"id": "a"})}); err != nil || fails != 2 {
+		t.Fatal(err, fails)
+	}
+	calls := 0
+	fs.fail = func() error {
+		calls++
+		return &os.PathError{Op: "write", Path: "x", Err: syscall.EACCES}
+	}
+	if err := h.Insert(context.Background(), []*resource.Item{mkitem("b", map[string]interface{}{"id": "b"})}); err == nil || calls != 1 {
+		t.Fatal(err, calls)
+	}
+	calls = 0
+	fs.fail = func() error {
+		calls++
+		return &os.PathError{Op: "write", Path: "x", Err: syscall.EINTR}
+	}
+	if err := h.Insert(context.Background(), []*resource.Item{mkitem("c", map[string]interface{}{"id": "c"})}); err == nil || calls != 4 {
+		t.Fatal(err, calls)
+	}
+}
@@ -0,0 +1,145 @@
+package filestore
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func randValue(r *rand.Rand, depth int) interface{} {
+	n := 18
+	if depth > 3 {
+		n = 15
+	}
+	switch r.Intn(n) {
+	case 9:
+		return []string{"a", fmt.Sprint(r.Int())}
+	case 10:
+		return []int{r.Int()}
+	case 11:
+		return map[string]string{"a": "b"}
+	case 12:
+		return []float64{r.NormFloat64()}
+	case 13:
+		return []map[string]interface{}{{"a": r.Int()}}
+	case 14:
+		return int32(r.Int31())
+	case 0:
+		return nil
+	case 1:
+		return r.Int()
+	case 2:
+		return r.Int63()
+	case 3:
+		return r.NormFloat64()
+	case 4:
+		return r.Intn(2) == 1
+	case 5:
+		return fmt.Sprint(r.Int())
+	case 6:
+		return time.Unix(r.Int63n(1<<33), r.Int63n(1e9)).UTC()
+	case 7:
+		b := make([]byte, r.Intn(4))
+		r.Read(b)
+		if len(b) == 0 {
+			b = nil
+		}
+		return b
+	case 8:
+		return time.Now()
+	case 15:
+		s := []interface{}{}
+		for i := r.Intn(4); i > 0; i-- {
+			s = append(s, randValue(r, depth+1))
+		}
+		return s
+	default:
+		m := map[string]interface{}{}
+		for i := r.Intn(4); i > 0; i-- {
+			m[fmt.Sprint("k", i)] = randValue(r, depth+1)
+		}
+		return m
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{nil} {
+		r := rand.New(rand.NewSource(1))
+		d := tmpdir(t)
+		h, err := NewHandlerWithOptions(d, "c", WithCodec(codec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		want := map[interface{}]map[string]interface{}{}
+		for i := 0; i < 300; i++ {
+			p := randValue(r, 0)
+			m, ok := p.(map[string]interface{})
+			if !ok {
+				m = map[string]interface{}{"v": p}
+			}
+			item := mkitem(fmt.Sprint(i), m)
+			if err := h.Insert(ctx, []*resource.Item{item}); err != nil {
+				t.Fatal(err)
+			}
+			want[item.ID] = item.Payload
+		}
+		h.Close()
+		h, err = NewHandlerWithOptions(d, "c", WithCodec(codec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, p := range want {
+			got, err := h.Get(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(normTimes(got.Payload), normTimes(p)) {
+				t.Errorf("%T %v\n%#v\n%#v", codec, id, got.Payload, p)
+				break
+			}
+		}
+		h.Close()
+	}
+}
+
+// normTimes make times comparable with DeepEqual
+func normTimes(v interface{}) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UnixNano()
+	case map[string]interface{}:
+		c := map[string]interface{}{}
+		for k, v := range t {
+			c[k] = normTimes(v)
+		}
+		return c
+	case []interface{}:
+		c := []interface{}{}
+		for _, v := range t {
+			c = append(c, normTimes(v))
+		}
+		return c
+	}
+	return v
+}
+
+func TestTypedSliceOwnership(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"s": []string{"x"}, "b": []byte("x")})}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := h.Get(ctx, "a")
+	got.Payload["s"].([]string)[0] = "y"
+	got.Payload["b"].([]byte)[0] = 'y'
+	again, _ := h.Get(ctx, "a")
+	if again.Payload["s"].([]string)[0] != "x" || again.Payload["b"].([]byte)[0] != 'x' {
+		t.Fatal(again.Payload)
+	}
+}
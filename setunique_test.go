@@ -0,0 +1,37 @@
+package filestore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+func TestSetUniqueFields(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"id": "a", "n": "x", "m": 1}),
+		mkitem("b", map[string]interface{}{"id": "b", "n": "x", "m": 2}),
+		mkitem("c", map[string]interface{}{"id": "c", "n": "y", "m": 3}),
+	})
+	err = h.SetUniqueFields(ctx, []string{"m", "n"})
+	if e, ok := err.(*rest.Error); !ok || e.Code != 422 || !strings.Contains(e.Message, "a, b") {
+		t.Fatal(err)
+	}
+	if len(h.UniqueFields) != 0 {
+		t.Fatal(h.UniqueFields)
+	}
+	if err := h.SetUniqueFields(ctx, []string{"m"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("d", map[string]interface{}{"id": "d", "m": 1})}); err == nil {
+		t.Fatal("no unique check")
+	}
+}
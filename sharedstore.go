@@ -0,0 +1,302 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCollectionOpen is returned by SharedStore.Handler for a collection
+// already served by a handler of the store
+var ErrCollectionOpen = errors.New("filestore: collection already opened in the shared store")
+
+// SharedStore keeps several collections in a single file, see NewSharedStore.
+// The file holds the datafile of each collection, as a handler would write it
+// on its own, by collection name.
+type SharedStore struct {
+	mu   sync.Mutex
+	path string
+	// datafiles are the datafiles of the collections, written to the file,
+	// and staged the ones written by a handler but not renamed yet
+	datafiles map[string]storedFile
+	staged    map[string]storedFile
+	// open are the collections served by a handler
+	open    map[string]bool
+	release func() error
+	closed  bool
+}
+
+// storedFile is a file of a SharedStore. perm and durable are the mode and
+// durability the handler writing the file asked for, the file of the store is
+// written with those of the file renamed or removed last.
+type storedFile struct {
+	data    []byte
+	modTime time.Time
+	perm    os.FileMode
+	durable bool
+}
+
+// NewSharedStore opens the file at path holding several collections, loading
+// it if it exists. Each collection is served by the handler returned by
+// Handler. The writes of a handler only replace the datafile of its own
+// collection, the whole file being rewritten under the lock of the store, so
+// the collections don't clobber each other. The file stays locked until the
+// store is closed.
+func NewSharedStore(path string) (*SharedStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	release, err := lockPath(path, DefaultFileMode)
+	if err != nil {
+		return nil, err
+	}
+	s := &SharedStore{
+		path:      path,
+		datafiles: map[string]storedFile{},
+		staged:    map[string]storedFile{},
+		open:      map[string]bool{},
+		release:   release,
+	}
+	if err := s.load(); err != nil {
+		release()
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the datafiles of the file of the store
+func (s *SharedStore) load() error {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, content, err := splitHeader(data)
+	if err != nil {
+		return err
+	}
+	var datafiles map[string][]byte
+	if err := gobDecode(content, &datafiles); err != nil {
+		return err
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for collection, data := range datafiles {
+		s.datafiles[collection] = storedFile{data: data, modTime: now, perm: info.Mode().Perm(), durable: true}
+	}
+	return nil
+}
+
+// save writes the datafiles to the file of the store with the mode and
+// durability of f
+func (s *SharedStore) save(f storedFile) error {
+	datafiles := make(map[string][]byte, len(s.datafiles))
+	for collection, f := range s.datafiles {
+		datafiles[collection] = f.data
+	}
+	data, err := GobCodec{}.Marshal(datafiles)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, addHeader(data), f.perm, f.durable)
+}
+
+// Handler returns the handler of a collection of the store, created with
+// opts. A collection can only be served by one handler at a time, until it is
+// closed. WAL, PersistIndexes, PerItemFiles and FileSystem can't be used, and
+// neither can Backups, BlobThreshold and RecoverFromCorruption, which write
+// files next to the datafile.
+func (s *SharedStore) Handler(collection string, opts ...Option) (*FileStoreHandler, error) {
+	if collection == "" || strings.ContainsRune(collection, filepath.Separator) {
+		return nil, fmt.Errorf("filestore: invalid collection name '%s'", collection)
+	}
+	probe := &FileStoreHandler{}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	if probe.WAL || probe.PersistIndexes || probe.PerItemFiles || probe.FileSystem != nil {
+		return nil, fmt.Errorf("filestore: WAL, PersistIndexes, PerItemFiles and FileSystem aren't supported by a shared store")
+	}
+	if probe.Backups > 0 || probe.BlobThreshold > 0 || probe.RecoverFromCorruption {
+		return nil, fmt.Errorf("filestore: Backups, BlobThreshold and RecoverFromCorruption aren't supported by a shared store")
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if s.open[collection] {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrCollectionOpen, collection)
+	}
+	s.open[collection] = true
+	s.mu.Unlock()
+
+	closed := func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.open, collection)
+		return nil
+	}
+	h, err := NewHandlerWithOptions(s.path, collection, append(opts, WithFileSystem(storeFileSystem{s}))...)
+	if err != nil {
+		closed()
+		return nil, err
+	}
+	h.onShutdown(closed)
+	return h, nil
+}
+
+// Close releases the lock of the file of the store. The handlers of the store
+// must be closed first.
+func (s *SharedStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	if len(s.open) > 0 {
+		return fmt.Errorf("filestore: %d collections of the shared store are still open", len(s.open))
+	}
+	s.closed = true
+	return s.release()
+}
+
+// storeFileSystem is the FileSystem of the handlers of a SharedStore, the
+// files are the datafiles of the store named after the path of the store. A
+// written file is only staged, it is saved to the file of the store when
+// renamed, which is how a handler replaces its datafile.
+type storeFileSystem struct {
+	store *SharedStore
+}
+
+// name returns the name of a file in the store
+func (fs storeFileSystem) name(path string) string {
+	return strings.TrimPrefix(path, fs.store.path+string(filepath.Separator))
+}
+
+func (fs storeFileSystem) file(path string) (storedFile, bool) {
+	name := fs.name(path)
+	if f, found := fs.store.datafiles[name]; found {
+		return f, true
+	}
+	f, found := fs.store.staged[name]
+	return f, found
+}
+
+func (fs storeFileSystem) ReadFile(name string) ([]byte, error) {
+	fs.store.mu.Lock()
+	defer fs.store.mu.Unlock()
+	f, found := fs.file(name)
+	if !found {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (fs storeFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	fs.store.mu.Lock()
+	defer fs.store.mu.Unlock()
+	fs.store.staged[fs.name(name)] = storedFile{data: append([]byte(nil), data...), modTime: time.Now(), perm: perm}
+	return nil
+}
+
+func (fs storeFileSystem) Sync(name string) error {
+	// The file of the store is synced when a durable file is renamed
+	fs.store.mu.Lock()
+	defer fs.store.mu.Unlock()
+	name = fs.name(name)
+	if f, staged := fs.store.staged[name]; staged {
+		f.durable = true
+		fs.store.staged[name] = f
+	}
+	return nil
+}
+
+func (fs storeFileSystem) Stat(name string) (os.FileInfo, error) {
+	fs.store.mu.Lock()
+	defer fs.store.mu.Unlock()
+	f, found := fs.file(name)
+	if !found {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return storedFileInfo{name: filepath.Base(name), size: int64(len(f.data)), modTime: f.modTime, mode: f.perm}, nil
+}
+
+func (fs storeFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return nil
+}
+
+func (fs storeFileSystem) Rename(oldpath, newpath string) error {
+	fs.store.mu.Lock()
+	defer fs.store.mu.Unlock()
+	oldName, newName := fs.name(oldpath), fs.name(newpath)
+	f, staged := fs.store.staged[oldName]
+	if !staged {
+		var found bool
+		if f, found = fs.store.datafiles[oldName]; !found {
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+		}
+	}
+	previous, replaced := fs.store.datafiles[newName]
+	fs.store.datafiles[newName] = f
+	delete(fs.store.datafiles, oldName)
+	if err := fs.store.save(f); err != nil {
+		// Leave the files as they were
+		delete(fs.store.datafiles, newName)
+		if replaced {
+			fs.store.datafiles[newName] = previous
+		}
+		if !staged {
+			fs.store.datafiles[oldName] = f
+		}
+		return err
+	}
+	delete(fs.store.staged, oldName)
+	return nil
+}
+
+func (fs storeFileSystem) Remove(name string) error {
+	fs.store.mu.Lock()
+	defer fs.store.mu.Unlock()
+	name = fs.name(name)
+	if _, staged := fs.store.staged[name]; staged {
+		delete(fs.store.staged, name)
+		return nil
+	}
+	f, found := fs.store.datafiles[name]
+	if !found {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(fs.store.datafiles, name)
+	if err := fs.store.save(f); err != nil {
+		fs.store.datafiles[name] = f
+		return err
+	}
+	return nil
+}
+
+// storedFileInfo is the os.FileInfo of a file of a SharedStore
+type storedFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	mode    os.FileMode
+}
+
+func (i storedFileInfo) Name() string       { return i.name }
+func (i storedFileInfo) Size() int64        { return i.size }
+func (i storedFileInfo) Mode() os.FileMode  { return i.mode }
+func (i storedFileInfo) ModTime() time.Time { return i.modTime }
+func (i storedFileInfo) IsDir() bool        { return false }
+func (i storedFileInfo) Sys() interface{}   { return nil }
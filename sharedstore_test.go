@@ -0,0 +1,94 @@
+package filestore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestSharedStore(t *testing.T) {
+	d := tmpdir(t)
+	p := filepath.Join(d, "all.db")
+	s, err := NewSharedStore(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSharedStore(p); !errors.Is(err, ErrLocked) {
+		t.Fatal(err)
+	}
+	a, err := s.Handler("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Handler("b", WithUniqueFields("n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Handler("a"); !errors.Is(err, ErrCollectionOpen) {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := a.Insert(ctx, []*resource.Item{mkitem("1", map[string]interface{}{"id": "1"})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Insert(ctx, []*resource.Item{mkitem("2", map[string]interface{}{"id": "2", "n": 1}), mkitem("3", map[string]interface{}{"id": "3", "n": 2})}); err != nil {
+		t.Fatal(err)
+	}
+	a.Insert(ctx, []*resource.Item{mkitem("4", map[string]interface{}{"id": "4"})})
+	if err := s.Close(); err == nil {
+		t.Fatal("closed with open handlers")
+	}
+	a.Close()
+	b.Close()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewSharedStore(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ = s.Handler("a")
+	b, _ = s.Handler("b")
+	if a.Len() != 2 || b.Len() != 2 {
+		t.Fatal(a.Len(), b.Len())
+	}
+	a.Close()
+	b.Close()
+	s.Close()
+}
+
+func TestSharedStoreOptions(t *testing.T) {
+	p := filepath.Join(tmpdir(t), "all.db")
+	s, err := NewSharedStore(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, opt := range []Option{WithBackups(2), WithBlobThreshold(10), WithRecoverFromCorruption()} {
+		if _, err := s.Handler("a", opt); err == nil {
+			t.Fatal("sidecar files allowed")
+		}
+	}
+	a, err := s.Handler("a", WithFileMode(0600, 0700), WithDurable(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Insert(context.Background(), []*resource.Item{mkitem("1", map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatal(info.Mode())
+	}
+	if len(s.datafiles) != 1 || s.datafiles["a"].durable {
+		t.Fatal(s.datafiles)
+	}
+}
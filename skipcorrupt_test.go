@@ -0,0 +1,40 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestSkipCorrupt(t *testing.T) {
+	ctx := context.Background()
+	for _, skip := range []bool{false, true} {
+		h, err := NewHandlerWithOptions(tmpdir(t), "c")
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.SkipCorrupt = skip
+		h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{}), mkitem(2, map[string]interface{}{}), mkitem(3, map[string]interface{}{})})
+		h.items[2] = []byte{0xff, 0x01}
+		h.cache.invalidate(2)
+		l, err := h.Find(ctx, resource.NewLookup(), 1, -1)
+		if !skip {
+			if err == nil {
+				t.Fatal("corrupt item not reported")
+			}
+			h.Close()
+			continue
+		}
+		if err != nil || len(l.Items) != 2 || l.Items[0].ID != 1 || l.Items[1].ID != 3 {
+			t.Fatal(l, err)
+		}
+		if n, err := h.Clear(ctx, resource.NewLookup()); err != nil || n != 2 {
+			t.Fatal(n, err)
+		}
+		if h.Stats().Corrupt != 2 {
+			t.Fatal(h.Stats())
+		}
+		h.Close()
+	}
+}
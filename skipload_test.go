@@ -0,0 +1,56 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestSkipInitialLoad(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, _ := NewHandlerWithOptions(d, "c")
+	h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{})})
+	h.Close()
+	fs := &countingFS{}
+	h, err := NewHandlerWithOptions(d, "c", WithSkipInitialLoad(), WithFileSystem(fs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.reads != 0 || h.Len() != 0 {
+		t.Fatal(fs.reads, h.Len())
+	}
+	h.Insert(ctx, []*resource.Item{mkitem(2, map[string]interface{}{}), mkitem(3, map[string]interface{}{})})
+	h.Close()
+	h, _ = NewHandler(d, "c", nil)
+	defer h.Close()
+	if ids := h.IDs(); len(ids) != 2 || ids[0] != 2 {
+		t.Fatal(ids)
+	}
+}
+
+func TestSkipInitialLoadWAL(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, _ := NewHandlerWithOptions(d, "c", WithWAL())
+	h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{})})
+	h.Close()
+	h, err := NewHandlerWithOptions(d, "c", WithWAL(), WithSkipInitialLoad())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.Insert(ctx, []*resource.Item{mkitem(2, map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+	// The acknowledged insert survives a crash
+	r, err := NewHandlerWithOptions(crashCopy(t, d), "c", WithWAL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if ids := r.IDs(); len(ids) != 1 || ids[0] != 2 {
+		t.Fatal(ids)
+	}
+}
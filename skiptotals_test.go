@@ -0,0 +1,75 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func skipTotalsHandler(tb testing.TB, n int, opts ...Option) *FileStoreHandler {
+	h, err := NewHandlerWithOptions(tmpdirTB(tb), "c", opts...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var items []*resource.Item
+	for i := 0; i < n; i++ {
+		items = append(items, mkitem(i+1, map[string]interface{}{"k": i % 2}))
+	}
+	if err := h.Insert(context.Background(), items); err != nil {
+		tb.Fatal(err)
+	}
+	return h
+}
+
+func TestSkipFindTotals(t *testing.T) {
+	ctx := context.Background()
+	h := skipTotalsHandler(t, 100, WithSkipFindTotals())
+	defer h.Close()
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "k", Value: 1}})
+	list, err := h.Find(ctx, l, 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != -1 || len(list.Items) != 10 || list.Items[0].ID != 22 {
+		t.Fatalf("%d %d %v", list.Total, len(list.Items), list.Items[0].ID)
+	}
+	// The last page is reached, the total is known
+	list, _ = h.Find(ctx, l, 6, 10)
+	if list.Total != 50 || len(list.Items) != 0 {
+		t.Fatalf("%d %d", list.Total, len(list.Items))
+	}
+	list, _ = h.FindWithOffset(ctx, l, 45, 20)
+	if list.Total != 50 || len(list.Items) != 5 {
+		t.Fatalf("%d %d", list.Total, len(list.Items))
+	}
+	// Sorted, all scanned
+	l.SetSorts([]string{"-id"})
+	list, _ = h.Find(ctx, l, 1, 10)
+	if list.Total != 50 || list.Items[0].ID != 100 {
+		t.Fatalf("%d %v", list.Total, list.Items[0].ID)
+	}
+	h2 := skipTotalsHandler(t, 100)
+	defer h2.Close()
+	l = resource.NewLookup()
+	if list, _ = h2.Find(ctx, l, 1, 10); list.Total != 100 {
+		t.Fatal(list.Total)
+	}
+}
+
+func benchFirstPage(b *testing.B, opts ...Option) {
+	h := skipTotalsHandler(b, 100000, opts...)
+	defer h.Close()
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "k", Value: 1}})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Find(context.Background(), l, 1, 20)
+	}
+}
+
+func BenchmarkFirstPageFull(b *testing.B) { benchFirstPage(b) }
+
+func BenchmarkFirstPageShort(b *testing.B) { benchFirstPage(b, WithSkipFindTotals()) }
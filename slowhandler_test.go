@@ -0,0 +1,39 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestSlowHandlerPersists(t *testing.T) {
+	dir := tmpdir(t)
+	h, err := NewSlowHandler(dir, "c", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(context.Background(), []*resource.Item{mkitem(1, map[string]interface{}{"a": 1})}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "c")); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	h2, err := NewSlowHandler(dir, "c", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := h2.Count(context.Background(), &resource.Lookup{}); n != 1 {
+		t.Fatalf("count %d", n)
+	}
+	m := NewMemoryHandler(0)
+	if err := m.Insert(context.Background(), []*resource.Item{mkitem(1, map[string]interface{}{"a": 1})}); err != nil {
+		t.Fatal(err)
+	}
+	if !m.inMemory() {
+		t.Fatal("memory handler has a datafile")
+	}
+}
@@ -0,0 +1,77 @@
+package filestore
+
+import (
+	"io"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// Snapshot is the state of a collection at a point in time, see
+// FileStoreHandler.Snapshot
+type Snapshot struct {
+	handler *FileStoreHandler
+	content datafileContent
+}
+
+// Snapshot captures the current state of the collection for a backup. The
+// handler is only locked while the records, which are stored encoded and
+// never modified in place, and the order of the ids are copied, the snapshot
+// can then be written at leisure while the handler keeps serving writes. The
+// expired and soft deleted items not removed yet are part of the snapshot.
+func (self *FileStoreHandler) Snapshot(ctx context.Context) (*Snapshot, error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	s := &Snapshot{handler: self}
+	err := handleWithLatency(self.Latency, ctx, func() error {
+		ids := self.snapshotIDs()
+		items := make(map[interface{}][]byte, len(ids))
+		for i, id := range ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			record, _, err := self.record(id)
+			if err != nil {
+				return err
+			}
+			items[id] = record
+		}
+		s.content = datafileContent{items: items, ids: ids, sequence: self.sequence}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Len returns the number of items of the snapshot
+func (s *Snapshot) Len() int {
+	return len(s.content.ids)
+}
+
+// WriteTo writes the snapshot to w in the datafile format of its handler, so
+// the output can be used as a datafile or loaded with ReadFrom
+func (s *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	data, err := s.handler.encodeContent(s.content, s.decode)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// decode decodes the record of id, without the handler's cache which may hold
+// a more recent version of the item
+func (s *Snapshot) decode(id interface{}) (*resource.Item, bool, error) {
+	var item resource.Item
+	if err := decodeRecord(s.content.items[id], &item); err != nil {
+		return nil, true, err
+	}
+	return &item, true, nil
+}
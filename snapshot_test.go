@@ -0,0 +1,38 @@
+package filestore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	for _, codec := range []Codec{nil, JSONCodec{}} {
+		h, _ := NewHandlerWithOptions(tmpdir(t), "c", WithCodec(codec))
+		h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"v": 1}), mkitem("b", map[string]interface{}{"v": 2})})
+		s, err := h.Snapshot(ctx)
+		if err != nil || s.Len() != 2 {
+			t.Fatal(err)
+		}
+		l, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+		h.Delete(ctx, l.Items[0])
+		h.Merge(ctx, "b", map[string]interface{}{"v": 3}, "")
+		var buf bytes.Buffer
+		if _, err := s.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		r, _ := NewHandlerWithOptions(tmpdir(t), "c", WithCodec(codec))
+		if _, err := r.ReadFrom(&buf); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := r.Get(ctx, "b")
+		if r.idCount() != 2 || b == nil || b.Payload["v"] == 3 {
+			t.Fatal(r.liveIDs(), b)
+		}
+		h.Close()
+		r.Close()
+	}
+}
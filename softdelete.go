@@ -0,0 +1,132 @@
+package filestore
+
+import (
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// SoftDeleteField is the payload field marking an item deleted with
+// SoftDelete
+const SoftDeleteField = "deleted_at"
+
+// With SoftDelete set, Delete and Clear keep the items and stamp them with
+// the time of their deletion in SoftDeleteField instead. Soft deleted items
+// are hidden from all the operations as if they were deleted, including the
+// unique checks so their values can be reused, and inserting an item with
+// the id of a soft deleted one replaces it. FindDeleted lists them and Purge
+// removes them for good.
+
+// softDeleted tells if an item is soft deleted
+func (self *FileStoreHandler) softDeleted(item *resource.Item) bool {
+	if !self.SoftDelete {
+		return false
+	}
+	_, found := item.Payload[SoftDeleteField]
+	return found
+}
+
+// deletedAt returns the deletion time of a soft deleted item
+func deletedAt(item *resource.Item) (time.Time, bool) {
+	return payloadTime(item, SoftDeleteField)
+}
+
+// remove deletes an item, or marks it deleted with SoftDelete. The caller is
+// responsible of persisting the change.
+func (self *FileStoreHandler) remove(item *resource.Item) error {
+	if !self.SoftDelete {
+		self.delete(item.ID)
+		return nil
+	}
+	deleted := cloneItem(item)
+	self.dropDerivedFields(deleted.Payload)
+	deleted.Payload[SoftDeleteField] = self.now()
+	etag, err := ETag(deleted.Payload)
+	if err != nil {
+		return err
+	}
+	deleted.ETag = etag
+	deleted.Updated = self.now()
+	_, record, err := self.encode(deleted)
+	if err != nil {
+		return err
+	}
+	old, _ := self.eventRecord(item.ID)
+	self.setRecord(item.ID, record)
+	self.indexItem(self.withDerivedFields(deleted))
+	self.recordChange(ChangeDelete, item.ID, nil)
+	self.notify(ChangeDelete, item.ID, nil, old)
+	return nil
+}
+
+// FindDeleted is like Find for the soft deleted items
+func (self *FileStoreHandler) FindDeleted(ctx context.Context, lookup *resource.Lookup, page, perPage int) (list *resource.ItemList, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	if err := self.checkLookup(lookup); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		list, err = self.scan(ctx, lookup, pageWindow(page, perPage), self.ids, func(id interface{}) (*resource.Item, bool, error) {
+			item, found, err := self.decodeItem(id)
+			if item != nil && (!self.softDeleted(item) || self.expired(item)) {
+				return nil, false, nil
+			}
+			return item, found, err
+		})
+		return err
+	})
+	count(&self.counters.finds, 1)
+	if list != nil {
+		for i, item := range list.Items {
+			list.Items[i] = self.present(item)
+		}
+	}
+	return list, err
+}
+
+// Purge removes for good the items soft deleted more than olderThan ago and
+// returns how many were removed
+func (self *FileStoreHandler) Purge(ctx context.Context, olderThan time.Duration) (total int, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return 0, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		limit := self.now().Add(-olderThan)
+		ids := self.snapshotIDs()
+		for i, id := range ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			item, _, err := self.decodeItem(id)
+			if err != nil {
+				return err
+			}
+			if !self.softDeleted(item) {
+				continue
+			}
+			if at, ok := deletedAt(item); ok && at.After(limit) {
+				continue
+			}
+			self.removeRecord(id)
+			self.unindexItem(id)
+			self.removeID(id)
+			total++
+		}
+		if total == 0 {
+			return nil
+		}
+		return self.persistData()
+	})
+	return total, err
+}
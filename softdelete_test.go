@@ -0,0 +1,49 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestSoftDelete(t *testing.T) {
+	d := tmpdir(t)
+	ctx := context.Background()
+	h, _ := NewHandlerWithOptions(d, "c", WithSoftDelete(), WithUniqueFields("email"))
+	h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"id": "a", "email": "a"}),
+		mkitem("b", map[string]interface{}{"id": "b", "email": "b"}),
+	})
+	l, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+	if err := h.Delete(ctx, l.Items[0]); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := h.Count(ctx, resource.NewLookup()); n != 1 {
+		t.Fatal(n)
+	}
+	if m, _ := h.MultiGet(ctx, []interface{}{"a"}); m[0] != nil {
+		t.Fatal(m)
+	}
+	del, _ := h.FindDeleted(ctx, resource.NewLookup(), 1, -1)
+	if del.Total != 1 || del.Items[0].ID != "a" {
+		t.Fatal(del)
+	}
+	// unique value reusable
+	if err := h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{"id": "c", "email": "a"})}); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	h, _ = NewHandlerWithOptions(d, "c", WithSoftDelete(), WithUniqueFields("email"))
+	if n, _ := h.Purge(ctx, time.Hour); n != 0 {
+		t.Fatal(n)
+	}
+	if n, _ := h.Purge(ctx, 0); n != 1 {
+		t.Fatal(n)
+	}
+	if del, _ := h.FindDeleted(ctx, resource.NewLookup(), 1, -1); del.Total != 0 {
+		t.Fatal(del)
+	}
+	h.Close()
+}
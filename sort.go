@@ -6,10 +6,20 @@ import (
 	"github.com/rs/rest-layer/resource"
 )
 
-// sortableItems is an item slice implementing sort.Interface
+// sortableItems is an item slice implementing sort.Interface. The sort
+// expressions are field names, which may be dotted paths to the fields of
+// sub documents, prefixed by a '-' for a descending order. Items equal on
+// every expression keep their relative order as it is sorted by sort.Stable.
+//
+// The items missing a field, or holding nil, come after the others whatever
+// the direction, before them if nullsFirst is set. The values of different
+// types are ordered by type: booleans, numbers, which compare across their
+// types, strings, times and then any other type, the values of which compare
+// as equal.
 type sortableItems struct {
-	sort  []string
-	items []*resource.Item
+	sort       []string
+	items      []*resource.Item
+	nullsFirst bool
 }
 
 func (s sortableItems) Len() int {
@@ -22,50 +32,179 @@ func (s sortableItems) Swap(i, j int) {
 
 func (s sortableItems) Less(i, j int) bool {
 	for _, exp := range s.sort {
-		var field1 interface{}
-		var field2 interface{}
-		if exp[0] == '-' {
-			field1 = s.items[j].GetField(exp[1:])
-			field2 = s.items[i].GetField(exp[1:])
-		} else {
-			field1 = s.items[i].GetField(exp)
-			field2 = s.items[j].GetField(exp)
+		field, desc := exp, exp[0] == '-'
+		if desc {
+			field = exp[1:]
 		}
-		if field1 == field2 {
-			continue
+		field1 := s.items[i].GetField(field)
+		field2 := s.items[j].GetField(field)
+		if null1, null2 := field1 == nil, field2 == nil; null1 || null2 {
+			if null1 == null2 {
+				continue
+			}
+			// Not reversed by a descending order
+			return null1 == s.nullsFirst
 		}
-		switch t := field1.(type) {
-		case int:
-			return t < field2.(int)
-		case int8:
-			return t < field2.(int8)
-		case int16:
-			return t < field2.(int16)
-		case int32:
-			return t < field2.(int32)
-		case int64:
-			return t < field2.(int64)
-		case uint:
-			return t < field2.(uint)
-		case uint8:
-			return t < field2.(uint8)
-		case uint16:
-			return t < field2.(uint16)
-		case uint32:
-			return t < field2.(uint32)
-		case uint64:
-			return t < field2.(uint64)
-		case float32:
-			return t < field2.(float32)
-		case float64:
-			return t < field2.(float64)
-		case string:
-			return t < field2.(string)
-		case bool:
-			return t
-		case time.Time:
-			return t.Before(field2.(time.Time))
+		c := compareValues(field1, field2)
+		if desc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
 		}
 	}
 	return false
 }
+
+// compareValues returns -1, 0 or 1 as a is lower than, equal to or greater
+// than b, neither being nil. The values of different types are ordered by
+// type, see sortableItems.
+func compareValues(a, b interface{}) int {
+	ra, rb := sortRank(a), sortRank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	var less, more bool
+	switch t := a.(type) {
+	case bool:
+		// false sorts before true
+		v := b.(bool)
+		less, more = !t && v, t && !v
+	case string:
+		v := b.(string)
+		less, more = t < v, t > v
+	case time.Time:
+		v := b.(time.Time)
+		less, more = t.Before(v), t.After(v)
+	default:
+		if ra == rankNumber {
+			c, _ := compareNumbers(a, b)
+			return c
+		}
+	}
+	switch {
+	case less:
+		return -1
+	case more:
+		return 1
+	}
+	return 0
+}
+
+// compareNumbers returns -1, 0 or 1 as the number a is lower than, equal to
+// or greater than the number b, and false if one of them isn't a number. The
+// integers are compared exactly, the floats can't hold the large ones, and
+// compared as floats with the floats only.
+func compareNumbers(a, b interface{}) (int, bool) {
+	x, xKind := integerValue(a)
+	y, yKind := integerValue(b)
+	if xKind != notInteger && yKind != notInteger {
+		switch {
+		case xKind == yKind && xKind == signedInteger:
+			return compareInt64(int64(x), int64(y)), true
+		case xKind == yKind:
+			return compareUint64(x, y), true
+		case xKind == signedInteger && int64(x) < 0:
+			return -1, true
+		case yKind == signedInteger && int64(y) < 0:
+			return 1, true
+		}
+		return compareUint64(x, y), true
+	}
+	f, ok := toFloat(a)
+	g, ok2 := toFloat(b)
+	if !ok || !ok2 {
+		return 0, false
+	}
+	switch {
+	case f < g:
+		return -1, true
+	case f > g:
+		return 1, true
+	}
+	return 0, true
+}
+
+// The kinds of integers, see integerValue
+const (
+	notInteger = iota
+	signedInteger
+	unsignedInteger
+)
+
+// integerValue returns the bits of the integer value, to read as an int64 or
+// a uint64 depending on its kind, notInteger if value isn't an integer
+func integerValue(value interface{}) (uint64, int) {
+	switch t := value.(type) {
+	case int:
+		return uint64(t), signedInteger
+	case int8:
+		return uint64(t), signedInteger
+	case int16:
+		return uint64(t), signedInteger
+	case int32:
+		return uint64(t), signedInteger
+	case int64:
+		return uint64(t), signedInteger
+	case uint:
+		return uint64(t), unsignedInteger
+	case uint8:
+		return uint64(t), unsignedInteger
+	case uint16:
+		return uint64(t), unsignedInteger
+	case uint32:
+		return uint64(t), unsignedInteger
+	case uint64:
+		return t, unsignedInteger
+	}
+	return 0, notInteger
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// The ranks of the types of the sorted values, see sortRank
+const (
+	rankBool = iota
+	rankNumber
+	rankString
+	rankTime
+	rankOther
+)
+
+// sortRank returns the rank of the type of value, the values of a lower rank
+// sort before the others
+func sortRank(value interface{}) int {
+	switch value.(type) {
+	case bool:
+		return rankBool
+	case string:
+		return rankString
+	case time.Time:
+		return rankTime
+	}
+	if _, ok := toFloat(value); ok {
+		return rankNumber
+	}
+	return rankOther
+}
@@ -0,0 +1,105 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func sortedIDs(t *testing.T, h *FileStoreHandler, sorts ...string) []interface{} {
+	l := resource.NewLookup()
+	l.SetSorts(sorts)
+	res, err := h.Find(context.Background(), l, 1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []interface{}{}
+	for _, item := range res.Items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func TestSortStableNested(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	var items []*resource.Item
+	for i, v := range []struct {
+		g, n int
+		b    bool
+	}{{1, 3, true}, {2, 1, false}, {1, 1, false}, {2, 2, true}, {1, 2, true}} {
+		items = append(items, mkitem(string(rune('a'+i)), map[string]interface{}{"g": v.g, "n": v.n, "b": v.b, "sub": map[string]interface{}{"n": v.n}, "m": map[string]interface{}{}}))
+	}
+	if err := h.Insert(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		sort []string
+		want string
+	}{
+		{[]string{"g"}, "aceb d"},
+		{[]string{"g", "-n"}, "aecdb"},
+		{[]string{"sub.n"}, "bcdea"},
+		{[]string{"-sub.n", "g"}, "aedcb"},
+		{[]string{"b"}, "bcade"},
+		{[]string{"m"}, "abcde"},
+	} {
+		got := ""
+		for _, id := range sortedIDs(t, h, c.sort...) {
+			got += id.(string)
+		}
+		want := ""
+		for _, r := range c.want {
+			if r != ' ' {
+				want += string(r)
+			}
+		}
+		if got != want {
+			t.Error(c.sort, got, want)
+		}
+	}
+}
+
+func TestSortLargeIntegers(t *testing.T) {
+	h := NewMemoryHandler(0)
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{
+		mkitem("a", map[string]interface{}{"n": int64(1<<60 + 1)}),
+		mkitem("b", map[string]interface{}{"n": int64(1 << 60)}),
+		mkitem("c", map[string]interface{}{"n": uint64(1<<63 + 1)}),
+		mkitem("d", map[string]interface{}{"n": -1}),
+		mkitem("e", map[string]interface{}{"n": 0.5}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got := ""
+	for _, id := range sortedIDs(t, h, "n") {
+		got += id.(string)
+	}
+	if got != "debac" {
+		t.Fatal(got)
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	for _, c := range []struct {
+		a, b interface{}
+		want int
+	}{
+		{int64(1<<60 + 1), int64(1 << 60), 1},
+		{uint64(1<<63 + 1), uint64(1 << 63), 1},
+		{-1, uint64(1), -1},
+		{uint64(1 << 63), int64(-1), 1},
+		{5, 5.0, 0},
+		{int8(2), uint16(2), 0},
+		{2.5, 3, -1},
+	} {
+		if got, ok := compareNumbers(c.a, c.b); !ok || got != c.want {
+			t.Errorf("%v %v: %d", c.a, c.b, got)
+		}
+	}
+	if _, ok := compareNumbers(1, "1"); ok {
+		t.Error("compared a string")
+	}
+}
@@ -0,0 +1,214 @@
+package filestore
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+)
+
+// sortedIndex keeps the ids of the items sorted by the numeric value of a
+// field, so a range of values is found by a binary search. Items lacking the
+// field or holding a value which isn't a number aren't indexed. Ids sharing a
+// value are kept in the order they were indexed. Indexing an item shifts the
+// ids following its value, a write costs O(n) but without decoding anything.
+type sortedIndex struct {
+	values []float64
+	ids    []interface{}
+	keys   map[interface{}]float64
+}
+
+// sortedValue returns the value of field in payload as indexed by a
+// sortedIndex
+func sortedValue(payload map[string]interface{}, field string) (float64, bool) {
+	n, ok := toFloat(payload[field])
+	if !ok || math.IsNaN(n) {
+		// NaN doesn't compare, no range holds it
+		return 0, false
+	}
+	return n, true
+}
+
+func (idx *sortedIndex) add(id interface{}, payload map[string]interface{}, field string) {
+	n, ok := sortedValue(payload, field)
+	if current, found := idx.keys[id]; found {
+		if ok && current == n {
+			return
+		}
+		idx.remove(id)
+	}
+	if !ok {
+		return
+	}
+	i := sort.Search(len(idx.values), func(i int) bool { return idx.values[i] > n })
+	idx.values = append(idx.values, 0)
+	copy(idx.values[i+1:], idx.values[i:])
+	idx.values[i] = n
+	idx.ids = append(idx.ids, nil)
+	copy(idx.ids[i+1:], idx.ids[i:])
+	idx.ids[i] = id
+	idx.keys[id] = n
+}
+
+func (idx *sortedIndex) remove(id interface{}) {
+	n, found := idx.keys[id]
+	if !found {
+		return
+	}
+	delete(idx.keys, id)
+	for i := sort.SearchFloat64s(idx.values, n); i < len(idx.values) && idx.values[i] == n; i++ {
+		if idx.ids[i] == id {
+			idx.values = append(idx.values[:i], idx.values[i+1:]...)
+			copy(idx.ids[i:], idx.ids[i+1:])
+			idx.ids[len(idx.ids)-1] = nil
+			idx.ids = idx.ids[:len(idx.ids)-1]
+			return
+		}
+	}
+}
+
+// between returns the ids of the items holding a value from lo to hi, the
+// bounds being included if loIn and hiIn are set
+func (idx *sortedIndex) between(lo float64, loIn bool, hi float64, hiIn bool) []interface{} {
+	start := sort.Search(len(idx.values), func(i int) bool {
+		return idx.values[i] > lo || (loIn && idx.values[i] == lo)
+	})
+	end := sort.Search(len(idx.values), func(i int) bool {
+		return idx.values[i] > hi || (!hiIn && idx.values[i] == hi)
+	})
+	if start >= end {
+		return nil
+	}
+	return append([]interface{}(nil), idx.ids[start:end]...)
+}
+
+// buildSortedIndex returns a sorted index of field built from all the stored
+// items
+func (self *FileStoreHandler) buildSortedIndex(field string) (*sortedIndex, error) {
+	idx := &sortedIndex{keys: map[interface{}]float64{}}
+	for _, id := range self.ids {
+		item, found, err := self.decodeItem(id)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		if n, ok := sortedValue(item.Payload, field); ok {
+			idx.values = append(idx.values, n)
+			idx.ids = append(idx.ids, id)
+			idx.keys[id] = n
+		}
+	}
+	// Sorting all the values at once instead of adding the items one by one
+	sort.Stable(idx)
+	return idx, nil
+}
+
+func (idx *sortedIndex) Len() int           { return len(idx.values) }
+func (idx *sortedIndex) Less(i, j int) bool { return idx.values[i] < idx.values[j] }
+func (idx *sortedIndex) Swap(i, j int) {
+	idx.values[i], idx.values[j] = idx.values[j], idx.values[i]
+	idx.ids[i], idx.ids[j] = idx.ids[j], idx.ids[i]
+}
+
+// rebuildSortedIndexes builds the indexes of the SortedIndexes from the stored
+// items. They aren't persisted: they are rebuilt whenever the other indexes
+// are rebuilt or loaded.
+func (self *FileStoreHandler) rebuildSortedIndexes() error {
+	if len(self.SortedIndexes) == 0 {
+		self.sortedIndexes = nil
+		return nil
+	}
+	indexes := make(map[string]*sortedIndex, len(self.SortedIndexes))
+	for _, field := range self.SortedIndexes {
+		idx, err := self.buildSortedIndex(field)
+		if err != nil {
+			return err
+		}
+		indexes[field] = idx
+	}
+	self.sortedIndexes = indexes
+	return nil
+}
+
+// rangeCandidates returns, in the handler's order, the ids of the items which
+// may match a lookup made only of ranges (GreaterThan, GreaterOrEqual,
+// LowerThan, LowerOrEqual) on a field with a sorted index. The candidates
+// still have to be matched against the lookup, the index doesn't know about
+// the expired and soft deleted items. It returns false when the lookup can't
+// be resolved from a sorted index.
+func (self *FileStoreHandler) rangeCandidates(lookup *resource.Lookup) ([]interface{}, bool) {
+	filter := lookup.Filter()
+	if len(filter) == 0 || len(self.sortedIndexes) == 0 || self.MatchFunc != nil {
+		return nil, false
+	}
+	var field string
+	lo, loIn := math.Inf(-1), true
+	hi, hiIn := math.Inf(1), true
+	for _, exp := range filter {
+		var f string
+		var value float64
+		switch exp := exp.(type) {
+		case schema.GreaterThan:
+			f, value = exp.Field, exp.Value
+			if value >= lo {
+				lo, loIn = value, false
+			}
+		case schema.GreaterOrEqual:
+			f, value = exp.Field, exp.Value
+			if value > lo {
+				lo, loIn = value, true
+			}
+		case schema.LowerThan:
+			f, value = exp.Field, exp.Value
+			if value <= hi {
+				hi, hiIn = value, false
+			}
+		case schema.LowerOrEqual:
+			f, value = exp.Field, exp.Value
+			if value < hi {
+				hi, hiIn = value, true
+			}
+		default:
+			return nil, false
+		}
+		if math.IsNaN(value) || (field != "" && f != field) {
+			return nil, false
+		}
+		field = f
+	}
+	if strings.Contains(field, ".") {
+		// Indexes only cover top level fields
+		return nil, false
+	}
+	idx := self.sortedIndexes[field]
+	if idx == nil {
+		return nil, false
+	}
+	return self.inIDsOrder(idx.between(lo, loIn, hi, hiIn)), true
+}
+
+// inIDsOrder sorts ids, a subset of the ids list, in the handler's order
+func (self *FileStoreHandler) inIDsOrder(ids []interface{}) []interface{} {
+	if len(ids) < 2 {
+		return ids
+	}
+	if self.idPos != nil {
+		sort.Slice(ids, func(i, j int) bool { return self.idPos[ids[i]] < self.idPos[ids[j]] })
+		return ids
+	}
+	candidates := make(map[interface{}]bool, len(ids))
+	for _, id := range ids {
+		candidates[id] = true
+	}
+	ids = ids[:0]
+	for _, id := range self.ids {
+		if candidates[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,89 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func rangeLookup(lo, hi float64) *resource.Lookup {
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.GreaterOrEqual{Field: "n", Value: lo}, schema.LowerThan{Field: "n", Value: hi}})
+	return l
+}
+
+func TestSortedIndex(t *testing.T) {
+	ctx := context.Background()
+	for _, opts := range [][]Option{{WithSortedIndexes("n")}, {}} {
+		h, err := NewHandlerWithOptions(tmpdir(t), "c", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var items []*resource.Item
+		for i := 0; i < 50; i++ {
+			items = append(items, mkitem(i+1, map[string]interface{}{"n": (i * 7) % 50}))
+		}
+		items = append(items, mkitem(100, map[string]interface{}{"n": "x"}), mkitem(101, map[string]interface{}{}))
+		if err := h.Insert(ctx, items); err != nil {
+			t.Fatal(err)
+		}
+		l, err := h.Find(ctx, rangeLookup(10, 20), 1, -1)
+		if err != nil || l.Total != 10 {
+			t.Fatal(l, err)
+		}
+		for i := 1; i < len(l.Items); i++ {
+			if l.Items[i-1].ID.(int) > l.Items[i].ID.(int) {
+				t.Fatal("order", l.Items)
+			}
+		}
+		// Update moves the item out of the range
+		it := mkitem(l.Items[0].ID, map[string]interface{}{"n": 45})
+		it.ETag = "x"
+		if err := h.Update(ctx, it, l.Items[0]); err != nil {
+			t.Fatal(err)
+		}
+		h.Delete(ctx, l.Items[1])
+		if n, _ := h.Count(ctx, rangeLookup(10, 20)); n != 8 {
+			t.Fatal(n)
+		}
+		seen := 0
+		h.FindEach(ctx, rangeLookup(45, 46), func(*resource.Item) error { seen++; return nil })
+		if seen != 2 {
+			t.Fatal(seen)
+		}
+		lg := resource.NewLookup()
+		lg.AddQuery(schema.Query{schema.GreaterThan{Field: "n", Value: 48}})
+		if l, _ := h.Find(ctx, lg, 1, -1); l.Total != 1 {
+			t.Fatal(l.Total)
+		}
+		h.Close()
+	}
+}
+
+func BenchmarkRangeQuery(b *testing.B) {
+	ctx := context.Background()
+	for _, indexed := range []bool{false, true} {
+		b.Run(fmt.Sprint("indexed=", indexed), func(b *testing.B) {
+			h := NewMemoryHandler(0)
+			if indexed {
+				h.SortedIndexes = []string{"n"}
+			}
+			var items []*resource.Item
+			for i := 0; i < 100000; i++ {
+				items = append(items, mkitem(i+1, map[string]interface{}{"n": i, "name": "x"}))
+			}
+			h.Insert(ctx, items)
+			h.rebuildIndexes()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l, _ := h.Find(ctx, rangeLookup(5000, 5100), 1, -1)
+				if l.Total != 100 {
+					b.Fatal(l.Total)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package filestore
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds operational metrics of a handler, see Stats
+type Stats struct {
+	// Items is the number of stored items
+	Items int
+	// FileSize is the size of the datafile when it was last read or written
+	FileSize int64
+	// LastSave is the time of the last successful save, zero if the
+	// handler didn't save yet
+	LastSave time.Time
+	// The number of items inserted, updated and deleted and of calls to the
+	// find methods since the handler was created
+	Inserts int64
+	Updates int64
+	Deletes int64
+	Finds   int64
+	// Corrupt is the number of times an item which can't be decoded was
+	// skipped, see SkipCorrupt
+	Corrupt int64
+	// WorkerPanics is the number of panics recovered in the background
+	// workers and Degraded is set once one of them stopped for good, see
+	// RestartWorkers
+	WorkerPanics int64
+	Degraded     bool
+}
+
+// opCounters counts the operations done by a handler. It is allocated on its
+// own so the counters are aligned for the atomic operations on all platforms.
+// lastFileSize is the size of the datafile last read or written, it is set
+// by the flusher without holding the handler's lock.
+type opCounters struct {
+	inserts      int64
+	updates      int64
+	deletes      int64
+	finds        int64
+	corrupt      int64
+	workerPanics int64
+	lastFileSize int64
+}
+
+// count adds n to one of the counters, it is safe to call under a read lock
+func count(counter *int64, n int) {
+	atomic.AddInt64(counter, int64(n))
+}
+
+// setFileSize records the size of the datafile content data just read or
+// written
+func (self *FileStoreHandler) setFileSize(data []byte) {
+	atomic.StoreInt64(&self.counters.lastFileSize, int64(len(data)))
+}
+
+// Stats returns the current metrics of the handler without decoding any item
+func (self *FileStoreHandler) Stats() Stats {
+	self.RLock()
+	defer self.RUnlock()
+	return Stats{
+		Items:        self.idCount(),
+		FileSize:     atomic.LoadInt64(&self.counters.lastFileSize),
+		LastSave:     self.lastSave,
+		Inserts:      atomic.LoadInt64(&self.counters.inserts),
+		Updates:      atomic.LoadInt64(&self.counters.updates),
+		Deletes:      atomic.LoadInt64(&self.counters.deletes),
+		Finds:        atomic.LoadInt64(&self.counters.finds),
+		Corrupt:      atomic.LoadInt64(&self.counters.corrupt),
+		WorkerPanics: atomic.LoadInt64(&self.counters.workerPanics),
+		Degraded:     self.degraded,
+	}
+}
+
+// Len returns the number of items, leaving out the expired and soft deleted
+// ones not removed yet
+func (self *FileStoreHandler) Len() int {
+	self.RLock()
+	defer self.RUnlock()
+	if self.TTL <= 0 && !self.SoftDelete {
+		return self.idCount()
+	}
+	return len(self.visible(self.liveIDs()))
+}
+
+// IDs returns the ids of the items in the handler's order, leaving out the
+// expired and soft deleted ones not removed yet. The list is a copy the
+// caller is free to modify.
+func (self *FileStoreHandler) IDs() []interface{} {
+	self.RLock()
+	defer self.RUnlock()
+	ids := self.visible(self.snapshotIDs())
+	if ids == nil {
+		ids = []interface{}{}
+	}
+	return ids
+}
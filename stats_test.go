@@ -0,0 +1,28 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestStats(t *testing.T) {
+	h := newH(t, tmpdir(t), "c", nil)
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"x": 1}), mkitem("b", map[string]interface{}{"x": 2})}); err != nil {
+		t.Fatal(err)
+	}
+	h.Find(ctx, &resource.Lookup{}, 1, 10)
+	l, _ := h.Find(ctx, &resource.Lookup{}, 1, 10)
+	if err := h.Delete(ctx, l.Items[0]); err != nil {
+		t.Fatal(err)
+	}
+	s := h.Stats()
+	if s.Items != 1 || s.Inserts != 2 || s.Finds != 2 || s.Deletes != 1 || s.FileSize == 0 || s.LastSave.IsZero() {
+		t.Fatalf("%+v", s)
+	}
+	if s := NewMemoryHandler(0).Stats(); s.Items != 0 || !s.LastSave.IsZero() {
+		t.Fatalf("%+v", s)
+	}
+}
@@ -0,0 +1,215 @@
+package filestore
+
+import (
+	"container/list"
+	"path/filepath"
+	"sync"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// ErrNoTenant is returned by a TenantHandler when the context of an operation
+// doesn't resolve to a tenant
+var ErrNoTenant = &rest.Error{Code: 400, Message: "No tenant for the request"}
+
+// TenantHandler is a resource.Storer routing each operation to the handler of
+// a tenant, resolved from the context of the operation by DirectoryFunc. The
+// handler of a tenant is opened with the options of the TenantHandler the
+// first time the tenant is used and kept open for the next operations. If
+// more than MaxOpen handlers are open, the least recently used ones which are
+// idle are closed, they are opened again by the next operation of their
+// tenant.
+//
+// The handlers are opened and closed with the TenantHandler's lock held, so
+// the operations waiting for a handler to open wait for the closes too.
+type TenantHandler struct {
+	// DirectoryFunc returns the directory and the collection of the tenant of
+	// ctx, an empty directory if ctx has no tenant
+	DirectoryFunc func(ctx context.Context) (directory, collection string)
+	// MaxOpen is the number of handlers kept open, unlimited if zero
+	MaxOpen int
+
+	opts   []Option
+	mu     sync.Mutex
+	closed bool
+	// tenants holds the elements of lru by tenant file, the most recently
+	// used tenant is at the front of lru
+	tenants map[string]*list.Element
+	lru     *list.List
+}
+
+// tenant is an open handler of a TenantHandler
+type tenant struct {
+	key     string
+	handler *FileStoreHandler
+	// busy counts the operations using the handler, it isn't closed while
+	// busy
+	busy int
+}
+
+// NewTenantHandler creates a TenantHandler resolving the tenants with
+// directoryFunc, keeping at most maxOpen handlers open, which are opened with
+// opts.
+func NewTenantHandler(directoryFunc func(ctx context.Context) (directory, collection string), maxOpen int, opts ...Option) *TenantHandler {
+	return &TenantHandler{
+		DirectoryFunc: directoryFunc,
+		MaxOpen:       maxOpen,
+		opts:          opts,
+		tenants:       map[string]*list.Element{},
+		lru:           list.New(),
+	}
+}
+
+// acquire returns the handler of the tenant of ctx, opening it if needed, and
+// the function to call once the operation is done with it
+func (self *TenantHandler) acquire(ctx context.Context) (*FileStoreHandler, func(), error) {
+	directory, collection := self.DirectoryFunc(ctx)
+	if directory == "" {
+		return nil, nil, ErrNoTenant
+	}
+	key := filepath.Join(directory, collection)
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.closed {
+		return nil, nil, ErrClosed
+	}
+	e, found := self.tenants[key]
+	if found {
+		self.lru.MoveToFront(e)
+	} else {
+		h, err := NewHandlerWithOptions(directory, collection, self.opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		e = self.lru.PushFront(&tenant{key: key, handler: h})
+		self.tenants[key] = e
+		self.evict()
+	}
+	t := e.Value.(*tenant)
+	t.busy++
+	return t.handler, func() {
+		self.mu.Lock()
+		t.busy--
+		self.evict()
+		self.mu.Unlock()
+	}, nil
+}
+
+// evict closes the least recently used idle handlers in excess of MaxOpen
+func (self *TenantHandler) evict() {
+	if self.MaxOpen <= 0 {
+		return
+	}
+	for e := self.lru.Back(); e != nil && self.lru.Len() > self.MaxOpen; {
+		t := e.Value.(*tenant)
+		prev := e.Prev()
+		if t.busy == 0 {
+			self.lru.Remove(e)
+			delete(self.tenants, t.key)
+			if err := t.handler.Close(); err != nil {
+				t.handler.logf("Error closing idle tenant database %s: %v", t.handler.database_file, err)
+			}
+		}
+		e = prev
+	}
+}
+
+// OpenTenants returns the number of handlers open
+func (self *TenantHandler) OpenTenants() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.lru.Len()
+}
+
+// Close closes the handlers of all the tenants, the operations made
+// afterwards fail with ErrClosed. The first error is returned.
+func (self *TenantHandler) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.closed {
+		return nil
+	}
+	self.closed = true
+	var err error
+	for e := self.lru.Front(); e != nil; e = e.Next() {
+		if cerr := e.Value.(*tenant).handler.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	self.tenants = map[string]*list.Element{}
+	self.lru.Init()
+	return err
+}
+
+// Find implements resource.Storer on the handler of the tenant of ctx
+func (self *TenantHandler) Find(ctx context.Context, lookup *resource.Lookup, page, perPage int) (*resource.ItemList, error) {
+	h, release, err := self.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return h.Find(ctx, lookup, page, perPage)
+}
+
+// Insert implements resource.Storer on the handler of the tenant of ctx
+func (self *TenantHandler) Insert(ctx context.Context, items []*resource.Item) error {
+	h, release, err := self.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return h.Insert(ctx, items)
+}
+
+// Update implements resource.Storer on the handler of the tenant of ctx
+func (self *TenantHandler) Update(ctx context.Context, item *resource.Item, original *resource.Item) error {
+	h, release, err := self.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return h.Update(ctx, item, original)
+}
+
+// Delete implements resource.Storer on the handler of the tenant of ctx
+func (self *TenantHandler) Delete(ctx context.Context, item *resource.Item) error {
+	h, release, err := self.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return h.Delete(ctx, item)
+}
+
+// Clear implements resource.Storer on the handler of the tenant of ctx
+func (self *TenantHandler) Clear(ctx context.Context, lookup *resource.Lookup) (int, error) {
+	h, release, err := self.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return h.Clear(ctx, lookup)
+}
+
+// MultiGet implements resource.MultiGetter on the handler of the tenant of ctx
+func (self *TenantHandler) MultiGet(ctx context.Context, ids []interface{}) ([]*resource.Item, error) {
+	h, release, err := self.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return h.MultiGet(ctx, ids)
+}
+
+// Get returns the item with the given id of the tenant of ctx, see
+// FileStoreHandler.Get
+func (self *TenantHandler) Get(ctx context.Context, id interface{}) (*resource.Item, error) {
+	h, release, err := self.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return h.Get(ctx, id)
+}
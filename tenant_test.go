@@ -0,0 +1,66 @@
+package filestore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+type tenantKey struct{}
+
+func TestTenantHandler(t *testing.T) {
+	dir := tmpdir(t)
+	th := NewTenantHandler(func(ctx context.Context) (string, string) {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		if tenant == "" {
+			return "", ""
+		}
+		return filepath.Join(dir, tenant), "items"
+	}, 2)
+	defer th.Close()
+	bg := context.Background()
+	a := context.WithValue(bg, tenantKey{}, "a")
+	b := context.WithValue(bg, tenantKey{}, "b")
+	c := context.WithValue(bg, tenantKey{}, "c")
+	if err := th.Insert(a, []*resource.Item{mkitem("1", map[string]interface{}{"t": "a"})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := th.Insert(b, []*resource.Item{mkitem("1", map[string]interface{}{"t": "b"}), mkitem("2", map[string]interface{}{})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := th.Insert(bg, []*resource.Item{mkitem("3", map[string]interface{}{})}); err != ErrNoTenant {
+		t.Fatal(err)
+	}
+	if _, err := th.Find(bg, resource.NewLookup(), 1, 10); err != ErrNoTenant {
+		t.Fatal(err)
+	}
+	la, _ := th.Find(a, resource.NewLookup(), 1, 10)
+	lb, _ := th.Find(b, resource.NewLookup(), 1, 10)
+	if la.Total != 1 || la.Items[0].Payload["t"] != "a" || lb.Total != 2 {
+		t.Fatal(la, lb)
+	}
+	// Evicts a, the least recently used
+	th.Find(c, resource.NewLookup(), 1, 10)
+	if n := th.OpenTenants(); n != 2 {
+		t.Fatal(n)
+	}
+	// Reopened
+	if item, err := th.Get(a, "1"); err != nil || item.Payload["t"] != "a" {
+		t.Fatal(item, err)
+	}
+	// b was evicted in turn
+	h, err := NewHandlerWithOptions(filepath.Join(dir, "b"), "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if h.Len() != 2 {
+		t.Fatal(h.Len())
+	}
+	th.Close()
+	if _, err := th.Get(a, "1"); err != ErrClosed {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+package filestore
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// Payload fields stamped by the handler with Timestamps
+const (
+	// CreatedAtField holds the time the item was inserted
+	CreatedAtField = "created_at"
+	// UpdatedAtField holds the time the item was last written
+	UpdatedAtField = "updated_at"
+)
+
+// ErrNoTimestamps is returned by FindModifiedSince when Timestamps isn't set
+var ErrNoTimestamps = errors.New("filestore: FindModifiedSince requires Timestamps")
+
+// With Timestamps set, every write of an item stamps it with the time of the
+// handler's Clock in UpdatedAtField, and with the time of its insertion in
+// CreatedAtField, whatever the client sent in these fields. The stamps are
+// stored in the payload, so they survive a save and load, but aren't part of
+// the ETag of the item.
+
+// stamp returns item with its timestamps set, the payload of item itself isn't
+// modified
+func (self *FileStoreHandler) stamp(item *resource.Item) *resource.Item {
+	if !self.Timestamps {
+		return item
+	}
+	now := self.now()
+	payload := make(map[string]interface{}, len(item.Payload)+2)
+	for k, v := range item.Payload {
+		payload[k] = v
+	}
+	delete(payload, CreatedAtField)
+	if stored, found, _ := self.peek(item.ID); !found {
+		payload[CreatedAtField] = now
+	} else if created, found := stored.Payload[CreatedAtField]; found {
+		payload[CreatedAtField] = created
+	}
+	payload[UpdatedAtField] = now
+	stamped := *item
+	stamped.Payload = payload
+	return &stamped
+}
+
+// payloadTime returns the time held by a field of item. It is a time.Time, or
+// a string once read back by JSONCodec.
+func payloadTime(item *resource.Item, field string) (time.Time, bool) {
+	switch t := item.Payload[field].(type) {
+	case time.Time:
+		return t, true
+	case string:
+		at, err := time.Parse(time.RFC3339Nano, t)
+		return at, err == nil
+	}
+	return time.Time{}, false
+}
+
+// FindModifiedSince returns the page of the items written after since, by
+// UpdatedAtField, sorted from the least to the most recently written. It
+// requires Timestamps, the items stored before it was set aren't returned.
+func (self *FileStoreHandler) FindModifiedSince(ctx context.Context, since time.Time, page, perPage int) (list *resource.ItemList, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	if !self.Timestamps {
+		return nil, ErrNoTimestamps
+	}
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		var items []*resource.Item
+		var times []time.Time
+		for i, id := range self.ids {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			item, found, err := self.peek(id)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+			if at, ok := payloadTime(item, UpdatedAtField); ok && at.After(since) {
+				items = append(items, item)
+				times = append(times, at)
+			}
+		}
+		w := pageWindow(page, perPage)
+		start, end := w.bounds(len(items))
+		if err := self.checkResultSize(end - start); err != nil {
+			return err
+		}
+		sort.Stable(modifiedItems{items, times})
+		list = paginate(items, w)
+		for i, item := range list.Items {
+			self.touch(item.ID)
+			list.Items[i] = self.present(cloneItem(item))
+		}
+		return nil
+	})
+	count(&self.counters.finds, 1)
+	return list, err
+}
+
+// modifiedItems sorts items by their times
+type modifiedItems struct {
+	items []*resource.Item
+	times []time.Time
+}
+
+func (s modifiedItems) Len() int {
+	return len(s.items)
+}
+
+func (s modifiedItems) Less(i, j int) bool {
+	return s.times[i].Before(s.times[j])
+}
+
+func (s modifiedItems) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.times[i], s.times[j] = s.times[j], s.times[i]
+}
@@ -0,0 +1,49 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestTimestamps(t *testing.T) {
+	for _, codec := range []Option{WithCodec(GobCodec{}), WithCodec(JSONCodec{})} {
+		c := &fakeClock{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+		d := tmpdir(t)
+		h, err := NewHandlerWithOptions(d, "c", WithClock(c), WithTimestamps(), codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+		t0 := c.t
+		h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{}), mkitem("b", map[string]interface{}{})})
+		c.t = c.t.Add(time.Minute)
+		h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{})})
+		c.t = c.t.Add(time.Minute)
+		a, _ := h.Get(ctx, "a")
+		if err := h.Update(ctx, mkitem("a", map[string]interface{}{"x": 1, CreatedAtField: "bogus"}), a); err != nil {
+			t.Fatal(err)
+		}
+		h.Close()
+		h, _ = NewHandlerWithOptions(d, "c", WithClock(c), WithTimestamps(), codec)
+		list, err := h.FindModifiedSince(ctx, t0, 1, -1)
+		if err != nil || list.Total != 2 || list.Items[0].ID != "c" || list.Items[1].ID != "a" {
+			t.Fatal(err, list)
+		}
+		created, _ := payloadTime(list.Items[1], CreatedAtField)
+		if !created.Equal(t0) {
+			t.Fatal(list.Items[1].Payload)
+		}
+		list, _ = h.FindModifiedSince(ctx, t0.Add(-time.Second), 1, 1)
+		if list.Total != 3 || len(list.Items) != 1 || list.Items[0].ID != "b" {
+			t.Fatal(list.Items)
+		}
+		h.Close()
+	}
+	h, _ := NewHandlerWithOptions(tmpdir(t), "c")
+	if _, err := h.FindModifiedSince(context.Background(), time.Time{}, 1, 1); err != ErrNoTimestamps {
+		t.Fatal(err)
+	}
+}
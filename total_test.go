@@ -0,0 +1,53 @@
+package filestore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestTotalUnderConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	h := NewMemoryHandler(0)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				id := fmt.Sprintf("%d-%d", g, i)
+				if err := h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{"n": i})}); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%2 == 0 {
+					h.DeleteMany(ctx, []interface{}{id})
+				}
+			}
+		}(g)
+	}
+	for i := 0; i < 500; i++ {
+		page := i%5 + 1
+		list, err := h.Find(ctx, resource.NewLookup(), page, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if list.Total < len(list.Items) {
+			t.Fatalf("total %d for %d items", list.Total, len(list.Items))
+		}
+		if len(list.Items) > 0 && list.Total < (page-1)*10+len(list.Items) {
+			t.Fatalf("total %d for page %d of %d items", list.Total, page, len(list.Items))
+		}
+	}
+	close(done)
+	wg.Wait()
+}
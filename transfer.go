@@ -0,0 +1,50 @@
+package filestore
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// WriteTo writes the whole collection to w in the datafile format, so the
+// output can be used as a datafile or loaded in another handler with ReadFrom.
+// The read lock is only held while the collection is encoded.
+func (self *FileStoreHandler) WriteTo(w io.Writer) (int64, error) {
+	self.RLock()
+	var data []byte
+	err := self.checkOpen()
+	if err == nil {
+		data, err = self.encodeDatafile()
+	}
+	self.RUnlock()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// ReadFrom replaces the whole collection with the datafile formatted content
+// read from r and persists it. The collection is left untouched if r can't be
+// decoded.
+func (self *FileStoreHandler) ReadFrom(r io.Reader) (int64, error) {
+	data, err := ioutil.ReadAll(r)
+	n := int64(len(data))
+	if err != nil {
+		return n, err
+	}
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return n, err
+	}
+	if err := self.decodeDatafile(data); err != nil {
+		return n, err
+	}
+	self.resetChanges()
+	if !self.persistSuspended() {
+		if err := self.saveDatafile(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
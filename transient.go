@@ -0,0 +1,104 @@
+package filestore
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+)
+
+// ErrNoTransaction is returned by Commit and Rollback when Begin wasn't called
+var ErrNoTransaction = errors.New("filestore: no transaction begun")
+
+// Begin starts a transient batch: until Commit or Rollback, the mutations only
+// update the memory and nothing is written to disk, like with BeginBulkLoad.
+// Commit persists the batch at once and Rollback restores the collection as
+// it was when Begin was called. Close commits a batch still running. Begin
+// copies the references to the records, not the records themselves, so it is
+// cheap whatever the size of the collection. A transaction can't begin during
+// a bulk load, but a bulk load may begin within a transaction: what it loads
+// is then committed or rolled back with the transaction.
+func (self *FileStoreHandler) Begin(ctx context.Context) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if self.txn != nil {
+			return errors.New("filestore: transaction already begun")
+		}
+		if self.bulkLoading {
+			return errors.New("filestore: bulk load in progress")
+		}
+		if self.dirty {
+			// Rollback restores the state Begin is called in, have it on
+			// disk too
+			if err := self.saveDatafile(); err != nil {
+				return err
+			}
+		}
+		ids := self.snapshotIDs()
+		items := make(map[interface{}][]byte, len(self.items))
+		for id, record := range self.items {
+			items[id] = record
+		}
+		self.txn = &datafileContent{items: items, ids: ids, sequence: self.sequence}
+		return nil
+	})
+}
+
+// Commit persists the mutations made since Begin
+func (self *FileStoreHandler) Commit(ctx context.Context) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if self.txn == nil {
+			return ErrNoTransaction
+		}
+		if err := self.saveDatafile(); err != nil {
+			return err
+		}
+		self.txn = nil
+		return nil
+	})
+}
+
+// Rollback discards the mutations made since Begin. The subscribers don't get
+// the events of the discarded mutations.
+func (self *FileStoreHandler) Rollback(ctx context.Context) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if self.txn == nil {
+			return ErrNoTransaction
+		}
+		for id := range self.items {
+			self.removeRecord(id)
+		}
+		for _, id := range self.txn.ids {
+			self.setRecord(id, self.txn.items[id])
+		}
+		self.setIDs(self.txn.ids)
+		self.sequence = self.txn.sequence
+		self.resetChanges()
+		if err := self.rebuildIndexes(); err != nil {
+			return err
+		}
+		self.pending = nil
+		self.dirty = false
+		self.txn = nil
+		return nil
+	})
+}
@@ -0,0 +1,104 @@
+package filestore
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestTransient(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h := newH(t, d, "c", []string{"v"})
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"v": 1})})
+	before, _ := ioutil.ReadFile(filepath.Join(d, "c"))
+	if err := h.Rollback(ctx); err != ErrNoTransaction {
+		t.Fatal(err)
+	}
+	if err := h.Begin(ctx); err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"v": 2})})
+	h.Delete(ctx, &resource.Item{ID: "a"})
+	if after, _ := ioutil.ReadFile(filepath.Join(d, "c")); string(after) != string(before) {
+		t.Fatal("written")
+	}
+	if err := h.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := h.liveIDs(); len(ids) != 1 || ids[0] != "a" {
+		t.Fatal(ids)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("x", map[string]interface{}{"v": 2})}); err != nil {
+		t.Fatal("stale index", err)
+	}
+	h.Begin(ctx)
+	h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{"v": 3})})
+	if err := h.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r := newH(t, d, "c", nil)
+	if r.idCount() != 3 {
+		t.Fatal(r.liveIDs())
+	}
+}
+
+func TestTransientBulkLoad(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{})})
+	before, _ := ioutil.ReadFile(filepath.Join(d, "c"))
+
+	// No transaction during a bulk load
+	h.BeginBulkLoad()
+	if err := h.Begin(ctx); err == nil {
+		t.Fatal("transaction begun during a bulk load")
+	}
+	if err := h.EndBulkLoad(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A bulk load within a transaction is rolled back with it
+	if err := h.Begin(ctx); err != nil {
+		t.Fatal(err)
+	}
+	h.BeginBulkLoad()
+	h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{})})
+	if err := h.EndBulkLoad(ctx); err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{})})
+	if after, _ := ioutil.ReadFile(filepath.Join(d, "c")); string(after) != string(before) {
+		t.Fatal("written before the commit")
+	}
+	if err := h.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ids := h.IDs(); len(ids) != 1 || ids[0] != "a" {
+		t.Fatal(ids)
+	}
+
+	// and outlives its commit
+	h.Begin(ctx)
+	h.BeginBulkLoad()
+	h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{})})
+	if err := h.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	committed, _ := ioutil.ReadFile(filepath.Join(d, "c"))
+	h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{})})
+	if after, _ := ioutil.ReadFile(filepath.Join(d, "c")); string(after) != string(committed) {
+		t.Fatal("written during the bulk load")
+	}
+	if err := h.EndBulkLoad(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r := newH(t, d, "c", nil)
+	if r.idCount() != 3 {
+		t.Fatal(r.liveIDs())
+	}
+}
@@ -0,0 +1,57 @@
+package filestore
+
+import "golang.org/x/net/context"
+
+// Truncate removes all the items at once without decoding them, including the
+// expired and soft deleted ones not removed yet, and returns how many there
+// were. The emptied datafile is saved right away, and the items are restored if
+// it can't be. Like the other bulk changes, the subscribers don't get an event
+// per item and ChangesSince requires a resync.
+func (self *FileStoreHandler) Truncate(ctx context.Context) (total int, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return 0, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		ids := self.snapshotIDs()
+		items := make(map[interface{}][]byte, len(self.items))
+		for id := range self.items {
+			// The item files are removed by the save
+			record, _, err := self.record(id)
+			if err != nil {
+				return err
+			}
+			items[id] = record
+		}
+		for id := range items {
+			self.removeRecord(id)
+		}
+		self.setIDs([]interface{}{})
+		self.resetChanges()
+		if err := self.rebuildIndexes(); err != nil {
+			return err
+		}
+		total = len(ids)
+		if self.persistSuspended() {
+			// Saved by EndBulkLoad or Commit
+			return nil
+		}
+		if err := self.saveDatafile(); err != nil {
+			for _, id := range ids {
+				self.setRecord(id, items[id])
+			}
+			self.setIDs(ids)
+			total = 0
+			if err := self.rebuildIndexes(); err != nil {
+				self.logf("Error rebuilding indexes of database %s: %v", self.database_file, err)
+			}
+			return err
+		}
+		return nil
+	})
+	count(&self.counters.deletes, total)
+	return total, err
+}
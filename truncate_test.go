@@ -0,0 +1,32 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestTruncate(t *testing.T) {
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithUniqueFields("n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"id": "a", "n": 1}), mkitem("b", map[string]interface{}{"id": "b", "n": 2})})
+	n, err := h.Truncate(ctx)
+	if err != nil || n != 2 || h.Len() != 0 {
+		t.Fatal(n, err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{"id": "c", "n": 1})}); err != nil {
+		t.Fatal(err)
+	}
+	h.Truncate(ctx)
+	h.Close()
+	h, err = NewHandlerWithOptions(d, "c")
+	if err != nil || h.Len() != 0 {
+		t.Fatal(err)
+	}
+	h.Close()
+}
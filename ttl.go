@@ -0,0 +1,86 @@
+package filestore
+
+import (
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+)
+
+// expired tells if an item is older than the handler's TTL. The age of an
+// item is computed from its Updated time, which is stored with it, so it
+// survives a save and load and writing an item again extends its life. Items
+// with a zero Updated time never expire.
+//
+// Expired items are hidden from all operations as if they were deleted and
+// actually removed by a background sweeper started on the first write, every
+// TTL.
+func (self *FileStoreHandler) expired(item *resource.Item) bool {
+	if self.TTL <= 0 || item.Updated.IsZero() {
+		return false
+	}
+	return self.now().Sub(item.Updated) > self.TTL
+}
+
+// visible returns the ids of ids whose item isn't hidden by peek, expired or
+// soft deleted
+func (self *FileStoreHandler) visible(ids []interface{}) []interface{} {
+	if self.TTL <= 0 && !self.SoftDelete {
+		return ids
+	}
+	live := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if _, found, _ := self.peek(id); found {
+			live = append(live, id)
+		}
+	}
+	return live
+}
+
+// startSweeper starts the worker removing the expired items if it isn't
+// already running. It must be called with the write lock held.
+func (self *FileStoreHandler) startSweeper() {
+	if self.sweeping {
+		return
+	}
+	self.sweeping = true
+	interval := self.TTL
+	self.startWorker(stageProducer, func(stop <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := self.sweep(); err != nil {
+					self.logf("Error removing expired items of database %s: %v", self.database_file, err)
+				}
+			}
+		}
+	})
+}
+
+// sweep removes the expired items and persists the change
+func (self *FileStoreHandler) sweep() error {
+	self.Lock()
+	defer self.Unlock()
+	if self.closed {
+		return nil
+	}
+	ids := self.snapshotIDs()
+	removed := 0
+	for _, id := range ids {
+		item, _, err := self.decodeItem(id)
+		if err != nil {
+			return err
+		}
+		if self.expired(item) {
+			self.delete(id)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return nil
+	}
+	return self.persistData()
+}
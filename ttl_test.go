@@ -0,0 +1,52 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+func TestTTL(t *testing.T) {
+	d := tmpdir(t)
+	h := newH(t, d, "c", []string{"name"})
+	h.TTL = 50 * time.Millisecond
+	ctx := context.Background()
+	a := &resource.Item{ID: "a", ETag: "x", Updated: time.Now(), Payload: map[string]interface{}{"id": "a", "name": "n"}}
+	if err := h.Insert(ctx, []*resource.Item{a}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := h.Count(ctx, resource.NewLookup()); n != 1 {
+		t.Fatal(n)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if n, _ := h.Count(ctx, resource.NewLookup()); n != 0 {
+		t.Fatal(n)
+	}
+	l := resource.NewLookup()
+	l.AddQuery(schema.Query{schema.Equal{Field: "name", Value: "n"}})
+	if r, _ := h.Find(ctx, l, 1, -1); r.Total != 0 {
+		t.Fatal(r)
+	}
+	// reinsert same id and unique value
+	a2 := &resource.Item{ID: "a", ETag: "y", Updated: time.Now(), Payload: map[string]interface{}{"id": "a", "name": "n"}}
+	if err := h.Insert(ctx, []*resource.Item{a2}); err != nil {
+		t.Fatal(err)
+	}
+	h.RLock()
+	ni := h.idCount()
+	h.RUnlock()
+	if ni != 1 {
+		t.Fatal(ni)
+	}
+	time.Sleep(120 * time.Millisecond)
+	h.RLock()
+	n := len(h.items)
+	h.RUnlock()
+	if n != 0 {
+		t.Fatal("not swept", n)
+	}
+	h.Close()
+}
@@ -0,0 +1,146 @@
+package filestore
+
+// A write changes the memory, then persists it, and undoes its changes if
+// they can't be persisted. The lock may be released while persisting, see
+// CoalesceWrites and SyncBatchWindow, and the other writes may change the
+// memory in the meantime: a write undoes its own changes by id instead of
+// cutting the ids list and the pending events back to their length before
+// it, so the ids, records and events of the other writes are kept. An item
+// changed again by another write since isn't restored.
+
+// writeUndo records the changes of a write to undo them
+type writeUndo struct {
+	// old are the records of the changed items before the write, nil for
+	// the ones it inserted, and stored their records after it, missing for
+	// the ones it deleted
+	old    map[interface{}][]byte
+	stored map[interface{}][]byte
+	// ids is the ids list before the write, set if it removes ids so they
+	// are put back at their position
+	ids []interface{}
+	// events is the number of the last event queued before the write, and
+	// queued after it
+	events, queued uint64
+	// sequence is the sequence of the ids before the write, and advanced
+	// after it
+	sequence, advanced int
+}
+
+// beginUndo starts recording the changes of a write
+func (self *FileStoreHandler) beginUndo() *writeUndo {
+	return &writeUndo{
+		old:      map[interface{}][]byte{},
+		events:   self.eventSeq,
+		sequence: self.sequence,
+	}
+}
+
+// change records record, the record of id before the write changes it, nil
+// if there's none. Only the first change of an id is recorded.
+func (u *writeUndo) change(id interface{}, record []byte) {
+	if _, found := u.old[id]; !found {
+		u.old[id] = record
+	}
+}
+
+// changed tells if the write changed any item
+func (u *writeUndo) changed() bool {
+	return len(u.old) > 0
+}
+
+// sealUndo records the state left by the write once it changed the memory,
+// before persisting it
+func (self *FileStoreHandler) sealUndo(u *writeUndo) {
+	u.stored = make(map[interface{}][]byte, len(u.old))
+	for id := range u.old {
+		if record, found := self.items[id]; found {
+			u.stored[id] = record
+		}
+	}
+	u.queued = self.eventSeq
+	u.advanced = self.sequence
+}
+
+// undo undoes the changes recorded by u, the changes of a write which couldn't
+// be persisted
+func (self *FileStoreHandler) undo(u *writeUndo) {
+	if u.stored == nil {
+		// Failed before persisting, with the lock held all along
+		self.sealUndo(u)
+	}
+	restored := map[interface{}][]byte{}
+	for id, old := range u.old {
+		record, found := self.items[id]
+		stored, wasStored := u.stored[id]
+		if found != wasStored || !sameRecord(record, stored) {
+			// Changed by another write since
+			continue
+		}
+		switch {
+		case old == nil && found:
+			self.removeRecord(id)
+			self.unindexItem(id)
+			self.removeID(id)
+			self.recordChange(ChangeDelete, id, nil)
+		case old != nil && found:
+			self.setRecord(id, old)
+			self.recordChange(ChangeUpdate, id, old)
+		case old != nil:
+			self.setRecord(id, old)
+			self.recordChange(ChangeInsert, id, old)
+			restored[id] = old
+		}
+	}
+	if len(restored) > 0 {
+		self.restoreIDs(u.ids, restored)
+	}
+	if self.sequence == u.advanced {
+		self.sequence = u.sequence
+	}
+	self.dropEvents(u.events, u.queued)
+	// The log can't express the restored positions, the next persist
+	// rewrites the datafile
+	self.wal.pending = nil
+	self.wal.full = true
+	// The datafile content being written may hold the changes undone
+	self.saveGen++
+	self.dirty = true
+	if err := self.rebuildIndexes(); err != nil {
+		self.logf("Error rebuilding indexes of database %s: %v", self.database_file, err)
+	}
+}
+
+// restoreIDs puts the ids of restored back in the ids list at their position
+// in ids, the list before they were removed, keeping the ids added since. The
+// ids missing from ids are put back at the end.
+func (self *FileStoreHandler) restoreIDs(ids []interface{}, restored map[interface{}][]byte) {
+	if self.idPos == nil {
+		self.setIDs(self.ids)
+	}
+	before := make(map[interface{}]bool, len(ids))
+	list := make([]interface{}, 0, self.idCount()+len(restored))
+	for _, id := range ids {
+		before[id] = true
+		if _, found := restored[id]; found {
+			list = append(list, id)
+		} else if _, found := self.idPos[id]; found {
+			list = append(list, id)
+		}
+	}
+	for _, id := range self.liveIDs() {
+		if !before[id] {
+			list = append(list, id)
+		}
+	}
+	for id := range restored {
+		if !before[id] {
+			list = append(list, id)
+		}
+	}
+	self.setIDs(list)
+}
+
+// sameRecord tells if a and b are the same record, not only equal ones
+func sameRecord(a, b []byte) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
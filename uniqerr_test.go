@@ -0,0 +1,33 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+func TestUniqueViolation(t *testing.T) {
+	h, _ := NewHandlerWithOptions(tmpdir(t), "c", WithUniqueFields("n"), WithUniqueCompositeFields([]string{"b", "a"}))
+	ctx := context.Background()
+	h.Insert(ctx, []*resource.Item{mkitem("x", map[string]interface{}{"n": 1, "a": 1, "b": 2})})
+	err := h.Insert(ctx, []*resource.Item{mkitem("y", map[string]interface{}{"n": 1})})
+	var e *rest.Error
+	if !errors.As(fmt.Errorf("wrapped: %w", err), &e) || e.Code != 422 {
+		t.Fatal(err)
+	}
+	if f, ok := UniqueViolation(fmt.Errorf("wrapped: %w", err)); !ok || !reflect.DeepEqual(f, []string{"n"}) {
+		t.Fatal(f, ok)
+	}
+	err = h.Insert(ctx, []*resource.Item{mkitem("y", map[string]interface{}{"a": 1, "b": 2})})
+	if f, ok := UniqueViolation(err); !ok || !reflect.DeepEqual(f, []string{"a", "b"}) {
+		t.Fatal(f, ok, err)
+	}
+	if _, ok := UniqueViolation(resource.ErrConflict); ok {
+		t.Fatal("conflict")
+	}
+}
@@ -0,0 +1,39 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestUniqueMissingField(t *testing.T) {
+	d := tmpdir(t)
+	if _, err := NewHandler(d, "c", []string{""}); err == nil {
+		t.Fatal("empty field accepted")
+	}
+	if _, err := NewHandler(d, "c", []string{"a.b"}); err == nil {
+		t.Fatal("nested field accepted")
+	}
+	if _, err := NewHandler(d, "c", []string{"a", "a"}); err == nil {
+		t.Fatal("duplicate accepted")
+	}
+	h, err := NewHandlerWithOptions(d, "c", WithUniqueFields("email"), WithUniqueCompositeFields([]string{"t", "n"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	ctx := context.Background()
+	for _, it := range []*resource.Item{
+		mkitem("1", map[string]interface{}{"x": 1, "t": 1}),
+		mkitem("2", map[string]interface{}{"x": 2, "t": 1}),
+		mkitem("3", map[string]interface{}{"email": nil, "t": 1, "n": 1}),
+	} {
+		if err := h.Insert(ctx, []*resource.Item{it}); err != nil {
+			t.Fatal(it.ID, err)
+		}
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("4", map[string]interface{}{"t": 1, "n": 1})}); err == nil {
+		t.Fatal("composite conflict not detected")
+	}
+}
@@ -0,0 +1,45 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+func TestCompositeUnique(t *testing.T) {
+	h := NewMemoryHandler(0)
+	h.UniqueCompositeFields = [][]string{{"tenant", "email"}}
+	ctx := context.Background()
+	if err := h.Insert(ctx, []*resource.Item{mkitem("a", map[string]interface{}{"tenant": 1, "email": "x"})}); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Insert(ctx, []*resource.Item{mkitem("b", map[string]interface{}{"tenant": 2, "email": "x"})}); err != nil {
+		t.Fatal(err)
+	}
+	err := h.Insert(ctx, []*resource.Item{mkitem("c", map[string]interface{}{"tenant": 1, "email": "x"})})
+	if e, ok := err.(*rest.Error); !ok || e.Code != 422 || e.Message != "Unique precondition failed on fields 'tenant', 'email'" {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateUnique(t *testing.T) {
+	h := NewMemoryHandler(0)
+	h.UniqueFields = []string{"email"}
+	ctx := context.Background()
+	a := mkitem("a", map[string]interface{}{"id": "a", "email": "a"})
+	b := mkitem("b", map[string]interface{}{"id": "b", "email": "b"})
+	if err := h.Insert(ctx, []*resource.Item{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	l, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+	orig := l.Items[1]
+	err := h.Update(ctx, mkitem("b", map[string]interface{}{"id": "b", "email": "a"}), orig)
+	if e, ok := err.(*rest.Error); !ok || e.Code != 422 {
+		t.Fatal(err)
+	}
+	if err := h.Update(ctx, mkitem("b", map[string]interface{}{"id": "b", "email": "b", "x": 1}), orig); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+package filestore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/schema"
+	"golang.org/x/net/context"
+)
+
+// uniqueHandler returns a memory handler holding n items with an email,
+// unique if unique is set
+func uniqueHandler(b *testing.B, n int, unique bool) *FileStoreHandler {
+	h := NewMemoryHandler(0)
+	if unique {
+		if err := h.SetUniqueFields(context.Background(), []string{"email"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	items := make([]*resource.Item, n)
+	for i := range items {
+		items[i] = mkitem(fmt.Sprint(i), map[string]interface{}{"email": fmt.Sprint(i, "@example.com")})
+	}
+	if err := h.Insert(context.Background(), items); err != nil {
+		b.Fatal(err)
+	}
+	return h
+}
+
+func BenchmarkInsertUniqueIndexed(b *testing.B) {
+	ctx := context.Background()
+	h := uniqueHandler(b, 10000, true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id := fmt.Sprint("new", i)
+		if err := h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{"email": id})}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkInsertUniqueScan checks the unique field with a find like Insert
+// did before the index
+func BenchmarkInsertUniqueScan(b *testing.B) {
+	ctx := context.Background()
+	h := uniqueHandler(b, 10000, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id := fmt.Sprint("new", i)
+		l := resource.NewLookup()
+		l.AddQuery(schema.Query{schema.Equal{Field: "email", Value: id}})
+		if n, err := h.Count(ctx, l); err != nil || n != 0 {
+			b.Fatal(n, err)
+		}
+		if err := h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{"email": id})}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
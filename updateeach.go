@@ -0,0 +1,94 @@
+package filestore
+
+import (
+	"reflect"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// UpdateEach calls fn with a copy of each item, in the handler's order, and
+// stores the item fn returns when it also returns true, with a new ETag. fn
+// may modify the item it gets and return it. The expired and soft deleted
+// items aren't passed to fn. The collection is persisted once at the end.
+//
+// The update is all or nothing: if fn fails, ctx is canceled, an item breaks a
+// unique constraint, its id is changed or the collection can't be persisted,
+// the items already updated are restored and the error returned. The unique
+// fields are checked one item at a time against the items as updated so far,
+// so two items can't swap a unique value.
+func (self *FileStoreHandler) UpdateEach(ctx context.Context, fn func(item *resource.Item) (*resource.Item, bool, error)) (updated int, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return 0, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		undo := self.beginUndo()
+		err := func() error {
+			for i, id := range self.snapshotIDs() {
+				if err := checkCanceled(ctx, i); err != nil {
+					return err
+				}
+				o, found, err := self.fetch(id)
+				if err != nil {
+					return err
+				}
+				if !found {
+					continue
+				}
+				original := canonicalID(o.Payload[self.idField()])
+				item, ok, err := fn(self.present(o))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+				if item == nil {
+					return &rest.Error{Code: 422, Message: "Invalid update: no item returned"}
+				}
+				self.canonicalizeIDs(item)
+				if item.ID != id || !reflect.DeepEqual(item.Payload[self.idField()], original) {
+					return &rest.Error{Code: 422, Message: "Invalid update: the id can't be changed"}
+				}
+				etag, err := ETag(item.Payload)
+				if err != nil {
+					return err
+				}
+				item = &resource.Item{ID: id, ETag: etag, Updated: self.now(), Payload: item.Payload}
+				invalid, err := self.checkUnique(ctx, item)
+				if err != nil {
+					return err
+				}
+				if invalid != nil {
+					return invalid
+				}
+				record, _, err := self.record(id)
+				if err != nil {
+					return err
+				}
+				if err := self.store(item); err != nil {
+					return err
+				}
+				undo.change(id, record)
+			}
+			if !undo.changed() {
+				return nil
+			}
+			self.sealUndo(undo)
+			return self.persistData()
+		}()
+		if err != nil && undo.changed() {
+			self.undo(undo)
+			return err
+		}
+		updated = len(undo.old)
+		return err
+	})
+	count(&self.counters.updates, updated)
+	return updated, err
+}
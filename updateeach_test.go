@@ -0,0 +1,62 @@
+package filestore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestUpdateEach(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithUniqueFields("name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{"name": "a"}), mkitem(2, map[string]interface{}{"name": "b"}), mkitem(3, map[string]interface{}{"name": "c", "v": 1})})
+	n, err := h.UpdateEach(ctx, func(item *resource.Item) (*resource.Item, bool, error) {
+		if _, found := item.Payload["v"]; found {
+			return nil, false, nil
+		}
+		item.Payload["v"] = 0
+		return item, true, nil
+	})
+	if err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+	// A unique violation restores everything
+	_, err = h.UpdateEach(ctx, func(item *resource.Item) (*resource.Item, bool, error) {
+		item.Payload["v"] = 5
+		if item.ID == 3 {
+			item.Payload["name"] = "a"
+		}
+		return item, true, nil
+	})
+	if _, ok := UniqueViolation(err); !ok {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+	if _, err := h.UpdateEach(ctx, func(item *resource.Item) (*resource.Item, bool, error) {
+		return nil, false, boom
+	}); err != boom {
+		t.Fatal(err)
+	}
+	h.Close()
+	h, _ = NewHandler(d, "c", []string{"name"})
+	defer h.Close()
+	l, _ := h.Find(ctx, resource.NewLookup(), 1, -1)
+	for _, it := range l.Items {
+		want := 0
+		if it.ID == 3 {
+			want = 1
+		}
+		if it.Payload["v"] != want {
+			t.Fatal(it)
+		}
+		if e, _ := ETag(it.Payload); e != it.ETag {
+			t.Fatal("etag", it)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package filestore
+
+import (
+	"fmt"
+
+	"github.com/rs/rest-layer/resource"
+	"github.com/rs/rest-layer/rest"
+	"golang.org/x/net/context"
+)
+
+// ItemUpdate is an update of UpdateMany: Item replaces the stored item with
+// the id of Original, whose ETag must match the stored item's one unless it
+// is empty, like for Update
+type ItemUpdate struct {
+	Item     *resource.Item
+	Original *resource.Item
+}
+
+// UpdateMany applies the updates and persists the collection once. The ETags
+// are all checked first: if one doesn't match, an error wrapping
+// resource.ErrConflict and naming the item is returned and nothing is updated.
+// The update is all or nothing: if an item isn't found, breaks a unique
+// constraint, ctx is canceled or the collection can't be persisted, the items
+// already updated are restored and the error returned.
+func (self *FileStoreHandler) UpdateMany(ctx context.Context, updates []ItemUpdate) (err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		seen := make(map[interface{}]bool, len(updates))
+		for i, u := range updates {
+			self.canonicalizeIDs(u.Item, u.Original)
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			if err := checkID(u.Original.ID); err != nil {
+				return err
+			}
+			if seen[u.Original.ID] {
+				return &rest.Error{Code: 422, Message: fmt.Sprintf("Invalid update: item %v updated twice", u.Original.ID)}
+			}
+			seen[u.Original.ID] = true
+			if u.Item.ID != u.Original.ID {
+				return &rest.Error{Code: 422, Message: "Invalid update: the id can't be changed"}
+			}
+			o, found, err := self.peek(u.Original.ID)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return fmt.Errorf("%w: item %v", resource.ErrNotFound, u.Original.ID)
+			}
+			if u.Original.ETag != "" && u.Original.ETag != o.ETag {
+				return fmt.Errorf("%w: item %v", resource.ErrConflict, u.Original.ID)
+			}
+		}
+		undo := self.beginUndo()
+		err := func() error {
+			for i, u := range updates {
+				if err := checkCanceled(ctx, i); err != nil {
+					return err
+				}
+				invalid, err := self.checkUnique(ctx, u.Item)
+				if err != nil {
+					return err
+				}
+				if invalid != nil {
+					return invalid
+				}
+				record, _, err := self.record(u.Item.ID)
+				if err != nil {
+					return err
+				}
+				if err := self.store(u.Item); err != nil {
+					return err
+				}
+				undo.change(u.Item.ID, record)
+			}
+			if !undo.changed() {
+				return nil
+			}
+			self.sealUndo(undo)
+			return self.persistData()
+		}()
+		if err != nil && undo.changed() {
+			self.undo(undo)
+		}
+		return err
+	})
+	if err == nil {
+		count(&self.counters.updates, len(updates))
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package filestore
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestUpdateMany(t *testing.T) {
+	h, err := NewHandlerWithOptions(tmpdir(t), "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	a, b := mkitem(1, map[string]interface{}{"v": 1}), mkitem(2, map[string]interface{}{"v": 2})
+	if err := h.Insert(ctx, []*resource.Item{a, b}); err != nil {
+		t.Fatal(err)
+	}
+	up := func(o *resource.Item, v int) ItemUpdate {
+		n := mkitem(o.ID, map[string]interface{}{"v": v})
+		return ItemUpdate{Item: n, Original: o}
+	}
+	if err := h.UpdateMany(ctx, []ItemUpdate{up(a, 10), up(b, 20)}); err != nil {
+		t.Fatal(err)
+	}
+	ga, _ := h.MultiGet(ctx, []interface{}{1, 2})
+	if ga[0].Payload["v"] != 10 || ga[1].Payload["v"] != 20 {
+		t.Fatalf("%v %v", ga[0].Payload, ga[1].Payload)
+	}
+	// a is stale now
+	err = h.UpdateMany(ctx, []ItemUpdate{up(ga[1], 200), up(a, 100)})
+	if !errors.Is(err, resource.ErrConflict) || !strings.Contains(err.Error(), "item 1") {
+		t.Fatalf("got %v", err)
+	}
+	gb, _ := h.MultiGet(ctx, []interface{}{1, 2})
+	if gb[0].Payload["v"] != 10 || gb[1].Payload["v"] != 20 {
+		t.Fatalf("%v %v", gb[0].Payload, gb[1].Payload)
+	}
+}
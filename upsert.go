@@ -0,0 +1,86 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// Upsert stores item whether or not an item with its id exists: it is
+// inserted if there's none and replaces it otherwise, and created tells
+// which. If expectedETag is set, the stored item must have this ETag, the
+// upsert fails with resource.ErrConflict otherwise, including when there's no
+// such item. An item without an id gets one from IDGenerator or AutoIncrement.
+// The unique fields are checked against the other items and the collection is
+// persisted once, the stored item is left untouched if it can't be.
+func (self *FileStoreHandler) Upsert(ctx context.Context, item *resource.Item, expectedETag string) (created bool, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkWritable(); err != nil {
+		return false, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		undo := self.beginUndo()
+		self.canonicalizeIDs(item)
+		if err := self.generateIDs([]*resource.Item{item}); err != nil {
+			return err
+		}
+		if err := checkID(item.ID); err != nil {
+			return err
+		}
+		if isZeroID(item.ID) {
+			return ErrMissingID
+		}
+		o, found, err := self.peek(item.ID)
+		if err != nil {
+			return err
+		}
+		if expectedETag != "" && (!found || o.ETag != expectedETag) {
+			return resource.ErrConflict
+		}
+		invalid, err := self.checkUnique(ctx, item)
+		if err != nil {
+			return err
+		}
+		if invalid != nil {
+			return invalid
+		}
+		// An expired or soft deleted item is replaced in place
+		record, stored, err := self.record(item.ID)
+		if err != nil {
+			return err
+		}
+		staged, encoded, err := self.encode(item)
+		if err != nil {
+			return err
+		}
+		added, grow := 1, recordSize(encoded)
+		if stored {
+			added, grow = 0, grow-recordSize(record)
+		}
+		if err := self.makeRoom(added, grow, map[interface{}]bool{item.ID: true}); err != nil {
+			return err
+		}
+		self.storeRecord(staged, encoded)
+		undo.change(item.ID, record)
+		if !stored {
+			self.appendID(item.ID)
+		}
+		self.sealUndo(undo)
+		if err := self.persistData(); err != nil {
+			self.undo(undo)
+			return err
+		}
+		created = !found
+		return nil
+	})
+	if err == nil {
+		if created {
+			count(&self.counters.inserts, 1)
+		} else {
+			count(&self.counters.updates, 1)
+		}
+	}
+	return created, err
+}
@@ -0,0 +1,46 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestUpsert(t *testing.T) {
+	dir := tmpdir(t)
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(dir, "c", WithUniqueFields("email"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &resource.Item{ID: "a", ETag: "e1", Payload: map[string]interface{}{"id": "a", "email": "a@x"}}
+	if created, err := h.Upsert(ctx, a, ""); err != nil || !created {
+		t.Fatal(created, err)
+	}
+	// Replaced, keeping its own unique value
+	a2 := &resource.Item{ID: "a", ETag: "e2", Payload: map[string]interface{}{"id": "a", "email": "a@x", "n": 2}}
+	if created, err := h.Upsert(ctx, a2, "e1"); err != nil || created {
+		t.Fatal(created, err)
+	}
+	if _, err := h.Upsert(ctx, &resource.Item{ID: "a", ETag: "e3", Payload: map[string]interface{}{"id": "a"}}, "e1"); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+	if _, err := h.Upsert(ctx, &resource.Item{ID: "z", ETag: "e3", Payload: map[string]interface{}{"id": "z"}}, "e1"); err != resource.ErrConflict {
+		t.Fatal(err)
+	}
+	b := &resource.Item{ID: "b", ETag: "e1", Payload: map[string]interface{}{"id": "b", "email": "a@x"}}
+	if _, err := h.Upsert(ctx, b, ""); err == nil {
+		t.Fatal("unique")
+	}
+	if h.Len() != 1 {
+		t.Fatal(h.Len())
+	}
+	h.Close()
+	h, _ = NewHandlerWithOptions(dir, "c")
+	defer h.Close()
+	item, err := h.Get(ctx, "a")
+	if err != nil || item.ETag != "e2" || item.Payload["n"] != 2 {
+		t.Fatal(item, err)
+	}
+}
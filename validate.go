@@ -0,0 +1,67 @@
+package filestore
+
+import (
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// ValidateInsert runs the Insert validation on items without persisting
+// anything. The returned slice holds the validation error of each item at its
+// position in items (nil if the item would insert cleanly) while err reports a
+// problem preventing the validation itself. Once each item is valid, the
+// checks of Insert on the whole batch are run, see checkBatch: as Insert
+// rejects the batch as a whole, their error is set for all the items.
+func (self *FileStoreHandler) ValidateInsert(ctx context.Context, items []*resource.Item) (errs []error, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		errs = make([]error, len(items))
+		seen := map[interface{}]bool{}
+		self.canonicalizeIDs(items...)
+		staged := make([]*resource.Item, len(items))
+		records := make([][]byte, len(items))
+		valid := true
+		for i, item := range items {
+			if errs[i] = checkID(item.ID); errs[i] != nil {
+				valid = false
+				continue
+			}
+			// Items lacking an id get a unique generated one on insert
+			if seen[item.ID] && !isZeroID(item.ID) {
+				errs[i] = resource.ErrConflict
+				valid = false
+				continue
+			}
+			seen[item.ID] = true
+			invalid, err := self.validateInsert(ctx, item)
+			if err != nil {
+				return err
+			}
+			if invalid == nil {
+				// Without writing the blobs
+				staged[i], records[i], invalid = self.encodeWith(item, self.previewRecord)
+			}
+			if errs[i] = invalid; invalid != nil {
+				valid = false
+			}
+		}
+		if !valid {
+			return nil
+		}
+		if _, _, _, invalid := self.checkBatch(staged, records); invalid != nil {
+			for i := range errs {
+				errs[i] = invalid
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
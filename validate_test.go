@@ -0,0 +1,63 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestValidateInsertMatchesInsert(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		name  string
+		opts  []Option
+		items func() []*resource.Item
+		// setup runs once the handler holds an item
+		setup func(h *FileStoreHandler)
+	}{
+		{"unique within the batch", []Option{WithUniqueFields("email")}, func() []*resource.Item {
+			return []*resource.Item{
+				mkitem("a", map[string]interface{}{"email": "x@y"}),
+				mkitem("b", map[string]interface{}{"email": "x@y"}),
+			}
+		}, nil},
+		{"item limit", []Option{WithMaxItems(1, EvictNone)}, func() []*resource.Item {
+			return []*resource.Item{mkitem("a", map[string]interface{}{}), mkitem("b", map[string]interface{}{})}
+		}, nil},
+		{"evicting", []Option{WithMaxItems(2, EvictOldest)}, func() []*resource.Item {
+			return []*resource.Item{mkitem("a", map[string]interface{}{}), mkitem("b", map[string]interface{}{})}
+		}, nil},
+		{"memory limit", nil, func() []*resource.Item {
+			return []*resource.Item{mkitem("a", map[string]interface{}{}), mkitem("b", map[string]interface{}{})}
+		}, func(h *FileStoreHandler) {
+			// Room for a single other item
+			h.MaxMemoryBytes = h.MemoryBytes() * 2
+		}},
+		{"valid", nil, func() []*resource.Item {
+			return []*resource.Item{mkitem("a", map[string]interface{}{}), mkitem("b", map[string]interface{}{})}
+		}, nil},
+	} {
+		h, err := NewHandlerWithOptions(tmpdir(t), "c", c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Insert(ctx, []*resource.Item{mkitem("z", map[string]interface{}{})}); err != nil {
+			t.Fatal(c.name, err)
+		}
+		if c.setup != nil {
+			c.setup(h)
+		}
+		errs, err := h.ValidateInsert(ctx, c.items())
+		if err != nil {
+			t.Fatal(c.name, err)
+		}
+		want := h.Insert(ctx, c.items())
+		for i, e := range errs {
+			if (e == nil) != (want == nil) || e != nil && e.Error() != want.Error() {
+				t.Errorf("%s: item %d validated with %v, inserted with %v", c.name, i, e, want)
+			}
+		}
+		h.Close()
+	}
+}
@@ -0,0 +1,155 @@
+package filestore
+
+import (
+	"fmt"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// ProblemKind is the kind of inconsistency of a Problem
+type ProblemKind int
+
+const (
+	// ProblemOrphanID is an id of the ids list without a record
+	ProblemOrphanID ProblemKind = iota + 1
+	// ProblemDuplicateID is an id listed more than once
+	ProblemDuplicateID
+	// ProblemUnlistedRecord is a record whose id isn't in the ids list, it
+	// is never returned by Find
+	ProblemUnlistedRecord
+	// ProblemUndecodable is a record which can't be decoded
+	ProblemUndecodable
+	// ProblemMissingETag is an item without an ETag
+	ProblemMissingETag
+	// ProblemDuplicateValue is a value of a unique field held by several
+	// items
+	ProblemDuplicateValue
+)
+
+// Problem is an inconsistency of the stored data reported by Verify
+type Problem struct {
+	Kind ProblemKind
+	// ID is the id at fault, the first item holding the value of a
+	// ProblemDuplicateValue
+	ID interface{}
+	// Field, Value and IDs are set for a ProblemDuplicateValue: the unique
+	// field, its value and the ids of all the items holding it
+	Field string
+	Value interface{}
+	IDs   []interface{}
+	// Err is the decoding error of a ProblemUndecodable
+	Err error
+}
+
+// String describes the problem
+func (p Problem) String() string {
+	switch p.Kind {
+	case ProblemOrphanID:
+		return fmt.Sprintf("id %v has no record", p.ID)
+	case ProblemDuplicateID:
+		return fmt.Sprintf("id %v is listed more than once", p.ID)
+	case ProblemUnlistedRecord:
+		return fmt.Sprintf("record %v isn't listed", p.ID)
+	case ProblemUndecodable:
+		return fmt.Sprintf("record %v can't be decoded: %v", p.ID, p.Err)
+	case ProblemMissingETag:
+		return fmt.Sprintf("item %v has no ETag", p.ID)
+	case ProblemDuplicateValue:
+		return fmt.Sprintf("unique field '%s' holds %v for items %v", p.Field, p.Value, p.IDs)
+	}
+	return fmt.Sprintf("problem %d with %v", p.Kind, p.ID)
+}
+
+// Verify checks the stored data is consistent: every listed id has a record
+// and every record is listed, the records decode, the items have an ETag and
+// the UniqueFields hold unique values. It changes nothing and returns the
+// problems found, mostly in the ids order; the error only reports that the
+// check itself couldn't complete. The records are decoded again, not read
+// from the cache, and the unique values are checked against the items, not
+// the indexes.
+func (self *FileStoreHandler) Verify(ctx context.Context) (problems []Problem, err error) {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.RLock()
+	defer self.RUnlock()
+	if err := self.checkOpen(); err != nil {
+		return nil, err
+	}
+	err = handleWithLatency(self.Latency, ctx, func() error {
+		problems, err = self.verify(ctx)
+		return err
+	})
+	return problems, err
+}
+
+func (self *FileStoreHandler) verify(ctx context.Context) ([]Problem, error) {
+	var problems []Problem
+	indexes := make([]*fieldIndex, len(self.UniqueFields))
+	for i, field := range self.UniqueFields {
+		indexes[i] = self.newFieldIndex(field)
+	}
+	// The order the unique values were first met in, to report them in the
+	// ids order
+	keys := make([][]interface{}, len(self.UniqueFields))
+
+	listed := make(map[interface{}]bool, len(self.ids))
+	for i, id := range self.ids {
+		if err := checkCanceled(ctx, i); err != nil {
+			return nil, err
+		}
+		if _, hole := id.(holeID); hole {
+			continue
+		}
+		if listed[id] {
+			problems = append(problems, Problem{Kind: ProblemDuplicateID, ID: id})
+			continue
+		}
+		listed[id] = true
+		data, found, err := self.record(id)
+		if !found {
+			problems = append(problems, Problem{Kind: ProblemOrphanID, ID: id})
+			continue
+		}
+		var item resource.Item
+		if err == nil {
+			err = decodeRecord(data, &item)
+		}
+		if err != nil {
+			problems = append(problems, Problem{Kind: ProblemUndecodable, ID: id, Err: err})
+			continue
+		}
+		if item.ETag == "" {
+			problems = append(problems, Problem{Kind: ProblemMissingETag, ID: id})
+		}
+		for f, field := range self.UniqueFields {
+			value, found := item.Payload[field]
+			if !found {
+				continue
+			}
+			idx := indexes[f]
+			if len(idx.lookup(value)) == 0 {
+				keys[f] = append(keys[f], value)
+			}
+			idx.add(id, item.Payload, field)
+		}
+	}
+	var unlisted []interface{}
+	for id := range self.items {
+		if !listed[id] {
+			unlisted = append(unlisted, id)
+		}
+	}
+	sortIDs(unlisted)
+	for _, id := range unlisted {
+		problems = append(problems, Problem{Kind: ProblemUnlistedRecord, ID: id})
+	}
+	for f, field := range self.UniqueFields {
+		for _, value := range keys[f] {
+			if ids := indexes[f].lookup(value); len(ids) > 1 {
+				problems = append(problems, Problem{Kind: ProblemDuplicateValue, ID: ids[0], Field: field, Value: value, IDs: ids})
+			}
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,41 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(tmpdir(t), "c", WithUniqueFields("name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	h.Insert(ctx, []*resource.Item{mkitem(1, map[string]interface{}{"name": "a"}), mkitem(2, map[string]interface{}{"name": "b"}), mkitem(3, map[string]interface{}{"name": "c"})})
+	if p, err := h.Verify(ctx); err != nil || len(p) != 0 {
+		t.Fatal(p, err)
+	}
+	// Seed inconsistencies
+	h.ids = append(h.ids, 9, 1)
+	h.items[7] = h.items[1]
+	h.items[2] = []byte{0xff, 0x01}
+	h.cache.invalidate(2)
+	b, _ := h.encodeRecord(&resource.Item{ID: 4, Payload: map[string]interface{}{"id": 4, "name": "a"}})
+	h.items[4] = b
+	h.ids = append(h.ids, 4)
+	p, err := h.Verify(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[ProblemKind]int{}
+	for _, pb := range p {
+		kinds[pb.Kind]++
+		t.Log(pb)
+	}
+	if kinds[ProblemOrphanID] != 1 || kinds[ProblemDuplicateID] != 1 || kinds[ProblemUnlistedRecord] != 1 || kinds[ProblemUndecodable] != 1 || kinds[ProblemMissingETag] != 1 || kinds[ProblemDuplicateValue] != 1 {
+		t.Fatal(p)
+	}
+}
@@ -0,0 +1,80 @@
+package filestore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// ErrUnsupportedVersion is returned, wrapped, when reading a datafile written
+// in a format version newer than the one of this package
+var ErrUnsupportedVersion = errors.New("filestore: unsupported datafile format version")
+
+// ErrChecksum is returned, wrapped, when the content of a datafile doesn't
+// match its checksum, from a truncation or a corruption on disk
+var ErrChecksum = errors.New("filestore: datafile checksum mismatch")
+
+// datafileMagic starts the header of the datafiles, followed by the format
+// version byte. Its first byte is in the range of the tags, so it can't be
+// mistaken for the start of a legacy datafile.
+var datafileMagic = []byte("\xc0RLF")
+
+// DatafileVersion is the version of the datafile format written by this
+// package. A datafile without a header is of version 0, it is read and
+// rewritten with the current version when the handler opens it.
+//
+// Since version 2 the version byte is followed by the CRC-32 of the rest of
+// the datafile, checked before anything is decoded.
+const DatafileVersion = 2
+
+// checksumVersion is the first version storing the checksum of the content
+const checksumVersion = 2
+
+// addHeader prepends the header of the current version to an encoded
+// datafile
+func addHeader(data []byte) []byte {
+	header := make([]byte, len(datafileMagic)+5, len(datafileMagic)+5+len(data))
+	copy(header, datafileMagic)
+	header[len(datafileMagic)] = DatafileVersion
+	binary.BigEndian.PutUint32(header[len(datafileMagic)+1:], crc32.ChecksumIEEE(data))
+	return append(header, data...)
+}
+
+// splitHeader returns the format version of an encoded datafile and its
+// content following the header. A datafile of a version newer than this
+// package knows or whose content doesn't match its checksum is rejected.
+func splitHeader(data []byte) (version int, content []byte, err error) {
+	if !bytes.HasPrefix(data, datafileMagic) {
+		return 0, data, nil
+	}
+	if len(data) == len(datafileMagic) {
+		return 0, nil, fmt.Errorf("filestore: corrupted datafile, truncated header")
+	}
+	version = int(data[len(datafileMagic)])
+	if version > DatafileVersion {
+		return version, nil, fmt.Errorf("%w %d, the newest supported is %d", ErrUnsupportedVersion, version, DatafileVersion)
+	}
+	content = data[len(datafileMagic)+1:]
+	if version < checksumVersion {
+		return version, content, nil
+	}
+	if len(content) < 4 {
+		return version, nil, fmt.Errorf("filestore: corrupted datafile, truncated header")
+	}
+	if sum := crc32.ChecksumIEEE(content[4:]); sum != binary.BigEndian.Uint32(content) {
+		return version, nil, fmt.Errorf("%w, got %#08x, expected %#08x", ErrChecksum, sum, binary.BigEndian.Uint32(content))
+	}
+	return version, content[4:], nil
+}
+
+// openDatafile checks the header of an encoded datafile and returns its
+// decrypted content
+func (self *FileStoreHandler) openDatafile(data []byte) ([]byte, error) {
+	_, content, err := splitHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	return self.decrypt(content)
+}
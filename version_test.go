@@ -0,0 +1,40 @@
+package filestore
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestVersionHeader(t *testing.T) {
+	d := tmpdir(t)
+	p := filepath.Join(d, "c")
+	h := newH(t, d, "c", nil)
+	h.Insert(context.Background(), []*resource.Item{mkitem("a", map[string]interface{}{"x": 1})})
+	h.Close()
+	data, _ := ioutil.ReadFile(p)
+	if !bytes.HasPrefix(data, append(datafileMagic, DatafileVersion)) {
+		t.Fatal("no header")
+	}
+	// legacy: strip the header, expect migration in place
+	ioutil.WriteFile(p, data[len(datafileMagic)+5:], 0644)
+	h = newH(t, d, "c", nil)
+	if n, _ := h.Count(context.Background(), resource.NewLookup()); n != 1 {
+		t.Fatal(n)
+	}
+	h.Close()
+	data, _ = ioutil.ReadFile(p)
+	if !bytes.HasPrefix(data, datafileMagic) {
+		t.Fatal("not migrated")
+	}
+	data[len(datafileMagic)] = DatafileVersion + 1
+	ioutil.WriteFile(p, data, 0644)
+	if _, err := NewHandlerWithOptions(d, "c", WithRecoverFromCorruption()); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,250 @@
+package filestore
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// With WAL set, a write doesn't rewrite the datafile: the changes it made to
+// the records and to the ids list are appended to a write-ahead log next to
+// the datafile and synced, which costs the size of the changes instead of the
+// size of the collection. The datafile is only rewritten, and the log
+// truncated, when the log holds WALCompactSize changes, on Close and by the
+// operations rewriting the whole collection like Compact or Rekey. When the
+// handler is created, the log is replayed over the datafile it follows and
+// the datafile rewritten.
+//
+// The log is a sequence of frames, each holding the changes persisted by one
+// write along with the checksum of the datafile they follow, so a log left
+// over by a crash right after the datafile was rewritten is ignored. A torn
+// frame at the end of the log, from a crash in the middle of an append, is
+// dropped. The WAL isn't used with FlushInterval, MergeOnSave or
+// PerItemFiles, nor by the memory handlers or on another FileSystem.
+
+// DefaultWALCompactSize is the number of logged changes after which the
+// datafile is rewritten when WALCompactSize isn't set
+const DefaultWALCompactSize = 10000
+
+// Operations of the write-ahead log
+const (
+	// walPut sets the record of an id
+	walPut byte = iota + 1
+	// walDelete removes the record of an id
+	walDelete
+	// walAppend adds an id at the end of the ids list
+	walAppend
+	// walRemove removes an id from the ids list
+	walRemove
+)
+
+// walOp is a change of the records or of the ids list
+type walOp struct {
+	Op     byte
+	ID     interface{}
+	Record []byte
+}
+
+// walFrame is the content of a frame of the log
+type walFrame struct {
+	// Base is the checksum of the datafile the changes follow
+	Base [sha256.Size]byte
+	Ops  []walOp
+}
+
+// walState tracks the changes to append to the log
+type walState struct {
+	// pending are the changes made since the last append
+	pending []walOp
+	// full is set when the memory was changed in a way the log can't
+	// express, the next persist must rewrite the datafile
+	full bool
+	// size is the number of changes in the log and base the checksum of the
+	// datafile it follows
+	size int
+	base [sha256.Size]byte
+	// onDisk is set when the log file may exist
+	onDisk bool
+}
+
+func (self *FileStoreHandler) walFile() string {
+	return self.database_file + ".wal"
+}
+
+// walEnabled tells if the writes are persisted to the log
+func (self *FileStoreHandler) walEnabled() bool {
+	return self.WAL && !self.inMemory() && self.FileSystem == nil && !self.PerItemFiles && !self.MergeOnSave && self.FlushInterval == 0
+}
+
+// logWAL records a change to append to the log by the next persist
+func (self *FileStoreHandler) logWAL(op byte, id interface{}, record []byte) {
+	if !self.walEnabled() || self.wal.full {
+		return
+	}
+	self.wal.pending = append(self.wal.pending, walOp{Op: op, ID: id, Record: record})
+}
+
+// truncateWAL removes the log once the datafile content data holding all its
+// changes is written. A log which can't be removed is ignored by the next
+// replay as it doesn't follow data.
+func (self *FileStoreHandler) truncateWAL(data []byte) {
+	if !self.walEnabled() && !self.wal.onDisk {
+		return
+	}
+	if err := os.Remove(self.walFile()); err != nil && !os.IsNotExist(err) {
+		self.logf("Error removing the log of database %s: %v", self.database_file, err)
+	}
+	self.wal = walState{base: sha256.Sum256(data)}
+}
+
+// appendWAL persists the pending changes by appending them to the log, or by
+// rewriting the datafile if the log is full or can't express them
+func (self *FileStoreHandler) appendWAL() error {
+	compactSize := self.WALCompactSize
+	if compactSize <= 0 {
+		compactSize = DefaultWALCompactSize
+	}
+	if self.wal.full || self.wal.size+len(self.wal.pending) > compactSize {
+		return self.saveDatafile()
+	}
+	appended := len(self.wal.pending) > 0
+	if appended {
+		content, err := self.serialize(&walFrame{Base: self.wal.base, Ops: self.wal.pending})
+		if err == nil {
+			content, err = self.encrypt(content)
+		}
+		if err == nil {
+			// The syncer syncs the frames of a batch at once
+			err = appendFrame(self.walFile(), content, self.fileMode(), self.Durable && !self.groupSyncing())
+		}
+		if err != nil {
+			return err
+		}
+		self.wal.size += len(self.wal.pending)
+		self.wal.onDisk = true
+		self.debugf("Logged %d changes of database %s", len(self.wal.pending), self.database_file)
+		self.wal.pending = nil
+	}
+	self.removeGarbageBlobs()
+	self.lastSave = self.now()
+	self.dirty = false
+	if appended && self.groupSyncing() {
+		// The events are published once synced
+		return self.groupSync()
+	}
+	self.publish()
+	return nil
+}
+
+// appendFrame appends content to the log at path, prefixed by its length and
+// CRC-32, and syncs it if durable is set. The log is created with perm. If the
+// append fails, on a full disk for instance, the log is cut back to its size
+// before the append: the part of the frame written would otherwise be taken
+// for a torn end and drop the frames appended after it.
+func appendFrame(path string, content []byte, perm os.FileMode, durable bool) error {
+	frame := make([]byte, 8, 8+len(content))
+	binary.BigEndian.PutUint32(frame, uint32(len(content)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(content))
+	frame = append(frame, content...)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, perm)
+	if err != nil {
+		return err
+	}
+	end, err := f.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = f.Write(frame)
+		if err == nil && durable {
+			err = f.Sync()
+		}
+		if err != nil {
+			f.Truncate(end)
+		}
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// replayWAL applies the log following the loaded datafile content data, nil
+// if there's no datafile, over the loaded items. It returns the number of
+// changes replayed.
+func (self *FileStoreHandler) replayWAL(data []byte) (int, error) {
+	var base [sha256.Size]byte
+	var log []byte
+	err := os.ErrNotExist
+	if self.FileSystem == nil {
+		log, err = ioutil.ReadFile(self.walFile())
+	}
+	if os.IsNotExist(err) {
+		if data != nil && self.walEnabled() {
+			base = sha256.Sum256(data)
+		}
+		self.wal = walState{base: base}
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if data != nil {
+		base = sha256.Sum256(data)
+	}
+	replayed := 0
+	for len(log) > 0 {
+		if len(log) < 8 || len(log)-8 < int(binary.BigEndian.Uint32(log)) {
+			self.logf("Warning: dropping the torn end of the log of database %s", self.database_file)
+			break
+		}
+		content := log[8 : 8+binary.BigEndian.Uint32(log)]
+		if crc32.ChecksumIEEE(content) != binary.BigEndian.Uint32(log[4:]) {
+			self.logf("Warning: dropping the torn end of the log of database %s", self.database_file)
+			break
+		}
+		log = log[8+len(content):]
+		content, err := self.decrypt(content)
+		if err != nil {
+			return replayed, err
+		}
+		var frame walFrame
+		if err := gobDecode(content, &frame); err != nil {
+			return replayed, err
+		}
+		if frame.Base != base {
+			// Written before the datafile was last rewritten, the datafile
+			// already holds these changes
+			continue
+		}
+		for _, op := range frame.Ops {
+			self.applyWALOp(op)
+		}
+		replayed += len(frame.Ops)
+	}
+	if replayed > 0 {
+		self.resetChanges()
+		if err := self.rebuildIndexes(); err != nil {
+			return replayed, err
+		}
+	}
+	self.wal = walState{base: base, size: replayed, onDisk: true}
+	self.debugf("Replayed %d changes of the log of database %s", replayed, self.database_file)
+	return replayed, nil
+}
+
+func (self *FileStoreHandler) applyWALOp(op walOp) {
+	op.ID = canonicalID(op.ID)
+	switch op.Op {
+	case walPut:
+		self.setRecord(op.ID, op.Record)
+		self.advanceSequence(op.ID)
+	case walDelete:
+		self.removeRecord(op.ID)
+	case walAppend:
+		if _, found := self.idPos[op.ID]; !found {
+			self.appendID(op.ID)
+		}
+	case walRemove:
+		self.removeID(op.ID)
+	}
+}
@@ -0,0 +1,96 @@
+package filestore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// crashCopy copies the files of directory d as they are right now
+func crashCopy(t *testing.T, d string) string {
+	c := tmpdir(t)
+	files, _ := ioutil.ReadDir(d)
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(d, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.WriteFile(filepath.Join(c, f.Name()), data, 0644)
+	}
+	return c
+}
+
+func TestWALRecovery(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h, err := NewHandlerWithOptions(d, "c", WithWAL(), WithUniqueFields("n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{"n": i})}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(d, "c")); !os.IsNotExist(err) {
+		t.Fatal("datafile written", err)
+	}
+	h.Delete(ctx, mkitem("3", map[string]interface{}{"n": 3}))
+	h.Merge(ctx, "4", map[string]interface{}{"n": 40}, "")
+	c := crashCopy(t, d)
+	// Torn append
+	f, _ := os.OpenFile(filepath.Join(c, "c.wal"), os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{0, 0, 1, 0, 1, 2})
+	f.Close()
+
+	r, err := NewHandlerWithOptions(c, "c", WithWAL(), WithUniqueFields("n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.idCount() != 9 {
+		t.Fatal(r.liveIDs())
+	}
+	if got, _ := r.Get(ctx, "4"); got.Payload["n"] != 40 {
+		t.Fatal(got)
+	}
+	if ids := r.liveIDs(); ids[3] != "4" || ids[8] != "9" {
+		t.Fatal(ids)
+	}
+	if _, err := os.Stat(filepath.Join(c, "c.wal")); !os.IsNotExist(err) {
+		t.Fatal("wal not truncated", err)
+	}
+	if err := r.Insert(ctx, []*resource.Item{mkitem("x", map[string]interface{}{"n": 40})}); err == nil {
+		t.Fatal("index not rebuilt")
+	}
+	r.Close()
+
+	// A stale log following an older datafile is ignored
+	h.Close()
+	c = crashCopy(t, d)
+	h, _ = NewHandlerWithOptions(d, "c", WithWAL())
+	h.Insert(ctx, []*resource.Item{mkitem("y", map[string]interface{}{})})
+	wal, _ := ioutil.ReadFile(filepath.Join(d, "c.wal"))
+	h.Insert(ctx, []*resource.Item{mkitem("z", map[string]interface{}{})})
+	h.Close()
+	ioutil.WriteFile(filepath.Join(d, "c.wal"), wal, 0644)
+	h, _ = NewHandlerWithOptions(d, "c", WithWAL())
+	if h.idCount() != 11 {
+		t.Fatal(h.liveIDs())
+	}
+	h.Close()
+
+	// Compaction
+	h, _ = NewHandlerWithOptions(tmpdir(t), "c", WithWAL(), func(f *FileStoreHandler) { f.WALCompactSize = 5 })
+	for i := 0; i < 4; i++ {
+		h.Insert(ctx, []*resource.Item{mkitem(fmt.Sprint(i), map[string]interface{}{})})
+	}
+	if h.wal.size > 5 || h.wal.size == 0 {
+		t.Fatal(h.wal.size)
+	}
+	h.Close()
+}
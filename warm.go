@@ -0,0 +1,36 @@
+package filestore
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// Warm decodes all the stored items into the cache up front, so the decode
+// cost is paid once at startup rather than by the first reads. The first item
+// which can't be decoded fails it, unless SkipCorrupt is set in which case it
+// is logged and counted like by the scans. Nothing is cached with
+// OffloadRecords, Warm does nothing then.
+func (self *FileStoreHandler) Warm(ctx context.Context) error {
+	ctx, cancel := self.opContext(ctx)
+	defer cancel()
+	self.Lock()
+	defer self.Unlock()
+	if err := self.checkOpen(); err != nil {
+		return err
+	}
+	return handleWithLatency(self.Latency, ctx, func() error {
+		if self.offloading() {
+			return nil
+		}
+		for i, id := range self.liveIDs() {
+			if err := checkCanceled(ctx, i); err != nil {
+				return err
+			}
+			if _, _, err := self.decodeValid(id); err != nil {
+				return fmt.Errorf("filestore: can't decode item %v: %v", id, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package filestore
+
+import (
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+func TestWarm(t *testing.T) {
+	dir := tmpdir(t)
+	ctx := context.Background()
+	h, err := NewHandlerWithOptions(dir, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []*resource.Item
+	for i := 0; i < 20; i++ {
+		items = append(items, mkitem(i+1, map[string]interface{}{"n": i}))
+	}
+	h.Insert(ctx, items)
+	h.Close()
+	h, _ = NewHandlerWithOptions(dir, "c")
+	defer h.Close()
+	h.cache.items = nil
+	if err := h.Warm(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.cache.items) != 20 {
+		t.Fatal(len(h.cache.items))
+	}
+	// Break the records: a find must not decode them anymore
+	for id := range h.items {
+		h.items[id] = []byte("garbage")
+	}
+	list, err := h.Find(ctx, resource.NewLookup(), 1, -1)
+	if err != nil || len(list.Items) != 20 {
+		t.Fatal(list, err)
+	}
+	h.cache.items = nil
+	if err := h.Warm(ctx); err == nil {
+		t.Fatal("decode error not reported")
+	}
+	h.SkipCorrupt = true
+	if err := h.Warm(ctx); err != nil || h.Stats().Corrupt != 20 {
+		t.Fatal(err, h.Stats().Corrupt)
+	}
+	ctx2, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := h.Warm(ctx2); err == nil {
+		t.Fatal("not canceled")
+	}
+}
@@ -0,0 +1,60 @@
+package filestore
+
+import (
+	"encoding/gob"
+	"testing"
+
+	"github.com/rs/rest-layer/resource"
+	"golang.org/x/net/context"
+)
+
+// testUUID is an id type like the UUIDs of the uuid packages
+type testUUID [16]byte
+
+func init() {
+	gob.Register(testUUID{})
+}
+
+func TestZeroIDs(t *testing.T) {
+	ctx := context.Background()
+	d := tmpdir(t)
+	h := newH(t, d, "c", nil)
+	for _, id := range []interface{}{nil, "", 0, testUUID{}} {
+		if err := h.Insert(ctx, []*resource.Item{{ID: id, Payload: map[string]interface{}{"id": id}}}); err != ErrMissingID {
+			t.Fatalf("%#v: %v", id, err)
+		}
+	}
+	ids := []interface{}{"a", 1, testUUID{1}}
+	for _, id := range ids {
+		if err := h.Insert(ctx, []*resource.Item{mkitem(id, map[string]interface{}{})}); err != nil {
+			t.Fatalf("%#v: %v", id, err)
+		}
+	}
+	// The ids match by type
+	if item, _ := h.Get(ctx, "1"); item != nil {
+		t.Fatal("string id matching an int one", item)
+	}
+	h = newH(t, d, "c", nil)
+	for _, id := range ids {
+		item, found, err := h.fetch(id)
+		if !found || err != nil || item.ID != id {
+			t.Fatalf("%#v: %v %v %v", id, item, found, err)
+		}
+		if err := h.Delete(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h.Len() != 0 {
+		t.Fatal(h.IDs())
+	}
+}
+
+func TestIDGeneratorCalled(t *testing.T) {
+	h := NewMemoryHandler(0)
+	h.IDGenerator = func() (interface{}, error) { return "gen", nil }
+	ctx := context.Background()
+	it := &resource.Item{Payload: map[string]interface{}{}}
+	if err := h.Insert(ctx, []*resource.Item{it}); err != nil || it.ID != "gen" {
+		t.Fatal(err, it.ID)
+	}
+}